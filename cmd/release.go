@@ -40,6 +40,8 @@ type releaseOpts struct {
 	skipDocker         bool
 	skipKo             bool
 	skipBefore         bool
+	brewDryRun         bool
+	brewValidateOnly   bool
 	clean              bool
 	deprecated         bool
 	parallelism        int
@@ -95,6 +97,8 @@ func newReleaseCmd() *releaseCmd {
 	cmd.Flags().BoolVar(&root.opts.skipKo, "skip-ko", false, "Skips Ko builds")
 	cmd.Flags().BoolVar(&root.opts.skipBefore, "skip-before", false, "Skips global before hooks")
 	cmd.Flags().BoolVar(&root.opts.skipValidate, "skip-validate", false, "Skips git checks")
+	cmd.Flags().BoolVar(&root.opts.brewDryRun, "brew-dry-run", false, "Only affects Homebrew formulas: validates them and prints their diff against the published ones, without writing nor publishing them; other pipes run as usual (implies --skip-publish and --skip-announce)")
+	cmd.Flags().BoolVar(&root.opts.brewValidateOnly, "brew-validate-only", false, "Only affects Homebrew formulas: generates and validates them, without network access, writing nor publishing them; other pipes run as usual (implies --skip-publish and --skip-announce)")
 	cmd.Flags().BoolVar(&root.opts.clean, "clean", false, "Removes the dist folder")
 	cmd.Flags().BoolVar(&root.opts.rmDist, "rm-dist", false, "Removes the dist folder")
 	cmd.Flags().IntVarP(&root.opts.parallelism, "parallelism", "p", 0, "Amount tasks to run concurrently (default: number of CPUs)")
@@ -153,8 +157,10 @@ func setupReleaseContext(ctx *context.Context, options releaseOpts) {
 		log.Info("git repository is dirty and --auto-snapshot is set, implying --snapshot")
 		ctx.Snapshot = true
 	}
-	ctx.SkipPublish = ctx.Snapshot || options.skipPublish
-	ctx.SkipAnnounce = ctx.Snapshot || options.skipPublish || options.skipAnnounce
+	ctx.BrewDryRun = options.brewDryRun
+	ctx.BrewValidateOnly = options.brewValidateOnly
+	ctx.SkipPublish = ctx.Snapshot || options.skipPublish || options.brewDryRun || options.brewValidateOnly
+	ctx.SkipAnnounce = ctx.Snapshot || options.skipPublish || options.brewDryRun || options.brewValidateOnly || options.skipAnnounce
 	ctx.SkipValidate = ctx.Snapshot || options.skipValidate
 	ctx.SkipSign = options.skipSign
 	ctx.SkipSBOMCataloging = options.skipSBOMCataloging
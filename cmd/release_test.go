@@ -83,6 +83,27 @@ func TestReleaseFlags(t *testing.T) {
 		require.True(t, ctx.SkipAnnounce)
 	})
 
+	t.Run("brew dry run", func(t *testing.T) {
+		ctx := setup(t, releaseOpts{
+			brewDryRun: true,
+		})
+		require.True(t, ctx.BrewDryRun)
+		require.True(t, ctx.SkipPublish)
+		require.True(t, ctx.SkipAnnounce)
+		require.False(t, ctx.Snapshot)
+	})
+
+	t.Run("brew validate only", func(t *testing.T) {
+		ctx := setup(t, releaseOpts{
+			brewValidateOnly: true,
+		})
+		require.True(t, ctx.BrewValidateOnly)
+		require.False(t, ctx.BrewDryRun)
+		require.True(t, ctx.SkipPublish)
+		require.True(t, ctx.SkipAnnounce)
+		require.False(t, ctx.Snapshot)
+	})
+
 	t.Run("parallelism", func(t *testing.T) {
 		require.Equal(t, 1, setup(t, releaseOpts{
 			parallelism: 1,
@@ -153,6 +153,9 @@ const (
 	ExtraReplaces  = "Replaces"
 	ExtraDigest    = "Digest"
 	ExtraSize      = "Size"
+	// ExtraUploadName is the name of the artifact as uploaded to the release,
+	// if it differs from its name.
+	ExtraUploadName = "UploadName"
 )
 
 // Extras represents the extra fields in an artifact.
@@ -179,6 +182,7 @@ type Artifact struct {
 	Goarm   string `json:"goarm,omitempty"`
 	Gomips  string `json:"gomips,omitempty"`
 	Goamd64 string `json:"goamd64,omitempty"`
+	Goarm64 string `json:"goarm64,omitempty"`
 	Type    Type   `json:"internal_type,omitempty"`
 	TypeS   string `json:"type,omitempty"`
 	Extra   Extras `json:"extra,omitempty"`
@@ -324,7 +328,7 @@ func (artifacts *Artifacts) GroupByID() map[string][]*Artifact {
 func (artifacts *Artifacts) GroupByPlatform() map[string][]*Artifact {
 	result := map[string][]*Artifact{}
 	for _, a := range artifacts.List() {
-		plat := a.Goos + a.Goarch + a.Goarm + a.Gomips + a.Goamd64
+		plat := a.Goos + a.Goarch + a.Goarm + a.Gomips + a.Goamd64 + a.Goarm64
 		result[plat] = append(result[plat], a)
 	}
 	return result
@@ -432,6 +436,13 @@ func ByGoamd64(s string) Filter {
 	}
 }
 
+// ByGoarm64 is a predefined filter that filters by the given goarm64.
+func ByGoarm64(s string) Filter {
+	return func(a *Artifact) bool {
+		return a.Goarm64 == s
+	}
+}
+
 // ByType is a predefined filter that filters by the given type.
 func ByType(t Type) Filter {
 	return func(a *Artifact) bool {
@@ -83,6 +83,11 @@ func TestFilter(t *testing.T) {
 			Name:  "foobar",
 			Goarm: "6",
 		},
+		{
+			Name:    "foobaz",
+			Goarch:  "arm64",
+			Goarm64: "v9.0",
+		},
 		{
 			Name: "check",
 			Type: Checksum,
@@ -127,13 +132,16 @@ func TestFilter(t *testing.T) {
 	require.Len(t, artifacts.Filter(ByGoarm("6")).items, 1)
 	require.Len(t, artifacts.Filter(ByGoarm("7")).items, 0)
 
+	require.Len(t, artifacts.Filter(ByGoarm64("v9.0")).items, 1)
+	require.Len(t, artifacts.Filter(ByGoarm64("v8.0")).items, 0)
+
 	require.Len(t, artifacts.Filter(ByType(Checksum)).items, 2)
 	require.Len(t, artifacts.Filter(ByType(Binary)).items, 0)
 
-	require.Len(t, artifacts.Filter(OnlyReplacingUnibins).items, 9)
+	require.Len(t, artifacts.Filter(OnlyReplacingUnibins).items, 10)
 	require.Len(t, artifacts.Filter(And(OnlyReplacingUnibins, ByGoos("darwin"))).items, 1)
 
-	require.Len(t, artifacts.Filter(nil).items, 10)
+	require.Len(t, artifacts.Filter(nil).items, 11)
 
 	require.Len(t, artifacts.Filter(
 		And(
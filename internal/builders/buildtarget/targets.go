@@ -1,5 +1,5 @@
 // Package buildtarget can generate a list of targets based on a matrix of
-// goos, goarch, goarm, goamd64, gomips and go version.
+// goos, goarch, goarm, goamd64, goarm64, gomips and go version.
 package buildtarget
 
 import (
@@ -11,11 +11,11 @@ import (
 )
 
 type target struct {
-	os, arch, arm, mips, amd64 string
+	os, arch, arm, mips, amd64, arm64 string
 }
 
 func (t target) String() string {
-	if extra := t.arm + t.mips + t.amd64; extra != "" {
+	if extra := t.arm + t.mips + t.amd64 + t.arm64; extra != "" {
 		return fmt.Sprintf("%s_%s_%s", t.os, t.arch, extra)
 	}
 	return fmt.Sprintf("%s_%s", t.os, t.arch)
@@ -43,6 +43,9 @@ func List(build config.Build) ([]string, error) {
 		if target.amd64 != "" && !contains(target.amd64, validGoamd64) {
 			return result, fmt.Errorf("invalid goamd64: %s", target.amd64)
 		}
+		if target.arm64 != "" && !contains(target.arm64, validGoarm64) {
+			return result, fmt.Errorf("invalid goarm64: %s", target.arm64)
+		}
 		if !valid(target) {
 			log.WithField("target", target).Debug("skipped invalid build")
 			continue
@@ -82,6 +85,18 @@ func allBuildTargets(build config.Build) (targets []target) {
 				}
 				continue
 			}
+			// goarm64 is opt-in, so arm64 targets keep their names unless
+			// it is set.
+			if goarch == "arm64" && len(build.Goarm64) > 0 {
+				for _, goarm64 := range build.Goarm64 {
+					targets = append(targets, target{
+						os:    goos,
+						arch:  goarch,
+						arm64: goarm64,
+					})
+				}
+				continue
+			}
 			if strings.HasPrefix(goarch, "mips") {
 				for _, gomips := range build.Gomips {
 					targets = append(targets, target{
@@ -120,6 +135,9 @@ func ignored(build config.Build, target target) bool {
 		if ig.Goamd64 != "" && ig.Goamd64 != target.amd64 {
 			continue
 		}
+		if ig.Goarm64 != "" && ig.Goarm64 != target.arm64 {
+			continue
+		}
 		return true
 	}
 	return false
@@ -224,4 +242,8 @@ var (
 	validGoarm   = []string{"5", "6", "7"}
 	validGomips  = []string{"hardfloat", "softfloat"}
 	validGoamd64 = []string{"v1", "v2", "v3", "v4"}
+	validGoarm64 = []string{
+		"v8.0", "v8.1", "v8.2", "v8.3", "v8.4", "v8.5", "v8.6", "v8.7", "v8.8", "v8.9",
+		"v9.0", "v9.1", "v9.2", "v9.3", "v9.4", "v9.5",
+	}
 )
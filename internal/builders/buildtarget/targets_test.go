@@ -214,7 +214,7 @@ func TestGoosGoarchCombos(t *testing.T) {
 	}
 	for _, p := range platforms {
 		t.Run(fmt.Sprintf("%v %v valid=%v", p.os, p.arch, p.valid), func(t *testing.T) {
-			require.Equal(t, p.valid, valid(target{p.os, p.arch, "", "", ""}))
+			require.Equal(t, p.valid, valid(target{os: p.os, arch: p.arch}))
 		})
 	}
 }
@@ -242,4 +242,29 @@ func TestList(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []string{"linux_amd64_v2"}, targets)
 	})
+
+	t.Run("goarm64", func(t *testing.T) {
+		targets, err := List(config.Build{
+			Goos:     []string{"linux", "darwin"},
+			Goarch:   []string{"arm64"},
+			Goarm64:  []string{"v8.0", "v9.0"},
+			GoBinary: "go",
+			Ignore: []config.IgnoredBuild{{
+				Goos:    "darwin",
+				Goarm64: "v9.0",
+			}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"linux_arm64_v8.0", "linux_arm64_v9.0", "darwin_arm64_v8.0"}, targets)
+	})
+
+	t.Run("invalid goarm64", func(t *testing.T) {
+		_, err := List(config.Build{
+			Goos:     []string{"linux"},
+			Goarch:   []string{"arm64"},
+			Goarm64:  []string{"v7.0"},
+			GoBinary: "go",
+		})
+		require.EqualError(t, err, "invalid goarm64: v7.0")
+	})
 }
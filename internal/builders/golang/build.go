@@ -118,6 +118,7 @@ func warnIfTargetsAndOtherOptionTogether(build config.Build) bool {
 		"goarm":   len(build.Goarm),
 		"gomips":  len(build.Gomips),
 		"goamd64": len(build.Goamd64),
+		"goarm64": len(build.Goarm64),
 		"ignore":  len(build.Ignore),
 	} {
 		if v == 0 {
@@ -169,6 +170,7 @@ func (*Builder) Build(ctx *context.Context, build config.Build, options api.Opti
 		Goamd64: options.Goamd64,
 		Goarm:   options.Goarm,
 		Gomips:  options.Gomips,
+		Goarm64: options.Goarm64,
 		Extra: map[string]interface{}{
 			artifact.ExtraBinary: strings.TrimSuffix(filepath.Base(options.Path), options.Ext),
 			artifact.ExtraExt:    options.Ext,
@@ -215,6 +217,7 @@ func (*Builder) Build(ctx *context.Context, build config.Build, options api.Opti
 		"GOMIPS="+options.Gomips,
 		"GOMIPS64="+options.Gomips,
 		"GOAMD64="+options.Goamd64,
+		"GOARM64="+options.Goarm64,
 	)
 
 	if len(testEnvs) > 0 {
@@ -243,9 +246,9 @@ func (*Builder) Build(ctx *context.Context, build config.Build, options api.Opti
 }
 
 func withOverrides(ctx *context.Context, build config.Build, options api.Options) (config.BuildDetails, error) {
-	optsTarget := options.Goos + options.Goarch + options.Goarm + options.Gomips + options.Goamd64
+	optsTarget := options.Goos + options.Goarch + options.Goarm + options.Gomips + options.Goamd64 + options.Goarm64
 	for _, o := range build.BuildDetailsOverrides {
-		overrideTarget, err := tmpl.New(ctx).Apply(o.Goos + o.Goarch + o.Gomips + o.Goarm + o.Goamd64)
+		overrideTarget, err := tmpl.New(ctx).Apply(o.Goos + o.Goarch + o.Gomips + o.Goarm + o.Goamd64 + o.Goarm64)
 		if err != nil {
 			return build.BuildDetails, err
 		}
@@ -452,6 +455,7 @@ func getHeaderArtifactForLibrary(build config.Build, options api.Options) *artif
 		Goamd64: options.Goamd64,
 		Goarm:   options.Goarm,
 		Gomips:  options.Gomips,
+		Goarm64: options.Goarm64,
 		Extra: map[string]interface{}{
 			artifact.ExtraBinary: headerName,
 			artifact.ExtraExt:    ".h",
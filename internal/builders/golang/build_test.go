@@ -594,6 +594,37 @@ func TestBuildCodeInSubdir(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestBuildGoarm64(t *testing.T) {
+	folder := testlib.Mktmp(t)
+	writeGoodMain(t, folder)
+	ctx := testctx.NewWithCfg(config.Project{
+		Builds: []config.Build{
+			{
+				ID:       "foo",
+				Binary:   "foo",
+				Targets:  []string{"linux_arm64_v9.0"},
+				GoBinary: "go",
+				Command:  "build",
+				BuildDetails: config.BuildDetails{
+					Env: []string{"GO111MODULE=off"},
+				},
+			},
+		},
+	}, testctx.WithCurrentTag("5.6.7"))
+	build := ctx.Config.Builds[0]
+	require.NoError(t, Default.Build(ctx, build, api.Options{
+		Target:  "linux_arm64_v9.0",
+		Name:    build.Binary,
+		Path:    filepath.Join("dist", "linux_arm64_v9.0", build.Binary),
+		Goos:    "linux",
+		Goarch:  "arm64",
+		Goarm64: "v9.0",
+	}))
+	bins := ctx.Artifacts.Filter(artifact.ByType(artifact.Binary)).List()
+	require.Len(t, bins, 1)
+	require.Equal(t, "v9.0", bins[0].Goarm64)
+}
+
 func TestBuildWithDotGoDir(t *testing.T) {
 	folder := testlib.Mktmp(t)
 	require.NoError(t, os.Mkdir(filepath.Join(folder, ".go"), 0o755))
@@ -2,14 +2,19 @@
 package client
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/caarlos0/log"
+	"github.com/google/go-github/v53/github"
 	"github.com/goreleaser/goreleaser/internal/artifact"
 	"github.com/goreleaser/goreleaser/internal/tmpl"
 	"github.com/goreleaser/goreleaser/pkg/config"
 	"github.com/goreleaser/goreleaser/pkg/context"
+	"github.com/xanzy/go-gitlab"
 )
 
 const (
@@ -22,6 +27,13 @@ const (
 // ErrNotImplemented is returned when a client does not implement certain feature.
 var ErrNotImplemented = fmt.Errorf("not implemented")
 
+// ErrFileNotFound is returned when a file does not exist in the repository.
+var ErrFileNotFound = fmt.Errorf("file not found")
+
+// ErrNoToken is returned when a flow that needs a token, e.g. opening a pull
+// request, has none available.
+var ErrNoToken = fmt.Errorf("no token available: set repository.token, repository.token_env, or the GITHUB_TOKEN, GITLAB_TOKEN or GITEA_TOKEN environment variables")
+
 // Info of the repository.
 type Info struct {
 	Description string
@@ -35,6 +47,7 @@ type Repo struct {
 	Branch        string
 	GitURL        string
 	GitSSHCommand string
+	GitRemote     string
 	PrivateKey    string
 }
 
@@ -79,14 +92,31 @@ type FilesCreator interface {
 	CreateFiles(ctx *context.Context, commitAuthor config.CommitAuthor, repo Repo, message string, files []RepoFile) (err error)
 }
 
+// FileGetter can get the contents of a file in some code repository.
+type FileGetter interface {
+	GetFile(ctx *context.Context, repo Repo, path string) ([]byte, error)
+}
+
 // ReleaseNotesGenerator can generate release notes.
 type ReleaseNotesGenerator interface {
 	GenerateReleaseNotes(ctx *context.Context, repo Repo, prev, current string) (string, error)
 }
 
+// PullRequestOptions are the options used when opening a pull request.
+type PullRequestOptions struct {
+	Draft bool
+	// Milestone is the milestone name or number to set in the pull request.
+	Milestone string
+	// Reviewers are the users, or teams as org/team, to request reviews from.
+	Reviewers []string
+	// Labels are the labels to add to the pull request.
+	Labels []string
+}
+
 // PullRequestOpener can open pull requests.
+// Options the client can't apply, e.g. reviewers, are ignored with a warning.
 type PullRequestOpener interface {
-	OpenPullRequest(ctx *context.Context, base, head Repo, title string, draft bool) error
+	OpenPullRequest(ctx *context.Context, base, head Repo, title string, opts PullRequestOptions) error
 }
 
 // New creates a new client depending on the token type.
@@ -108,6 +138,21 @@ func newWithToken(ctx *context.Context, token string) (Client, error) {
 	}
 }
 
+// NewReleaseURLTemplater returns the ReleaserURLTemplater of the current
+// token type, which does not need a token nor network access.
+func NewReleaseURLTemplater(ctx *context.Context) (ReleaserURLTemplater, error) {
+	switch ctx.TokenType {
+	case context.TokenTypeGitHub:
+		return &githubClient{}, nil
+	case context.TokenTypeGitLab:
+		return &gitlabClient{}, nil
+	case context.TokenTypeGitea:
+		return &giteaClient{}, nil
+	default:
+		return nil, fmt.Errorf("invalid client token type: %q", ctx.TokenType)
+	}
+}
+
 func NewIfToken(ctx *context.Context, cli Client, token string) (Client, error) {
 	if token == "" {
 		return cli, nil
@@ -120,6 +165,59 @@ func NewIfToken(ctx *context.Context, cli Client, token string) (Client, error)
 	return newWithToken(ctx, token)
 }
 
+// RepositoryToken returns the token to use for the given repository, in order
+// of precedence:
+//
+//  1. repository.token, which can read it from the environment;
+//  2. the environment variable named in repository.token_env;
+//  3. none, meaning the token of the current client, e.g. GITHUB_TOKEN,
+//     should be used.
+func RepositoryToken(ctx *context.Context, repo config.RepoRef) (string, error) {
+	if repo.Token != "" {
+		return tmpl.New(ctx).ApplySingleEnvOnly(repo.Token)
+	}
+	if repo.TokenEnv != "" {
+		if token := ctx.Env[repo.TokenEnv]; token != "" {
+			return token, nil
+		}
+		log.WithField("env", repo.TokenEnv).Warn("repository.token_env is empty, using the default token")
+	}
+	return "", nil
+}
+
+// NewForRepository returns a client using the token of the given repository,
+// as resolved by RepositoryToken, or the given client itself if the
+// repository has no token.
+// If requireToken is set, ErrNoToken is returned when neither the repository
+// nor the context have a token.
+func NewForRepository(ctx *context.Context, cli Client, repo config.RepoRef, requireToken bool) (Client, error) {
+	token, err := RepositoryToken(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		if requireToken && ctx.Token == "" {
+			return nil, ErrNoToken
+		}
+		return cli, nil
+	}
+	log.Debug("using custom token")
+	return newWithToken(ctx, token)
+}
+
+// commitDate returns the date of the commit, or nil if it should be dated when
+// created.
+func commitDate(author config.CommitAuthor) (*time.Time, error) {
+	if author.Date == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, author.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit date: %w", err)
+	}
+	return &t, nil
+}
+
 func truncateReleaseBody(body string) string {
 	if len(body) > maxReleaseBodyLength {
 		body = body[1:(maxReleaseBodyLength-len(ellipsis))] + ellipsis
@@ -144,3 +242,30 @@ type RetriableError struct {
 func (e RetriableError) Error() string {
 	return e.Err.Error()
 }
+
+// IsRetriable tells whether the given error is transient, i.e. a
+// RetriableError, a rate limit, or a server side error from the provider API.
+// Other errors, e.g. authentication failures, are permanent.
+func IsRetriable(err error) bool {
+	if errors.As(err, &RetriableError{}) {
+		return true
+	}
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateErr) || errors.As(err, &abuseErr) {
+		return true
+	}
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		return isRetriableStatus(ghErr.Response.StatusCode)
+	}
+	var glErr *gitlab.ErrorResponse
+	if errors.As(err, &glErr) && glErr.Response != nil {
+		return isRetriableStatus(glErr.Response.StatusCode)
+	}
+	return false
+}
+
+func isRetriableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
@@ -1,13 +1,18 @@
 package client
 
 import (
+	"errors"
+	"fmt"
 	"math/rand"
+	"net/http"
 	"testing"
 
+	"github.com/google/go-github/v53/github"
 	"github.com/goreleaser/goreleaser/internal/testctx"
 	"github.com/goreleaser/goreleaser/pkg/config"
 	"github.com/goreleaser/goreleaser/pkg/context"
 	"github.com/stretchr/testify/require"
+	"github.com/xanzy/go-gitlab"
 )
 
 func TestClientEmpty(t *testing.T) {
@@ -99,6 +104,72 @@ func TestNewIfToken(t *testing.T) {
 	})
 }
 
+func TestRepositoryToken(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		Env: []string{"EXPLICIT=explicit", "FROM_ENV=from-env"},
+	})
+
+	for name, tt := range map[string]struct {
+		repo   config.RepoRef
+		expect string
+	}{
+		"explicit first": {
+			repo:   config.RepoRef{Token: "{{ .Env.EXPLICIT }}", TokenEnv: "FROM_ENV"},
+			expect: "explicit",
+		},
+		"env": {
+			repo:   config.RepoRef{TokenEnv: "FROM_ENV"},
+			expect: "from-env",
+		},
+		"empty env": {
+			repo: config.RepoRef{TokenEnv: "NOPE"},
+		},
+		"ambient": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			token, err := RepositoryToken(ctx, tt.repo)
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, token)
+		})
+	}
+
+	t.Run("invalid tmpl", func(t *testing.T) {
+		_, err := RepositoryToken(ctx, config.RepoRef{Token: "nope"})
+		require.EqualError(t, err, `expected {{ .Env.VAR_NAME }} only (no plain-text or other interpolation)`)
+	})
+}
+
+func TestNewForRepository(t *testing.T) {
+	t.Run("token env", func(t *testing.T) {
+		ctx := testctx.NewWithCfg(config.Project{
+			Env: []string{"TAP_TOKEN=tap-token"},
+		}, testctx.GitLabTokenType)
+		cli, err := NewForRepository(ctx, NewMock(), config.RepoRef{TokenEnv: "TAP_TOKEN"}, true)
+		require.NoError(t, err)
+		_, ok := cli.(*gitlabClient)
+		require.True(t, ok)
+	})
+
+	t.Run("ambient", func(t *testing.T) {
+		ctx := testctx.New(testctx.GitLabTokenType)
+		mock := NewMock()
+		cli, err := NewForRepository(ctx, mock, config.RepoRef{TokenEnv: "TAP_TOKEN"}, true)
+		require.NoError(t, err)
+		require.Equal(t, mock, cli)
+	})
+
+	t.Run("no token", func(t *testing.T) {
+		ctx := testctx.New(testctx.WithTokenType(context.TokenTypeGitLab))
+		mock := NewMock()
+		cli, err := NewForRepository(ctx, mock, config.RepoRef{}, false)
+		require.NoError(t, err)
+		require.Equal(t, mock, cli)
+
+		_, err = NewForRepository(ctx, mock, config.RepoRef{}, true)
+		require.ErrorIs(t, err, ErrNoToken)
+	})
+}
+
 func TestNewWithToken(t *testing.T) {
 	t.Run("gitlab", func(t *testing.T) {
 		ctx := testctx.NewWithCfg(config.Project{
@@ -137,6 +208,68 @@ func TestNewWithToken(t *testing.T) {
 	})
 }
 
+func TestNewReleaseURLTemplater(t *testing.T) {
+	t.Run("github", func(t *testing.T) {
+		ctx := testctx.NewWithCfg(config.Project{
+			GitHubURLs: config.GitHubURLs{Download: "https://github.com"},
+			Release: config.Release{
+				GitHub: config.Repo{Owner: "foo", Name: "bar"},
+			},
+		}, testctx.GitHubTokenType)
+		cli, err := NewReleaseURLTemplater(ctx)
+		require.NoError(t, err)
+		url, err := cli.ReleaseURLTemplate(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "https://github.com/foo/bar/releases/download/{{ .Tag }}/{{ .ArtifactName }}", url)
+	})
+
+	t.Run("gitea", func(t *testing.T) {
+		// unlike New, it does not fetch the Gitea version.
+		ctx := testctx.NewWithCfg(config.Project{
+			GiteaURLs: config.GiteaURLs{
+				API:      "http://localhost:1/api/v1",
+				Download: "https://gitea.com",
+			},
+			Release: config.Release{
+				Gitea: config.Repo{Owner: "foo", Name: "bar"},
+			},
+		}, testctx.GiteaTokenType)
+		cli, err := NewReleaseURLTemplater(ctx)
+		require.NoError(t, err)
+		url, err := cli.ReleaseURLTemplate(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "https://gitea.com/foo/bar/releases/download/{{ .Tag }}/{{ .ArtifactName }}", url)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cli, err := NewReleaseURLTemplater(testctx.New())
+		require.EqualError(t, err, `invalid client token type: ""`)
+		require.Nil(t, cli)
+	})
+}
+
+func TestIsRetriable(t *testing.T) {
+	ghErr := func(code int) error {
+		return fmt.Errorf("could not update %q: %w", "foo.rb", &github.ErrorResponse{
+			Response: &http.Response{StatusCode: code},
+		})
+	}
+	glErr := func(code int) error {
+		return &gitlab.ErrorResponse{Response: &http.Response{StatusCode: code}}
+	}
+
+	require.True(t, IsRetriable(RetriableError{Err: errors.New("upload failed")}))
+	require.True(t, IsRetriable(fmt.Errorf("wrapped: %w", &github.RateLimitError{})))
+	require.True(t, IsRetriable(&github.AbuseRateLimitError{}))
+	require.True(t, IsRetriable(ghErr(http.StatusBadGateway)))
+	require.True(t, IsRetriable(ghErr(http.StatusTooManyRequests)))
+	require.True(t, IsRetriable(glErr(http.StatusServiceUnavailable)))
+	require.False(t, IsRetriable(ghErr(http.StatusUnauthorized)))
+	require.False(t, IsRetriable(glErr(http.StatusForbidden)))
+	require.False(t, IsRetriable(errors.New("some error")))
+	require.False(t, IsRetriable(nil))
+}
+
 func TestClientBlanks(t *testing.T) {
 	repo := Repo{}
 	require.Equal(t, "", repo.String())
@@ -10,6 +10,7 @@ func RepoFromRef(ref config.RepoRef) Repo {
 		Branch:        ref.Branch,
 		GitURL:        ref.Git.URL,
 		GitSSHCommand: ref.Git.SSHCommand,
+		GitRemote:     ref.Git.Remote,
 		PrivateKey:    ref.Git.PrivateKey,
 	}
 }
@@ -36,16 +37,25 @@ func TemplateRef(apply func(s string) (string, error), ref config.RepoRef) (conf
 	if err != nil {
 		return ref, err
 	}
+	milestone, err := apply(ref.PullRequest.Milestone)
+	if err != nil {
+		return ref, err
+	}
+	pr := ref.PullRequest
+	pr.Milestone = milestone
 	return config.RepoRef{
-		Owner:       owner,
-		Name:        name,
-		Token:       ref.Token,
-		Branch:      branch,
-		PullRequest: ref.PullRequest,
+		Owner:        owner,
+		Name:         name,
+		Token:        ref.Token,
+		TokenEnv:     ref.TokenEnv,
+		Branch:       branch,
+		CommitBranch: ref.CommitBranch,
+		PullRequest:  pr,
 		Git: config.GitRepoRef{
 			URL:        gitURL,
 			PrivateKey: privateKey,
 			SSHCommand: ref.Git.SSHCommand,
+			Remote:     ref.Git.Remote,
 		},
 	}, nil
 }
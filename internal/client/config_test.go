@@ -29,10 +29,12 @@ func TestRepoFromRef(t *testing.T) {
 
 func TestTemplateRef(t *testing.T) {
 	expected := config.RepoRef{
-		Owner:  "owner",
-		Name:   "name",
-		Branch: "branch",
-		Token:  "token",
+		Owner:        "owner",
+		Name:         "name",
+		Branch:       "branch",
+		CommitBranch: "commitbranch",
+		Token:        "token",
+		TokenEnv:     "TOKEN_ENV",
 		Git: config.GitRepoRef{
 			URL:        "giturl",
 			SSHCommand: "gitsshcommand",
@@ -2,6 +2,8 @@ package client
 
 import (
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -79,6 +81,73 @@ func TestGitClient(t *testing.T) {
 		))
 		require.Equal(t, "fake content 2", string(testlib.CatFileFromBareRepository(t, url, "fake.txt")))
 	})
+	t.Run("no changes", func(t *testing.T) {
+		url := testlib.GitMakeBareRepository(t)
+		ctx := testctx.NewWithCfg(config.Project{
+			Dist: t.TempDir(),
+		})
+		repo := Repo{
+			GitURL:     url,
+			PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+			Name:       "test-no-changes",
+		}
+		for _, msg := range []string{"first", "second"} {
+			require.NoError(t, cli.CreateFile(
+				ctx,
+				author,
+				repo,
+				[]byte("fake content"),
+				"fake.txt",
+				msg,
+			))
+		}
+		require.Equal(t, "fake content", string(testlib.CatFileFromBareRepository(t, url, "fake.txt")))
+		out, err := exec.Command("git", "-C", url, "log", "--format=%s").CombinedOutput()
+		require.NoError(t, err)
+		require.Equal(t, "first", strings.TrimSpace(string(out)))
+	})
+	t.Run("custom remote", func(t *testing.T) {
+		url := testlib.GitMakeBareRepository(t)
+		ctx := testctx.NewWithCfg(config.Project{
+			Dist: t.TempDir(),
+		})
+		repo := Repo{
+			GitURL:     url,
+			PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+			Name:       "test-remote",
+			GitRemote:  "upstream",
+		}
+		require.NoError(t, cli.CreateFile(ctx, author, repo, []byte("fake content"), "fake.txt", "first"))
+		require.Equal(t, "fake content", string(testlib.CatFileFromBareRepository(t, url, "fake.txt")))
+
+		// same repository, another remote name
+		repo.GitRemote = "other"
+		require.NoError(t, cli.CreateFile(ctx, author, repo, []byte("fake content 2"), "fake.txt", "second"))
+		require.Equal(t, "fake content 2", string(testlib.CatFileFromBareRepository(t, url, "fake.txt")))
+
+		out, err := exec.Command("git", "-C", filepath.Join(ctx.Config.Dist, "git", repo.Name), "remote").CombinedOutput()
+		require.NoError(t, err)
+		require.Equal(t, []string{"other", "upstream"}, strings.Fields(string(out)))
+	})
+	t.Run("with date", func(t *testing.T) {
+		url := testlib.GitMakeBareRepository(t)
+		ctx := testctx.NewWithCfg(config.Project{
+			Dist: t.TempDir(),
+		})
+		repo := Repo{
+			GitURL:     url,
+			PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+			Name:       "test-date",
+		}
+		author := author
+		author.Date = "2023-07-10T14:40:49Z"
+		require.NoError(t, cli.CreateFile(ctx, author, repo, []byte("fake content"), "fake.txt", "dated"))
+
+		out, err := exec.Command("git", "-C", url, "log", "-1", "--format=%aI %cI", "master").CombinedOutput()
+		require.NoError(t, err)
+		require.Equal(t, "2023-07-10T14:40:49Z 2023-07-10T14:40:49Z", strings.TrimSpace(strings.ReplaceAll(string(out), "+00:00", "Z")))
+	})
+
 	t.Run("bad url", func(t *testing.T) {
 		ctx := testctx.NewWithCfg(config.Project{
 			Dist: t.TempDir(),
@@ -182,6 +251,177 @@ func TestGitClient(t *testing.T) {
 	})
 }
 
+func TestGitClientForBranch(t *testing.T) {
+	url := testlib.GitMakeBareRepository(t)
+	repo := Repo{
+		GitURL:     url,
+		PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+		Name:       "test1",
+	}
+	author := config.CommitAuthor{
+		Name:  "Foo",
+		Email: "foo@bar.com",
+	}
+
+	// the second commit starts from the already pushed branch.
+	ctx := testctx.NewWithCfg(config.Project{
+		Dist: t.TempDir(),
+	})
+	for _, file := range []string{"fake.txt", "fake2.txt"} {
+		require.NoError(t, NewGitUploadClientForBranch("formulas").CreateFile(
+			ctx,
+			author,
+			repo,
+			[]byte(file+" content"),
+			file,
+			"hey test",
+		))
+	}
+
+	require.Equal(t, "fake.txt content", string(testlib.CatFileFromBareRepositoryBranch(t, url, "formulas", "fake.txt")))
+	require.Equal(t, "fake2.txt content", string(testlib.CatFileFromBareRepositoryBranch(t, url, "formulas", "fake2.txt")))
+}
+
+func TestGitClientBranchNotCheckedOut(t *testing.T) {
+	url := testlib.GitMakeBareRepository(t)
+	ctx := testctx.NewWithCfg(config.Project{
+		Dist: t.TempDir(),
+	})
+	require.NoError(t, NewGitUploadClient("formulas").CreateFile(
+		ctx,
+		config.CommitAuthor{
+			Name:  "Foo",
+			Email: "foo@bar.com",
+		},
+		Repo{
+			GitURL:     url,
+			PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+			Name:       "test1",
+		},
+		[]byte("fake content"),
+		"fake.txt",
+		"hey test",
+	))
+	require.Equal(t, "fake content", string(testlib.CatFileFromBareRepository(t, url, "fake.txt")))
+}
+
+func TestGitClientSigning(t *testing.T) {
+	testlib.CheckPath(t, "gpg")
+	cli := NewGitUploadClient("master")
+	key := makeGPGKey(t, "Foo <foo@bar.com>", "s3cr3t")
+	contents, err := os.ReadFile(key)
+	require.NoError(t, err)
+
+	for name, signing := range map[string]config.CommitSigning{
+		"key path": {
+			Key:           key,
+			PassphraseEnv: "GPG_PASSPHRASE",
+		},
+		"key contents": {
+			Key:           string(contents),
+			PassphraseEnv: "GPG_PASSPHRASE",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			url := testlib.GitMakeBareRepository(t)
+			ctx := testctx.NewWithCfg(config.Project{
+				Dist: t.TempDir(),
+			}, testctx.WithEnv(map[string]string{"GPG_PASSPHRASE": "s3cr3t"}))
+			repo := Repo{
+				GitURL:     url,
+				PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+				Name:       "test1",
+			}
+			require.NoError(t, cli.CreateFile(
+				ctx,
+				config.CommitAuthor{
+					Name:    "Foo",
+					Email:   "foo@bar.com",
+					Signing: signing,
+				},
+				repo,
+				[]byte("fake content"),
+				"fake.txt",
+				"hey test",
+			))
+			commit, err := exec.Command("git", "-C", url, "cat-file", "commit", "HEAD").CombinedOutput()
+			require.NoError(t, err, string(commit))
+			require.Contains(t, string(commit), "gpgsig -----BEGIN PGP SIGNATURE-----")
+		})
+	}
+
+	t.Run("passphrase not set", func(t *testing.T) {
+		url := testlib.GitMakeBareRepository(t)
+		ctx := testctx.NewWithCfg(config.Project{
+			Dist: t.TempDir(),
+		})
+		repo := Repo{
+			GitURL:     url,
+			PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+			Name:       "test1",
+		}
+		require.EqualError(t, cli.CreateFile(
+			ctx,
+			config.CommitAuthor{
+				Name:  "Foo",
+				Email: "foo@bar.com",
+				Signing: config.CommitSigning{
+					Key:           key,
+					PassphraseEnv: "NOPE_GPG_PASSPHRASE",
+				},
+			},
+			repo,
+			[]byte("fake content"),
+			"fake.txt",
+			"hey test",
+		), `git: signing passphrase env "NOPE_GPG_PASSPHRASE" is not set`)
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		url := testlib.GitMakeBareRepository(t)
+		ctx := testctx.NewWithCfg(config.Project{
+			Dist: t.TempDir(),
+		})
+		repo := Repo{
+			GitURL:     url,
+			PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+			Name:       "test1",
+		}
+		require.NoError(t, cli.CreateFile(
+			ctx,
+			config.CommitAuthor{Name: "Foo", Email: "foo@bar.com"},
+			repo,
+			[]byte("fake content"),
+			"fake.txt",
+			"hey test",
+		))
+		commit, err := exec.Command("git", "-C", url, "cat-file", "commit", "HEAD").CombinedOutput()
+		require.NoError(t, err, string(commit))
+		require.NotContains(t, string(commit), "gpgsig")
+	})
+}
+
+// makeGPGKey generates a passphrase-protected GPG key in a temporary GPG
+// home, and returns the path of its armored private key.
+func makeGPGKey(tb testing.TB, uid, passphrase string) string {
+	tb.Helper()
+	home := tb.TempDir()
+	gpg := func(args ...string) []byte {
+		tb.Helper()
+		args = append([]string{"--homedir", home, "--batch", "--pinentry-mode", "loopback", "--passphrase", passphrase}, args...)
+		out, err := exec.Command("gpg", args...).Output()
+		require.NoError(tb, err)
+		return out
+	}
+	gpg("--quick-generate-key", uid, "default", "default", "never")
+	path := filepath.Join(tb.TempDir(), "key.asc")
+	require.NoError(tb, os.WriteFile(path, gpg("--armor", "--export-secret-keys", uid), 0o600))
+	tb.Cleanup(func() {
+		_ = exec.Command("gpgconf", "--homedir", home, "--kill", "gpg-agent").Run()
+	})
+	return path
+}
+
 func TestKeyPath(t *testing.T) {
 	t.Run("with valid path", func(t *testing.T) {
 		path := testlib.MakeNewSSHKey(t, keygen.Ed25519, "")
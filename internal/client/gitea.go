@@ -146,6 +146,17 @@ func (c *giteaClient) CreateFile(
 		},
 	}
 
+	date, err := commitDate(commitAuthor)
+	if err != nil {
+		return err
+	}
+	if date != nil {
+		fileOptions.Dates = gitea.CommitDateOptions{
+			Author:    *date,
+			Committer: *date,
+		}
+	}
+
 	log.
 		WithField("repository", repo.String()).
 		WithField("name", repo.Name).
@@ -26,6 +26,7 @@ var (
 	_ Client                = &githubClient{}
 	_ ReleaseNotesGenerator = &githubClient{}
 	_ PullRequestOpener     = &githubClient{}
+	_ FileGetter            = &githubClient{}
 )
 
 type githubClient struct {
@@ -193,7 +194,7 @@ func (c *githubClient) OpenPullRequest(
 	ctx *context.Context,
 	base, head Repo,
 	title string,
-	draft bool,
+	opts PullRequestOptions,
 ) error {
 	c.checkRateLimit(ctx)
 	if base.Branch == "" {
@@ -213,7 +214,7 @@ func (c *githubClient) OpenPullRequest(
 	log := log.
 		WithField("base", headString(base, Repo{})).
 		WithField("head", headString(base, head)).
-		WithField("draft", draft)
+		WithField("draft", opts.Draft)
 	log.Info("opening pull request")
 	pr, res, err := c.client.PullRequests.Create(
 		ctx,
@@ -224,7 +225,7 @@ func (c *githubClient) OpenPullRequest(
 			Base:  github.String(base.Branch),
 			Head:  github.String(headString(base, head)),
 			Body:  github.String(strings.Join([]string{tpl, prFooter}, "\n")),
-			Draft: github.Bool(draft),
+			Draft: github.Bool(opts.Draft),
 		},
 	)
 	if err != nil {
@@ -235,9 +236,111 @@ func (c *githubClient) OpenPullRequest(
 		return fmt.Errorf("could not create pull request: %w", err)
 	}
 	log.WithField("url", pr.GetHTMLURL()).Info("pull request created")
+
+	repo := Repo{
+		Owner: firstNonEmpty(base.Owner, head.Owner),
+		Name:  firstNonEmpty(base.Name, head.Name),
+	}
+	if opts.Milestone != "" {
+		c.setPullRequestMilestone(ctx, repo, pr.GetNumber(), opts.Milestone)
+	}
+	if len(opts.Reviewers) > 0 {
+		c.requestPullRequestReviewers(ctx, repo, pr.GetNumber(), opts.Reviewers)
+	}
+	if len(opts.Labels) > 0 {
+		c.addPullRequestLabels(ctx, repo, pr.GetNumber(), opts.Labels)
+	}
 	return nil
 }
 
+// requestPullRequestReviewers requests reviews of the given pull request from
+// the given users, or teams, given as org/team.
+// Failing to do so only warns, as the pull request was already opened.
+func (c *githubClient) requestPullRequestReviewers(ctx *context.Context, repo Repo, number int, reviewers []string) {
+	log := log.WithField("reviewers", reviewers)
+	var req github.ReviewersRequest
+	for _, reviewer := range reviewers {
+		if _, team, ok := strings.Cut(reviewer, "/"); ok {
+			req.TeamReviewers = append(req.TeamReviewers, team)
+			continue
+		}
+		req.Reviewers = append(req.Reviewers, reviewer)
+	}
+	c.checkRateLimit(ctx)
+	if _, _, err := c.client.PullRequests.RequestReviewers(ctx, repo.Owner, repo.Name, number, req); err != nil {
+		log.WithError(err).Warn("could not request pull request reviewers")
+		return
+	}
+	log.Info("pull request reviewers requested")
+}
+
+// addPullRequestLabels adds the given labels to the given pull request.
+// Failing to do so only warns, as the pull request was already opened.
+func (c *githubClient) addPullRequestLabels(ctx *context.Context, repo Repo, number int, labels []string) {
+	log := log.WithField("labels", labels)
+	c.checkRateLimit(ctx)
+	if _, _, err := c.client.Issues.AddLabelsToIssue(ctx, repo.Owner, repo.Name, number, labels); err != nil {
+		log.WithError(err).Warn("could not add pull request labels")
+		return
+	}
+	log.Info("pull request labels added")
+}
+
+// setPullRequestMilestone sets the milestone, given by name or number, of the
+// given pull request.
+// Failing to do so only warns, as the pull request was already opened.
+func (c *githubClient) setPullRequestMilestone(ctx *context.Context, repo Repo, number int, milestone string) {
+	log := log.WithField("milestone", milestone)
+	id, err := strconv.Atoi(milestone)
+	if err != nil {
+		m, err := c.getMilestoneByTitle(ctx, repo, milestone)
+		if err != nil {
+			log.WithError(err).Warn("could not get milestone")
+			return
+		}
+		if m == nil {
+			log.Warn("milestone not found")
+			return
+		}
+		id = m.GetNumber()
+	}
+	c.checkRateLimit(ctx)
+	if _, _, err := c.client.Issues.Edit(ctx, repo.Owner, repo.Name, number, &github.IssueRequest{
+		Milestone: github.Int(id),
+	}); err != nil {
+		log.WithError(err).Warn("could not set pull request milestone")
+		return
+	}
+	log.Info("pull request milestone set")
+}
+
+func (c *githubClient) GetFile(ctx *context.Context, repo Repo, path string) ([]byte, error) {
+	c.checkRateLimit(ctx)
+	file, _, res, err := c.client.Repositories.GetContents(
+		ctx,
+		repo.Owner,
+		repo.Name,
+		path,
+		&github.RepositoryContentGetOptions{
+			Ref: repo.Branch,
+		},
+	)
+	if res != nil && res.StatusCode == http.StatusNotFound {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get %q: %w", path, err)
+	}
+	if file == nil {
+		return nil, fmt.Errorf("could not get %q: not a file", path)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %q: %w", path, err)
+	}
+	return []byte(content), nil
+}
+
 func (c *githubClient) CreateFile(
 	ctx *context.Context,
 	commitAuthor config.CommitAuthor,
@@ -266,6 +369,19 @@ func (c *githubClient) CreateFile(
 		Message: github.String(message),
 	}
 
+	date, err := commitDate(commitAuthor)
+	if err != nil {
+		return err
+	}
+	if date != nil {
+		options.Committer.Date = &github.Timestamp{Time: *date}
+		options.Author = &github.CommitAuthor{
+			Name:  options.Committer.Name,
+			Email: options.Committer.Email,
+			Date:  options.Committer.Date,
+		}
+	}
+
 	// Set the branch if we got it above...otherwise, just default to
 	// whatever the SDK does auto-magically
 	if branch != "" {
@@ -441,7 +557,7 @@ func (c *githubClient) ReleaseURLTemplate(ctx *context.Context) (string, error)
 func (c *githubClient) Upload(
 	ctx *context.Context,
 	releaseID string,
-	artifact *artifact.Artifact,
+	art *artifact.Artifact,
 	file *os.File,
 ) error {
 	c.checkRateLimit(ctx)
@@ -449,13 +565,13 @@ func (c *githubClient) Upload(
 	if err != nil {
 		return err
 	}
-	_, resp, err := c.client.Repositories.UploadReleaseAsset(
+	asset, resp, err := c.client.Repositories.UploadReleaseAsset(
 		ctx,
 		ctx.Config.Release.GitHub.Owner,
 		ctx.Config.Release.GitHub.Name,
 		githubReleaseID,
 		&github.UploadOptions{
-			Name: artifact.Name,
+			Name: art.Name,
 		},
 		file,
 	)
@@ -464,12 +580,19 @@ func (c *githubClient) Upload(
 		if resp != nil {
 			requestID = resp.Header.Get("X-GitHub-Request-Id")
 		}
-		log.WithField("name", artifact.Name).
+		log.WithField("name", art.Name).
 			WithField("release-id", releaseID).
 			WithField("request-id", requestID).
 			Warn("upload failed")
 	}
 	if err == nil {
+		// GitHub may rename the asset, e.g. replacing special characters.
+		if name := asset.GetName(); name != "" && name != art.Name {
+			if art.Extra == nil {
+				art.Extra = artifact.Extras{}
+			}
+			art.Extra[artifact.ExtraUploadName] = name
+		}
 		return nil
 	}
 	if resp != nil && resp.StatusCode == 422 {
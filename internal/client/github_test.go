@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
 	"text/template"
@@ -126,6 +127,52 @@ func TestGitHubUploadReleaseIDNotInt(t *testing.T) {
 	)
 }
 
+func TestGitHubUploadRenamed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		if r.URL.Path == "/repos/someone/something/releases/1/assets" {
+			require.Equal(t, "foo bar.tar.gz", r.URL.Query().Get("name"))
+			fmt.Fprint(w, `{"name": "foo.bar.tar.gz"}`)
+			return
+		}
+
+		if r.URL.Path == "/rate_limit" {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"resources":{"core":{"remaining":120}}}`)
+			return
+		}
+
+		t.Error("unhandled request: " + r.URL.Path)
+	}))
+	defer srv.Close()
+
+	ctx := testctx.NewWithCfg(config.Project{
+		GitHubURLs: config.GitHubURLs{
+			API:    srv.URL + "/",
+			Upload: srv.URL + "/",
+		},
+		Release: config.Release{
+			GitHub: config.Repo{
+				Owner: "someone",
+				Name:  "something",
+			},
+		},
+	})
+	client, err := newGitHub(ctx, "test-token")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "foo.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("fake"), 0o644))
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = file.Close() })
+
+	art := &artifact.Artifact{Name: "foo bar.tar.gz", Path: path}
+	require.NoError(t, client.Upload(ctx, "1", art, file))
+	require.Equal(t, "foo.bar.tar.gz", artifact.ExtraOr(*art, artifact.ExtraUploadName, ""))
+}
+
 func TestGitHubReleaseURLTemplate(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -465,7 +512,7 @@ func TestGitHubOpenPullRequestCrossRepo(t *testing.T) {
 		Name:   "something",
 		Branch: "foo",
 	}
-	require.NoError(t, client.OpenPullRequest(ctx, base, head, "some title", false))
+	require.NoError(t, client.OpenPullRequest(ctx, base, head, "some title", PullRequestOptions{}))
 }
 
 func TestGitHubOpenPullRequestHappyPath(t *testing.T) {
@@ -513,7 +560,189 @@ func TestGitHubOpenPullRequestHappyPath(t *testing.T) {
 		Branch: "main",
 	}
 
-	require.NoError(t, client.OpenPullRequest(ctx, repo, Repo{}, "some title", false))
+	require.NoError(t, client.OpenPullRequest(ctx, repo, Repo{}, "some title", PullRequestOptions{}))
+}
+
+func TestGitHubOpenPullRequestMilestone(t *testing.T) {
+	for name, milestone := range map[string]string{
+		"by title":  "v1.0",
+		"by number": "3",
+	} {
+		t.Run(name, func(t *testing.T) {
+			var milestoneSet bool
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer r.Body.Close()
+
+				if r.URL.Path == "/repos/someone/something/contents/.github/PULL_REQUEST_TEMPLATE.md" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				if r.URL.Path == "/repos/someone/something/pulls" {
+					fmt.Fprint(w, `{"number": 1}`)
+					return
+				}
+
+				if r.URL.Path == "/repos/someone/something/milestones" {
+					fmt.Fprint(w, `[{"number": 2, "title": "v0.9"}, {"number": 3, "title": "v1.0"}]`)
+					return
+				}
+
+				if r.URL.Path == "/repos/someone/something/issues/1" {
+					require.Equal(t, http.MethodPatch, r.Method)
+					got, err := io.ReadAll(r.Body)
+					require.NoError(t, err)
+					require.JSONEq(t, `{"milestone": 3}`, string(got))
+					milestoneSet = true
+					fmt.Fprint(w, `{}`)
+					return
+				}
+
+				if r.URL.Path == "/rate_limit" {
+					w.WriteHeader(http.StatusOK)
+					fmt.Fprint(w, `{"resources":{"core":{"remaining":120}}}`)
+					return
+				}
+
+				t.Error("unhandled request: " + r.URL.Path)
+			}))
+			defer srv.Close()
+
+			ctx := testctx.NewWithCfg(config.Project{
+				GitHubURLs: config.GitHubURLs{
+					API: srv.URL + "/",
+				},
+			})
+			client, err := newGitHub(ctx, "test-token")
+			require.NoError(t, err)
+			repo := Repo{
+				Owner:  "someone",
+				Name:   "something",
+				Branch: "main",
+			}
+
+			require.NoError(t, client.OpenPullRequest(ctx, repo, Repo{}, "some title", PullRequestOptions{
+				Milestone: milestone,
+			}))
+			require.True(t, milestoneSet)
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+
+			if r.URL.Path == "/repos/someone/something/contents/.github/PULL_REQUEST_TEMPLATE.md" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			if r.URL.Path == "/repos/someone/something/pulls" {
+				r, err := os.Open("testdata/github/pull.json")
+				require.NoError(t, err)
+				_, err = io.Copy(w, r)
+				require.NoError(t, err)
+				return
+			}
+
+			if r.URL.Path == "/repos/someone/something/milestones" {
+				fmt.Fprint(w, `[]`)
+				return
+			}
+
+			if r.URL.Path == "/rate_limit" {
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprint(w, `{"resources":{"core":{"remaining":120}}}`)
+				return
+			}
+
+			t.Error("unhandled request: " + r.URL.Path)
+		}))
+		defer srv.Close()
+
+		ctx := testctx.NewWithCfg(config.Project{
+			GitHubURLs: config.GitHubURLs{
+				API: srv.URL + "/",
+			},
+		})
+		client, err := newGitHub(ctx, "test-token")
+		require.NoError(t, err)
+		repo := Repo{
+			Owner:  "someone",
+			Name:   "something",
+			Branch: "main",
+		}
+
+		require.NoError(t, client.OpenPullRequest(ctx, repo, Repo{}, "some title", PullRequestOptions{
+			Milestone: "nope",
+		}))
+	})
+}
+
+func TestGitHubOpenPullRequestReviewersAndLabels(t *testing.T) {
+	var reviewersRequested, labelsAdded bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		if r.URL.Path == "/repos/someone/something/contents/.github/PULL_REQUEST_TEMPLATE.md" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.URL.Path == "/repos/someone/something/pulls" {
+			fmt.Fprint(w, `{"number": 1}`)
+			return
+		}
+
+		if r.URL.Path == "/repos/someone/something/pulls/1/requested_reviewers" {
+			require.Equal(t, http.MethodPost, r.Method)
+			got, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"reviewers": ["caarlos0"], "team_reviewers": ["maintainers"]}`, string(got))
+			reviewersRequested = true
+			fmt.Fprint(w, `{}`)
+			return
+		}
+
+		if r.URL.Path == "/repos/someone/something/issues/1/labels" {
+			require.Equal(t, http.MethodPost, r.Method)
+			got, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.JSONEq(t, `["automated", "release"]`, string(got))
+			labelsAdded = true
+			fmt.Fprint(w, `[]`)
+			return
+		}
+
+		if r.URL.Path == "/rate_limit" {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"resources":{"core":{"remaining":120}}}`)
+			return
+		}
+
+		t.Error("unhandled request: " + r.URL.Path)
+	}))
+	defer srv.Close()
+
+	ctx := testctx.NewWithCfg(config.Project{
+		GitHubURLs: config.GitHubURLs{
+			API: srv.URL + "/",
+		},
+	})
+	client, err := newGitHub(ctx, "test-token")
+	require.NoError(t, err)
+	repo := Repo{
+		Owner:  "someone",
+		Name:   "something",
+		Branch: "main",
+	}
+
+	require.NoError(t, client.OpenPullRequest(ctx, repo, Repo{}, "some title", PullRequestOptions{
+		Reviewers: []string{"caarlos0", "goreleaser/maintainers"},
+		Labels:    []string{"automated", "release"},
+	}))
+	require.True(t, reviewersRequested)
+	require.True(t, labelsAdded)
 }
 
 func TestGitHubOpenPullRequestNoBaseBranchDraft(t *testing.T) {
@@ -571,7 +800,7 @@ func TestGitHubOpenPullRequestNoBaseBranchDraft(t *testing.T) {
 
 	require.NoError(t, client.OpenPullRequest(ctx, repo, Repo{
 		Branch: "foo",
-	}, "some title", true))
+	}, "some title", PullRequestOptions{Draft: true}))
 }
 
 func TestGitHubOpenPullRequestPRExists(t *testing.T) {
@@ -615,7 +844,7 @@ func TestGitHubOpenPullRequestPRExists(t *testing.T) {
 		Branch: "main",
 	}
 
-	require.NoError(t, client.OpenPullRequest(ctx, repo, Repo{}, "some title", false))
+	require.NoError(t, client.OpenPullRequest(ctx, repo, Repo{}, "some title", PullRequestOptions{}))
 }
 
 func TestGitHubOpenPullRequestBaseEmpty(t *testing.T) {
@@ -664,7 +893,7 @@ func TestGitHubOpenPullRequestBaseEmpty(t *testing.T) {
 		Branch: "main",
 	}
 
-	require.NoError(t, client.OpenPullRequest(ctx, repo, Repo{}, "some title", false))
+	require.NoError(t, client.OpenPullRequest(ctx, repo, Repo{}, "some title", PullRequestOptions{}))
 }
 
 func TestGitHubCreateFileHappyPathCreate(t *testing.T) {
@@ -712,6 +941,68 @@ func TestGitHubCreateFileHappyPathCreate(t *testing.T) {
 	require.NoError(t, client.CreateFile(ctx, config.CommitAuthor{}, repo, []byte("content"), "file.txt", "message"))
 }
 
+func TestGitHubCreateFileWithDate(t *testing.T) {
+	var created bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		if r.URL.Path == "/repos/someone/something" {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"default_branch": "main"}`)
+			return
+		}
+
+		if r.URL.Path == "/repos/someone/something/contents/file.txt" && r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.URL.Path == "/repos/someone/something/contents/file.txt" && r.Method == http.MethodPut {
+			var body struct {
+				Author    github.CommitAuthor `json:"author"`
+				Committer github.CommitAuthor `json:"committer"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			for _, author := range []github.CommitAuthor{body.Author, body.Committer} {
+				require.Equal(t, "foo", author.GetName())
+				require.Equal(t, "foo@bar", author.GetEmail())
+				require.Equal(t, time.Date(2023, 7, 10, 14, 40, 49, 0, time.UTC), author.GetDate().UTC())
+			}
+			created = true
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if r.URL.Path == "/rate_limit" {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"resources":{"core":{"remaining":120}}}`)
+			return
+		}
+
+		t.Error("unhandled request: " + r.URL.Path)
+	}))
+	defer srv.Close()
+
+	ctx := testctx.NewWithCfg(config.Project{
+		GitHubURLs: config.GitHubURLs{
+			API: srv.URL + "/",
+		},
+	})
+	client, err := newGitHub(ctx, "test-token")
+	require.NoError(t, err)
+	repo := Repo{
+		Owner: "someone",
+		Name:  "something",
+	}
+
+	require.NoError(t, client.CreateFile(ctx, config.CommitAuthor{
+		Name:  "foo",
+		Email: "foo@bar",
+		Date:  "2023-07-10T14:40:49Z",
+	}, repo, []byte("content"), "file.txt", "message"))
+	require.True(t, created)
+}
+
 func TestGitHubCreateFileHappyPathUpdate(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
@@ -858,3 +1149,49 @@ func TestGitHubCheckRateLimit(t *testing.T) {
 // TODO: test create upload file to release
 // TODO: test delete draft release
 // TODO: test create PR
+
+func TestGitHubGetFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		if r.URL.Path == "/repos/someone/something/contents/Formula/foo.rb" {
+			require.Equal(t, "main", r.URL.Query().Get("ref"))
+			fmt.Fprint(w, `{"type": "file", "encoding": "base64", "content": "Y2xhc3MgRm9v"}`)
+			return
+		}
+
+		if r.URL.Path == "/repos/someone/something/contents/Formula/bar.rb" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.URL.Path == "/rate_limit" {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"resources":{"core":{"remaining":120}}}`)
+			return
+		}
+
+		t.Error("unhandled request: " + r.URL.Path)
+	}))
+	defer srv.Close()
+
+	ctx := testctx.NewWithCfg(config.Project{
+		GitHubURLs: config.GitHubURLs{
+			API: srv.URL + "/",
+		},
+	})
+	client, err := newGitHub(ctx, "test-token")
+	require.NoError(t, err)
+	repo := Repo{
+		Owner:  "someone",
+		Name:   "something",
+		Branch: "main",
+	}
+
+	content, err := client.GetFile(ctx, repo, "Formula/foo.rb")
+	require.NoError(t, err)
+	require.Equal(t, "class Foo", string(content))
+
+	_, err = client.GetFile(ctx, repo, "Formula/bar.rb")
+	require.ErrorIs(t, err, ErrFileNotFound)
+}
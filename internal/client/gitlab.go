@@ -22,7 +22,10 @@ type gitlabClient struct {
 	client *gitlab.Client
 }
 
-var _ Client = &gitlabClient{}
+var (
+	_ Client     = &gitlabClient{}
+	_ FileGetter = &gitlabClient{}
+)
 
 // newGitLab returns a gitlab client implementation.
 func newGitLab(ctx *context.Context, token string) (*gitlabClient, error) {
@@ -128,6 +131,23 @@ func (c *gitlabClient) CloseMilestone(_ *context.Context, repo Repo, title strin
 	return err
 }
 
+// GetFile returns the contents of the file at the given path, in the given
+// branch or in the default one.
+func (c *gitlabClient) GetFile(_ *context.Context, repo Repo, path string) ([]byte, error) {
+	opts := &gitlab.GetRawFileOptions{}
+	if repo.Branch != "" {
+		opts.Ref = &repo.Branch
+	}
+	content, res, err := c.client.RepositoryFiles.GetRawFile(repo.String(), path, opts)
+	if res != nil && res.StatusCode == http.StatusNotFound {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get %q: %w", path, err)
+	}
+	return content, nil
+}
+
 // CreateFile gets a file in the repository at a given path
 // and updates if it exists or creates it for later pipes in the pipeline.
 func (c *gitlabClient) CreateFile(
@@ -141,6 +161,10 @@ func (c *gitlabClient) CreateFile(
 	fileName := path
 	projectID := repo.String()
 
+	if commitAuthor.Date != "" {
+		log.Warn("gitlab does not support setting the commit date, ignoring commit_author.date")
+	}
+
 	// Use the project default branch if we can get it...otherwise, just use
 	// 'master'
 	var branch, ref string
@@ -555,6 +555,42 @@ func TestGitLabCreateFile(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestGitLabGetFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		if strings.HasSuffix(r.URL.Path, "projects/someone/something/repository/files/Formula/foo.rb/raw") {
+			require.Equal(t, "somebranch", r.URL.Query().Get("ref"))
+			_, err := io.Copy(w, strings.NewReader("class Foo < Formula\nend\n"))
+			require.NoError(t, err)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	ctx := testctx.NewWithCfg(config.Project{
+		GitLabURLs: config.GitLabURLs{
+			API: srv.URL,
+		},
+	})
+
+	client, err := newGitLab(ctx, "test-token")
+	require.NoError(t, err)
+
+	repo := Repo{
+		Owner:  "someone",
+		Name:   "something",
+		Branch: "somebranch",
+	}
+
+	content, err := client.GetFile(ctx, repo, "Formula/foo.rb")
+	require.NoError(t, err)
+	require.Equal(t, "class Foo < Formula\nend\n", string(content))
+
+	_, err = client.GetFile(ctx, repo, "Formula/bar.rb")
+	require.ErrorIs(t, err, ErrFileNotFound)
+}
+
 func TestGitLabCloseMileston(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.HasSuffix(r.URL.Path, "projects/someone/something/milestones") {
@@ -16,6 +16,7 @@ var (
 	_ Client                = &Mock{}
 	_ ReleaseNotesGenerator = &Mock{}
 	_ PullRequestOpener     = &Mock{}
+	_ FileGetter            = &Mock{}
 )
 
 func NewMock() *Mock {
@@ -24,6 +25,7 @@ func NewMock() *Mock {
 
 type Mock struct {
 	CreatedFile          bool
+	CreatedFileRepo      Repo
 	Content              string
 	Path                 string
 	Messages             []string
@@ -41,10 +43,24 @@ type Mock struct {
 	ReleaseNotes         string
 	ReleaseNotesParams   []string
 	OpenedPullRequest    bool
+	PullRequestTitle     string
+	PullRequestOptions   PullRequestOptions
+	Files                map[string]string
+	CreateFileErrs       []error
 }
 
-func (c *Mock) OpenPullRequest(_ *context.Context, _, _ Repo, _ string, _ bool) error {
+func (c *Mock) GetFile(_ *context.Context, _ Repo, path string) ([]byte, error) {
+	content, ok := c.Files[path]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	return []byte(content), nil
+}
+
+func (c *Mock) OpenPullRequest(_ *context.Context, _, _ Repo, title string, opts PullRequestOptions) error {
 	c.OpenedPullRequest = true
+	c.PullRequestTitle = title
+	c.PullRequestOptions = opts
 	return nil
 }
 
@@ -85,10 +101,22 @@ func (c *Mock) ReleaseURLTemplate(_ *context.Context) (string, error) {
 	return "https://dummyhost/download/{{ .Tag }}/{{ .ArtifactName }}", nil
 }
 
-func (c *Mock) CreateFile(_ *context.Context, _ config.CommitAuthor, _ Repo, content []byte, path, msg string) error {
+func (c *Mock) CreateFile(_ *context.Context, _ config.CommitAuthor, repo Repo, content []byte, path, msg string) error {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	if len(c.CreateFileErrs) > 0 {
+		err := c.CreateFileErrs[0]
+		c.CreateFileErrs = c.CreateFileErrs[1:]
+		return err
+	}
 	c.CreatedFile = true
+	c.CreatedFileRepo = repo
 	c.Content = string(content)
 	c.Path = path
+	if c.Files == nil {
+		c.Files = map[string]string{}
+	}
+	c.Files[path] = string(content)
 	c.Messages = append(c.Messages, msg)
 	return nil
 }
@@ -2,6 +2,10 @@
 package commitauthor
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/goreleaser/goreleaser/internal/tmpl"
 	"github.com/goreleaser/goreleaser/pkg/config"
 	"github.com/goreleaser/goreleaser/pkg/context"
@@ -22,9 +26,36 @@ func Get(ctx *context.Context, og config.CommitAuthor) (config.CommitAuthor, err
 		return author, err
 	}
 	author.Email, err = tmpl.New(ctx).Apply(og.Email)
+	if err != nil {
+		return author, err
+	}
+	author.Signing.Key, err = tmpl.New(ctx).Apply(og.Signing.Key)
+	if err != nil {
+		return author, err
+	}
+	author.Signing.PassphraseEnv = og.Signing.PassphraseEnv
+	author.Date, err = date(ctx, og.Date)
 	return author, err
 }
 
+// date templates the given commit date, which can be either a RFC3339 date or
+// an unix timestamp, and returns it as RFC3339.
+// An empty date means the commit is dated when it's created.
+func date(ctx *context.Context, s string) (string, error) {
+	s, err := tmpl.New(ctx).Apply(s)
+	if err != nil || s == "" {
+		return s, err
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC().Format(time.RFC3339), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "", fmt.Errorf("commit_author.date: invalid value %q, must be either a RFC3339 date or an unix timestamp", s)
+	}
+	return t.Format(time.RFC3339), nil
+}
+
 // Default sets the default commit author name and email.
 func Default(og config.CommitAuthor) config.CommitAuthor {
 	if og.Name == "" {
@@ -2,6 +2,7 @@ package commitauthor
 
 import (
 	"testing"
+	"time"
 
 	"github.com/goreleaser/goreleaser/internal/testctx"
 	"github.com/goreleaser/goreleaser/pkg/config"
@@ -23,6 +24,37 @@ func TestGet(t *testing.T) {
 		}, author)
 	})
 
+	t.Run("date", func(t *testing.T) {
+		for date, expected := range map[string]string{
+			"{{ .CommitDate }}":         "2023-07-10T14:40:49Z",
+			"{{ .CommitTimestamp }}":    "2023-07-10T14:40:49Z",
+			"2023-07-10T16:40:49+02:00": "2023-07-10T16:40:49+02:00",
+		} {
+			t.Run(date, func(t *testing.T) {
+				author, err := Get(testctx.New(
+					testctx.WithCommitDate(time.Date(2023, 7, 10, 14, 40, 49, 0, time.UTC)),
+				), config.CommitAuthor{
+					Name:  "foo",
+					Email: "foo@bar",
+					Date:  date,
+				})
+				require.NoError(t, err)
+				require.Equal(t, expected, author.Date)
+			})
+		}
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		_, err := Get(
+			testctx.New(),
+			config.CommitAuthor{
+				Name:  "a",
+				Email: "a",
+				Date:  "yesterday",
+			})
+		require.EqualError(t, err, `commit_author.date: invalid value "yesterday", must be either a RFC3339 date or an unix timestamp`)
+	})
+
 	t.Run("invalid name tmpl", func(t *testing.T) {
 		_, err := Get(
 			testctx.New(),
@@ -33,6 +65,24 @@ func TestGet(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("signing", func(t *testing.T) {
+		author, err := Get(testctx.NewWithCfg(config.Project{
+			Env: []string{"GPG_KEY=/keys/bot.asc"},
+		}), config.CommitAuthor{
+			Name:  "foo",
+			Email: "foo@bar",
+			Signing: config.CommitSigning{
+				Key:           "{{ .Env.GPG_KEY }}",
+				PassphraseEnv: "GPG_PASSPHRASE",
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, config.CommitSigning{
+			Key:           "/keys/bot.asc",
+			PassphraseEnv: "GPG_PASSPHRASE",
+		}, author.Signing)
+	})
+
 	t.Run("invalid email tmpl", func(t *testing.T) {
 		_, err := Get(
 			testctx.New(),
@@ -25,7 +25,7 @@ import (
 )
 
 const (
-	defaultNameTemplateSuffix = `{{ .Version }}_{{ .Os }}_{{ .Arch }}{{ with .Arm }}v{{ . }}{{ end }}{{ with .Mips }}_{{ . }}{{ end }}{{ if not (eq .Amd64 "v1") }}{{ .Amd64 }}{{ end }}`
+	defaultNameTemplateSuffix = `{{ .Version }}_{{ .Os }}_{{ .Arch }}{{ with .Arm }}v{{ . }}{{ end }}{{ with .Mips }}_{{ . }}{{ end }}{{ if not (eq .Amd64 "v1") }}{{ .Amd64 }}{{ end }}{{ with .Arm64 }}{{ . }}{{ end }}`
 	defaultNameTemplate       = "{{ .ProjectName }}_" + defaultNameTemplateSuffix
 	defaultBinaryNameTemplate = "{{ .Binary }}_" + defaultNameTemplateSuffix
 )
@@ -231,6 +231,7 @@ func doCreate(ctx *context.Context, arch config.Archive, binaries []*artifact.Ar
 		art.Goarm = binaries[0].Goarm
 		art.Gomips = binaries[0].Gomips
 		art.Goamd64 = binaries[0].Goamd64
+		art.Goarm64 = binaries[0].Goarm64
 		art.Extra[artifact.ExtraReplaces] = binaries[0].Extra[artifact.ExtraReplaces]
 	}
 
@@ -268,6 +269,7 @@ func skip(ctx *context.Context, archive config.Archive, binaries []*artifact.Art
 			Goarm:   binary.Goarm,
 			Gomips:  binary.Gomips,
 			Goamd64: binary.Goamd64,
+			Goarm64: binary.Goarm64,
 			Extra: map[string]interface{}{
 				artifact.ExtraID:       archive.ID,
 				artifact.ExtraFormat:   archive.Format,
@@ -63,7 +63,7 @@ func TestRunPipe(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			dist := filepath.Join(folder, name+"_dist")
 			require.NoError(t, os.Mkdir(dist, 0o755))
-			for _, arch := range []string{"darwinamd64v1", "darwinall", "linux386", "linuxarm7", "linuxmipssoftfloat", "linuxamd64v3"} {
+			for _, arch := range []string{"darwinamd64v1", "darwinall", "linux386", "linuxarm7", "linuxmipssoftfloat", "linuxamd64v3", "linuxarm64v9.0"} {
 				createFakeBinary(t, dist, arch, "bin/mybin")
 			}
 			createFakeBinary(t, dist, "windowsamd64", "bin/mybin.exe")
@@ -188,6 +188,18 @@ func TestRunPipe(t *testing.T) {
 					artifact.ExtraID:     "default",
 				},
 			}
+			linuxArm64Build := &artifact.Artifact{
+				Goos:    "linux",
+				Goarch:  "arm64",
+				Goarm64: "v9.0",
+				Name:    "bin/mybin",
+				Path:    filepath.Join(dist, "linuxarm64v9.0", "bin", "mybin"),
+				Type:    artifact.Binary,
+				Extra: map[string]interface{}{
+					artifact.ExtraBinary: "mybin",
+					artifact.ExtraID:     "default",
+				},
+			}
 			ctx.Artifacts.Add(darwinBuild)
 			ctx.Artifacts.Add(darwinUniversalBinary)
 			ctx.Artifacts.Add(linux386Build)
@@ -195,6 +207,7 @@ func TestRunPipe(t *testing.T) {
 			ctx.Artifacts.Add(linuxMipsBuild)
 			ctx.Artifacts.Add(windowsBuild)
 			ctx.Artifacts.Add(linuxAmd64Build)
+			ctx.Artifacts.Add(linuxArm64Build)
 			ctx.Version = "0.0.1"
 			ctx.Git.CurrentTag = "v0.0.1"
 			ctx.Config.Archives[0].Format = format
@@ -210,7 +223,13 @@ func TestRunPipe(t *testing.T) {
 				require.Equal(t, []string{expectBin}, artifact.ExtraOr(*arch, artifact.ExtraBinaries, []string{}))
 				require.Equal(t, "", artifact.ExtraOr(*arch, artifact.ExtraBinary, ""))
 			}
-			require.Len(t, archives, 7)
+			require.Len(t, archives, 8)
+			arm64Archives := ctx.Artifacts.Filter(artifact.And(
+				artifact.ByType(artifact.UploadableArchive),
+				artifact.ByGoarch("arm64"),
+			)).List()
+			require.Len(t, arm64Archives, 1)
+			require.Equal(t, "v9.0", arm64Archives[0].Goarm64)
 			// TODO: should verify the artifact fields here too
 
 			expectBin := "bin/mybin"
@@ -227,6 +246,7 @@ func TestRunPipe(t *testing.T) {
 					"foobar_0.0.1_linux_armv7.tar.gz":          "linux",
 					"foobar_0.0.1_linux_mips_softfloat.tar.gz": "linux",
 					"foobar_0.0.1_linux_amd64v3.tar.gz":        "linux",
+					"foobar_0.0.1_linux_arm64v9.0.tar.gz":      "linux",
 				} {
 					require.Equal(
 						t,
@@ -0,0 +1,129 @@
+package brew
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/goreleaser/goreleaser/internal/tmpl"
+	"github.com/goreleaser/goreleaser/pkg/config"
+	"github.com/goreleaser/goreleaser/pkg/context"
+)
+
+var (
+	bottleTagRe    = regexp.MustCompile(`^[a-z0-9_]+$`)
+	bottleSHA256Re = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+// bottleManifest is the JSON written by `brew bottle --json`, keyed by the
+// formula name.
+type bottleManifest map[string]struct {
+	Bottle struct {
+		RootURL string `json:"root_url"`
+		Cellar  string `json:"cellar"`
+		Rebuild int    `json:"rebuild"`
+		Tags    map[string]struct {
+			Cellar string `json:"cellar"`
+			SHA256 string `json:"sha256"`
+		} `json:"tags"`
+	} `json:"bottle"`
+}
+
+// bottleFor reads the bottle block of the formula from brew.bottle_manifest.
+func bottleFor(ctx *context.Context, cfg config.Homebrew) (*bottle, error) {
+	path, err := tmpl.New(ctx).Apply(cfg.BottleManifest)
+	if err != nil {
+		return nil, err
+	}
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("bottle_manifest: %w", err)
+	}
+	var manifest bottleManifest
+	if err := json.Unmarshal(bts, &manifest); err != nil {
+		return nil, fmt.Errorf("bottle_manifest: invalid manifest %s: %w", path, err)
+	}
+
+	var name string
+	for key := range manifest {
+		if key == cfg.Name || strings.HasSuffix(key, "/"+cfg.Name) || len(manifest) == 1 {
+			name = key
+			break
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("bottle_manifest: no bottle found for %s in %s", cfg.Name, path)
+	}
+
+	entry := manifest[name].Bottle
+	if len(entry.Tags) == 0 {
+		return nil, fmt.Errorf("bottle_manifest: %s has no bottle tags", name)
+	}
+
+	result := &bottle{
+		RootURL: entry.RootURL,
+		Rebuild: entry.Rebuild,
+	}
+	for tag, sum := range entry.Tags {
+		if !bottleTagRe.MatchString(tag) {
+			return nil, fmt.Errorf("bottle_manifest: %s: invalid tag %q", name, tag)
+		}
+		if !bottleSHA256Re.MatchString(sum.SHA256) {
+			return nil, fmt.Errorf("bottle_manifest: %s: invalid sha256 for %s: %q", name, tag, sum.SHA256)
+		}
+		cellar := sum.Cellar
+		if cellar == "" {
+			cellar = entry.Cellar
+		}
+		result.Checksums = append(result.Checksums, bottleChecksum{
+			Cellar: cellarFor(cellar),
+			Tag:    tag,
+			SHA256: sum.SHA256,
+		})
+	}
+	sort.Slice(result.Checksums, func(i, j int) bool {
+		return result.Checksums[i].Tag < result.Checksums[j].Tag
+	})
+	return result, nil
+}
+
+// bottleFromConfig templates the bottle block of the formula from
+// brew.bottle.
+func bottleFromConfig(ctx *context.Context, cfg config.HomebrewBottle) (*bottle, error) {
+	result := &bottle{
+		RootURL: cfg.RootURL,
+		Rebuild: cfg.Rebuild,
+	}
+	if err := tmpl.New(ctx).ApplyAll(&result.RootURL); err != nil {
+		return nil, err
+	}
+	for _, sum := range cfg.Checksums {
+		if err := tmpl.New(ctx).ApplyAll(&sum.Tag, &sum.Cellar, &sum.SHA256); err != nil {
+			return nil, err
+		}
+		if !bottleTagRe.MatchString(sum.Tag) {
+			return nil, fmt.Errorf("bottle: invalid tag %q", sum.Tag)
+		}
+		if !bottleSHA256Re.MatchString(sum.SHA256) {
+			return nil, fmt.Errorf("bottle: invalid sha256 for %s: %q", sum.Tag, sum.SHA256)
+		}
+		result.Checksums = append(result.Checksums, bottleChecksum{
+			Cellar: cellarFor(sum.Cellar),
+			Tag:    sum.Tag,
+			SHA256: sum.SHA256,
+		})
+	}
+	return result, nil
+}
+
+// cellarFor normalizes the cellar, which brew writes without the leading `:`
+// when it is a symbol, e.g. `any_skip_relocation`.
+func cellarFor(s string) string {
+	if s == "" || strings.HasPrefix(s, "/") || strings.HasPrefix(s, ":") {
+		return s
+	}
+	return ":" + s
+}
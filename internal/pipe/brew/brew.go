@@ -3,29 +3,79 @@ package brew
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
+	"github.com/caarlos0/go-shellwords"
 	"github.com/caarlos0/log"
 	"github.com/goreleaser/goreleaser/internal/artifact"
 	"github.com/goreleaser/goreleaser/internal/client"
 	"github.com/goreleaser/goreleaser/internal/commitauthor"
 	"github.com/goreleaser/goreleaser/internal/deprecate"
+	"github.com/goreleaser/goreleaser/internal/extrafiles"
 	"github.com/goreleaser/goreleaser/internal/pipe"
+	"github.com/goreleaser/goreleaser/internal/semerrgroup"
+	"github.com/goreleaser/goreleaser/internal/shell"
 	"github.com/goreleaser/goreleaser/internal/tmpl"
 	"github.com/goreleaser/goreleaser/pkg/config"
 	"github.com/goreleaser/goreleaser/pkg/context"
+	"github.com/pmezard/go-difflib/difflib"
 )
 
 const brewConfigExtra = "BrewConfig"
 
+// ExtraRepository is the key of the BrewTap artifact extra holding the
+// Repository the formula will be published to.
+const ExtraRepository = "BrewRepository"
+
+// Keys of the BrewTap artifact extras holding the formula name, as in its
+// file name, its Ruby class name, and the version it installs.
+const (
+	ExtraFormulaName      = "FormulaName"
+	ExtraFormulaClassName = "FormulaClassName"
+	ExtraVersion          = "Version"
+)
+
+// Repository is where a formula will be published to.
+type Repository struct {
+	Owner  string `json:"owner,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Branch string `json:"branch,omitempty"`
+	GitURL string `json:"git_url,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// defaultGoarm64 is the base ARMv8 level, which is what Go targets when
+// GOARM64 is not set.
+const defaultGoarm64 = "v8.0"
+
+// placeholderChecksum is used instead of the real checksum on snapshots, if
+// allowed.
+const placeholderChecksum = "0000000000000000000000000000000000000000000000000000000000000000"
+
+const (
+	quoteDouble = "double"
+	quoteSingle = "single"
+)
+
+// rubyConstantRe matches valid Ruby constant names.
+var rubyConstantRe = regexp.MustCompile(`^[A-Z][A-Za-z0-9_]*$`)
+
 // ErrMultipleArchivesSameOS happens when the config yields multiple archives
 // for linux or windows.
 var ErrMultipleArchivesSameOS = errors.New("one tap can handle only one archive of an OS/Arch combination. Consider using ids in the brew section")
@@ -34,11 +84,22 @@ var ErrMultipleArchivesSameOS = errors.New("one tap can handle only one archive
 type ErrNoArchivesFound struct {
 	goarm   string
 	goamd64 string
+	goarm64 string
 	ids     []string
 }
 
 func (e ErrNoArchivesFound) Error() string {
-	return fmt.Sprintf("no linux/macos archives found matching goos=[darwin linux] goarch=[amd64 arm64 arm] goamd64=%s goarm=%s ids=%v", e.goamd64, e.goarm, e.ids)
+	return fmt.Sprintf("no linux/macos archives found matching goos=[darwin linux] goarch=[amd64 arm64 arm] goamd64=%s goarm64=%s goarm=%s ids=%v", e.goamd64, e.goarm64, e.goarm, e.ids)
+}
+
+// ErrMissingRepository happens when the repository owner or name are empty
+// after templating.
+type ErrMissingRepository struct {
+	field string
+}
+
+func (e ErrMissingRepository) Error() string {
+	return fmt.Sprintf("brew.repository.%s is empty, check your configuration and its templates", e.field)
 }
 
 // Pipe for brew deployment.
@@ -66,19 +127,59 @@ func (Pipe) Default(ctx *context.Context) error {
 		if brew.Goamd64 == "" {
 			brew.Goamd64 = "v1"
 		}
+		if brew.Goarm64 == "" {
+			brew.Goarm64 = defaultGoarm64
+		}
 		if brew.Plist != "" {
 			deprecate.Notice(ctx, "brews.plist")
 		}
+		if brew.Service.Raw != "" {
+			deprecate.NoticeCustom(ctx, "brews.service", "`{{ .Property }}` should be a map instead of a string, check {{ .URL }} for more info")
+		}
 		if !reflect.DeepEqual(brew.Tap, config.RepoRef{}) {
 			brew.Repository = brew.Tap
 			deprecate.Notice(ctx, "brews.tap")
 		}
+		if brew.QuoteStyle == "" {
+			brew.QuoteStyle = quoteDouble
+		}
+		if brew.ChecksumAlgorithm == "" {
+			brew.ChecksumAlgorithm = "sha256"
+		}
+		if brew.Install != "" && brew.ExtraInstall != "" {
+			log.WithField("brew", brew.Name).
+				Info("brews.install is set, binaries are not guessed: brews.extra_install is rendered after it")
+		}
+		if err := brew.Validate(); err != nil {
+			return fmt.Errorf("brews[%d]: %w", i, err)
+		}
+		if brew.License != "" {
+			license, err := normalizeLicense(brew.License)
+			if err != nil && brew.StrictLicense {
+				return fmt.Errorf("brews[%d]: license: %w", i, err)
+			}
+			if err != nil {
+				log.WithField("brew", brew.Name).WithError(err).
+					Warn("brews.license is not a known SPDX expression, using it as is")
+			} else {
+				brew.License = license
+			}
+		}
 	}
 
 	return nil
 }
 
 func (Pipe) Run(ctx *context.Context) error {
+	// validate only runs don't need a client able to reach the APIs.
+	if ctx.BrewValidateOnly {
+		cl, err := client.NewReleaseURLTemplater(ctx)
+		if err != nil {
+			return err
+		}
+		return validateAll(ctx, cl, nil)
+	}
+
 	cli, err := client.New(ctx)
 	if err != nil {
 		return err
@@ -97,32 +198,195 @@ func (Pipe) Publish(ctx *context.Context) error {
 }
 
 func runAll(ctx *context.Context, cli client.Client) error {
-	for _, brew := range ctx.Config.Brews {
-		err := doRun(ctx, brew, cli)
-		if err != nil {
+	// dry runs only generate and validate the formulas.
+	if ctx.BrewDryRun {
+		return validateAll(ctx, cli, func(brew config.Homebrew, content string) error {
+			gpath := buildFormulaPath(brew.Folder, formulaFileName(brew))
+			return printDiff(ctx, brew, cli, gpath, content)
+		})
+	}
+
+	// formulas are generated concurrently, but once one of them fails the
+	// remaining ones are not started.
+	var lock sync.Mutex
+	var failed bool
+	g := semerrgroup.NewSkipAware(semerrgroup.New(ctx.Parallelism))
+	for _, brew := range formulasFor(ctx.Config.Brews) {
+		brew := brew
+		g.Go(func() error {
+			lock.Lock()
+			stop := failed
+			lock.Unlock()
+			if stop {
+				return nil
+			}
+
+			err := doRun(ctx, brew, cli)
+			if err != nil && !pipe.IsSkip(err) {
+				lock.Lock()
+				failed = true
+				lock.Unlock()
+			}
 			return err
+		})
+	}
+	return g.Wait()
+}
+
+// validateAll generates all the formulas and runs all the validations of the
+// pipe, without writing nor publishing anything, so the configuration can be
+// checked before being merged, e.g. in CI.
+// All the problems found are returned together.
+// If set, check is called with each generated formula, e.g. to print its diff
+// against the published one on dry runs.
+func validateAll(ctx *context.Context, cl client.ReleaserURLTemplater, check func(brew config.Homebrew, content string) error) error {
+	var errs []error
+	for i, brew := range ctx.Config.Brews {
+		if err := brew.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("brews[%d]: %w", i, err))
+			continue
+		}
+		for _, formula := range formulasFor([]config.Homebrew{brew}) {
+			generated, content, err := generateFormula(ctx, formula, cl)
+			if pipe.IsSkip(err) {
+				continue
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("brews[%d]: %w", i, err))
+				continue
+			}
+			if _, err := extraFilesFor(ctx, generated); err != nil {
+				errs = append(errs, fmt.Errorf("brews[%d]: %w", i, err))
+				continue
+			}
+			if check == nil {
+				continue
+			}
+			if err := check(generated, content); err != nil {
+				errs = append(errs, fmt.Errorf("brews[%d]: %w", i, err))
+			}
 		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// formulasFor returns the configuration of each formula to generate: brews
+// using from_archive are replaced by one formula per name, each installing
+// only its own binaries from the same archives.
+func formulasFor(brews []config.Homebrew) []config.Homebrew {
+	var result []config.Homebrew
+	for _, brew := range brews {
+		if len(brew.FromArchive) == 0 {
+			result = append(result, brew)
+			continue
+		}
+		names := keys(brew.FromArchive)
+		sort.Strings(names)
+		for _, name := range names {
+			formula := brew
+			formula.Name = name
+			formula.FromArchive = map[string][]string{name: brew.FromArchive[name]}
+			result = append(result, formula)
+		}
+	}
+	return result
+}
+
+// fromArchiveBinaries returns the binaries the formula installs from
+// the archive, and whether it is restricted to them at all.
+func fromArchiveBinaries(cfg config.Homebrew) (map[string]bool, bool) {
+	bins, ok := cfg.FromArchive[cfg.Name]
+	if !ok {
+		return nil, false
+	}
+	result := map[string]bool{}
+	for _, bin := range bins {
+		result[bin] = true
+	}
+	return result, true
 }
 
 func publishAll(ctx *context.Context, cli client.Client) error {
 	// even if one of them skips, we run them all, and then show return the skips all at once.
 	// this is needed so we actually create the `dist/foo.rb` file, which is useful for debugging.
 	skips := pipe.SkipMemento{}
-	for _, formula := range ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List() {
-		err := doPublish(ctx, formula, cli)
-		if err != nil && pipe.IsSkip(err) {
-			skips.Remember(err)
-			continue
-		}
-		if err != nil {
-			return err
-		}
+	var lock sync.Mutex
+	var failed bool
+	formulas := ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List()
+	g := semerrgroup.New(publishConcurrency(ctx.Config.Brews))
+	for i, formula := range formulas {
+		i, formula := i, formula
+		g.Go(func() error {
+			// stop publishing the remaining formulas once one of them fails.
+			lock.Lock()
+			stop := failed
+			lock.Unlock()
+			if stop {
+				return nil
+			}
+
+			err := doPublish(ctx, formula, cli)
+			if err != nil && pipe.IsSkip(err) {
+				lock.Lock()
+				skips.Remember(err)
+				lock.Unlock()
+				return nil
+			}
+			if err != nil {
+				lock.Lock()
+				failed = true
+				lock.Unlock()
+				return err
+			}
+			if i == len(formulas)-1 {
+				return nil
+			}
+			return waitPublishDelay(formula)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
 	}
 	return skips.Evaluate()
 }
 
+// publishConcurrency returns how many formulas can be published at the same
+// time.
+// As all formulas are published together, the lowest value set in any of the
+// brews is used, defaulting to publishing them one by one.
+func publishConcurrency(brews []config.Homebrew) int {
+	limit := 0
+	for _, brew := range brews {
+		if brew.PublishConcurrency > 0 && (limit == 0 || brew.PublishConcurrency < limit) {
+			limit = brew.PublishConcurrency
+		}
+	}
+	if limit == 0 {
+		return 1
+	}
+	return limit
+}
+
+// waitPublishDelay waits for the brew.publish_delay of the given formula.
+// The delay only applies to API-based clients, as pushing to a git
+// repository is not rate limited.
+func waitPublishDelay(formula *artifact.Artifact) error {
+	brew, err := artifact.Extra[config.Homebrew](*formula, brewConfigExtra)
+	if err != nil {
+		return err
+	}
+	if brew.PublishDelay == "" || brew.Repository.Git.URL != "" {
+		return nil
+	}
+	delay, err := time.ParseDuration(brew.PublishDelay)
+	if err != nil {
+		return err
+	}
+	log.WithField("delay", delay).Debug("waiting before publishing the next formula")
+	time.Sleep(delay)
+	return nil
+}
+
 func doPublish(ctx *context.Context, formula *artifact.Artifact, cl client.Client) error {
 	brew, err := artifact.Extra[config.Homebrew](*formula, brewConfigExtra)
 	if err != nil {
@@ -137,11 +401,19 @@ func doPublish(ctx *context.Context, formula *artifact.Artifact, cl client.Clien
 		return pipe.Skip("prerelease detected with 'auto' upload, skipping homebrew publish")
 	}
 
-	repo := client.RepoFromRef(brew.Repository)
+	if brew.DryRun {
+		log.WithField("formula", formula.Name).Warn("brew.dry_run is set, the formula is not published")
+		return pipe.Skip("brew.dry_run is set")
+	}
 
 	gpath := buildFormulaPath(brew.Folder, formula.Name)
 
-	msg, err := tmpl.New(ctx).Apply(brew.CommitMessageTemplate)
+	content, err := os.ReadFile(formula.Path)
+	if err != nil {
+		return err
+	}
+
+	msg, err := commitMessageFor(ctx, brew, content)
 	if err != nil {
 		return err
 	}
@@ -151,23 +423,170 @@ func doPublish(ctx *context.Context, formula *artifact.Artifact, cl client.Clien
 		return err
 	}
 
-	content, err := os.ReadFile(formula.Path)
+	files := []client.RepoFile{{
+		Content: content,
+		Path:    gpath,
+	}}
+	if brew.SharedStrategyFile != "" {
+		spath := sharedStrategyPath(brew.SharedStrategyFile)
+		content, err := os.ReadFile(filepath.Join(ctx.Config.Dist, "homebrew", spath))
+		if err != nil {
+			return err
+		}
+		// the shared file goes first, so the formula is never pushed without
+		// the strategy it requires.
+		files = append([]client.RepoFile{{
+			Content: content,
+			Path:    spath,
+		}}, files...)
+	}
+
+	extraFiles, err := extraFilesFor(ctx, brew)
+	if err != nil {
+		return err
+	}
+	files = append(files, extraFiles...)
+
+	// the formula is only skipped if it is skipped in every repository.
+	repos := repositoriesFor(brew)
+	var published []string
+	for _, ref := range repos {
+		err = publishTo(ctx, cl, brew, ref, author, msg, gpath, content, files)
+		if pipe.IsSkip(err) {
+			log.WithField("repository", ref.Name).Info(err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		published = append(published, repoName(ref))
+	}
+	if len(published) == 0 {
+		return err
+	}
+	return runPostPublish(ctx, brew, published)
+}
+
+// repoName returns the name of the given repository, as owner/name, or its
+// Git URL if it has no owner and name.
+func repoName(ref config.RepoRef) string {
+	repo := client.RepoFromRef(ref)
+	if name := repo.String(); name != "" {
+		return name
+	}
+	return repo.GitURL
+}
+
+// runPostPublish runs the brew.post_publish command, if any, with the formula
+// name, version and the repositories it was published to in its environment.
+func runPostPublish(ctx *context.Context, brew config.Homebrew, repos []string) error {
+	if brew.PostPublish == "" {
+		return nil
+	}
+
+	env := append(ctx.Env.Strings(),
+		"FORMULA_NAME="+brew.Name,
+		"FORMULA_VERSION="+formulaVersion(ctx.Version, brew.StripBuildMetadata),
+		"FORMULA_REPOSITORY="+strings.Join(repos, ","),
+	)
+	return runCommand(ctx, "post publish hook", brew.PostPublish, env)
+}
+
+// runCommand templates and runs the given command with the given environment.
+func runCommand(ctx *context.Context, name, command string, env []string) error {
+	sh, err := tmpl.New(ctx).WithEnvS(env).Apply(command)
 	if err != nil {
 		return err
 	}
 
-	if brew.Repository.Git.URL != "" {
-		return client.NewGitUploadClient(repo.Branch).
-			CreateFile(ctx, author, repo, content, gpath, msg)
+	log.WithField("cmd", sh).Info("running " + name)
+	cmd, err := shellwords.Parse(sh)
+	if err != nil {
+		return err
+	}
+	if err := shell.Run(ctx, "", cmd, env, false); err != nil {
+		return fmt.Errorf("brew: %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// commitMessageFor returns the commit message of the formula, with the body
+// after a blank line, if any.
+func commitMessageFor(ctx *context.Context, brew config.Homebrew, content []byte) (string, error) {
+	t := tmpl.New(ctx).WithExtraFields(tmpl.Fields{
+		"FormulaSHA": formulaSHA(content),
+	})
+	msg, err := t.Apply(brew.CommitMessageTemplate)
+	if err != nil {
+		return "", err
+	}
+	body, err := t.Apply(brew.CommitBodyTemplate)
+	if err != nil {
+		return "", err
+	}
+	if body = strings.TrimSpace(body); body != "" {
+		msg += "\n\n" + body
+	}
+	return msg, nil
+}
+
+// publishTo publishes the formula files to a single repository, using its
+// own token, branch and pull request settings.
+func publishTo(ctx *context.Context, cl client.Client, brew config.Homebrew, ref config.RepoRef, author config.CommitAuthor, msg, gpath string, content []byte, files []client.RepoFile) error {
+	repo := repoFor(ref)
+
+	if ref.Git.URL != "" {
+		gcl := client.NewGitUploadClient(repo.Branch)
+		if ref.CommitBranch != "" {
+			gcl = client.NewGitUploadClientForBranch(ref.CommitBranch)
+		}
+		// git uploads are not retried, their errors are never transient.
+		return gcl.CreateFiles(ctx, author, repo, msg, files)
+	}
+
+	// prereleases are committed directly to the pull request base instead of
+	// its head with brew.pull_request_only_stable.
+	direct := ref.PullRequest.Enabled &&
+		brew.PullRequestOnlyStable && ctx.Semver.Prerelease != ""
+	if direct {
+		log.Info("prerelease detected with brews.pull_request_only_stable, committing directly")
+		repo = pullRequestBase(ref)
 	}
 
-	cl, err = client.NewIfToken(ctx, cl, brew.Repository.Token)
+	// opening a pull request needs a token, either the repository's one or
+	// the default one.
+	openPR := ref.PullRequest.Enabled && !direct
+	cl, err := client.NewForRepository(ctx, cl, ref, openPR)
 	if err != nil {
 		return err
 	}
 
-	if !brew.Repository.PullRequest.Enabled {
-		return cl.CreateFile(ctx, author, repo, content, gpath, msg)
+	if brew.SkipIfUnchanged {
+		unchanged, err := formulaUnchanged(ctx, cl, repo, gpath, content)
+		if err != nil {
+			return err
+		}
+		if unchanged {
+			return pipe.Skip("formula unchanged")
+		}
+	}
+
+	if brew.OnlyNewerVersion {
+		published, newer, err := formulaIsNewer(ctx, cl, repo, gpath)
+		if err != nil {
+			return err
+		}
+		if !newer {
+			return pipe.Skip(fmt.Sprintf("formula version %s is not newer than the published %s", ctx.Version, published))
+		}
+	}
+
+	create := func() error {
+		return createFiles(ctx, cl, author, repo, msg, files)
+	}
+
+	if !openPR {
+		return withRetries(brew, create)
 	}
 
 	log.Info("brews.pull_request enabled, creating a PR")
@@ -176,20 +595,129 @@ func doPublish(ctx *context.Context, formula *artifact.Artifact, cl client.Clien
 		return fmt.Errorf("client does not support pull requests")
 	}
 
-	if err := cl.CreateFile(ctx, author, repo, content, gpath, msg); err != nil {
+	if err := withRetries(brew, create); err != nil {
 		return err
 	}
 
-	return pcl.OpenPullRequest(ctx, client.Repo{
-		Name:   brew.Repository.PullRequest.Base.Name,
-		Owner:  brew.Repository.PullRequest.Base.Owner,
-		Branch: brew.Repository.PullRequest.Base.Branch,
-	}, repo, msg, brew.Repository.PullRequest.Draft)
+	// the pull request title is only the subject of the commit message.
+	title, _, _ := strings.Cut(msg, "\n")
+	return withRetries(brew, func() error {
+		return pcl.OpenPullRequest(ctx, client.Repo{
+			Name:   ref.PullRequest.Base.Name,
+			Owner:  ref.PullRequest.Base.Owner,
+			Branch: ref.PullRequest.Base.Branch,
+		}, repo, title, client.PullRequestOptions{
+			Draft:     ref.PullRequest.Draft,
+			Milestone: ref.PullRequest.Milestone,
+			Reviewers: ref.PullRequest.Reviewers,
+			Labels:    ref.PullRequest.Labels,
+		})
+	})
+}
+
+// defaultRetryDelay is the delay before the first retry when
+// brew.publish_retry_delay is not set.
+const defaultRetryDelay = time.Second
+
+// withRetries runs fn, retrying it up to brew.publish_retries times while it
+// fails with a transient error.
+// The delay between tries grows linearly with each try.
+func withRetries(brew config.Homebrew, fn func() error) error {
+	delay := defaultRetryDelay
+	if brew.PublishRetryDelay != "" {
+		d, err := time.ParseDuration(brew.PublishRetryDelay)
+		if err != nil {
+			return err
+		}
+		delay = d
+	}
+
+	for try := 0; ; try++ {
+		err := fn()
+		if err == nil || pipe.IsSkip(err) || !client.IsRetriable(err) || try >= brew.PublishRetries {
+			return err
+		}
+		log.WithField("try", try+1).
+			WithError(err).
+			Warn("failed to publish formula, will retry")
+		time.Sleep(time.Duration(try+1) * delay)
+	}
+}
+
+// formulaUnchanged tells whether the formula in the repository is the same as
+// the one we just generated.
+// A formula that is not in the repository yet is always considered changed.
+func formulaUnchanged(ctx *context.Context, cl client.Client, repo client.Repo, gpath string, content []byte) (bool, error) {
+	getter, ok := cl.(client.FileGetter)
+	if !ok {
+		log.Warn("client does not support getting files, ignoring brew.skip_if_unchanged")
+		return false, nil
+	}
+	current, err := getter.GetFile(ctx, repo, gpath)
+	if errors.Is(err, client.ErrFileNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(current, content), nil
+}
+
+// formulaVersionRe matches the version line of a formula.
+var formulaVersionRe = regexp.MustCompile(`(?m)^\s*version\s+["']([^"']+)["']`)
+
+// formulaIsNewer tells whether the version being released is newer than the
+// one of the formula in the repository, which is also returned.
+// A formula that is not in the repository yet, or whose version can't be
+// parsed, is always considered older.
+func formulaIsNewer(ctx *context.Context, cl client.Client, repo client.Repo, gpath string) (string, bool, error) {
+	getter, ok := cl.(client.FileGetter)
+	if !ok {
+		log.Warn("client does not support getting files, ignoring brew.only_newer_version")
+		return "", true, nil
+	}
+	current, err := getter.GetFile(ctx, repo, gpath)
+	if errors.Is(err, client.ErrFileNotFound) {
+		return "", true, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	match := formulaVersionRe.FindSubmatch(current)
+	if match == nil {
+		log.WithField("formula", gpath).Warn("could not find the published formula version")
+		return "", true, nil
+	}
+	published, err := semver.NewVersion(string(match[1]))
+	if err != nil {
+		log.WithField("formula", gpath).
+			WithError(err).
+			Warn("could not parse the published formula version")
+		return string(match[1]), true, nil
+	}
+	version := semver.New(ctx.Semver.Major, ctx.Semver.Minor, ctx.Semver.Patch, ctx.Semver.Prerelease, "")
+	return published.Original(), version.GreaterThan(published), nil
+}
+
+// createFiles creates all the given files in a single commit if the client
+// supports it, or one by one otherwise.
+func createFiles(ctx *context.Context, cl client.FileCreator, author config.CommitAuthor, repo client.Repo, msg string, files []client.RepoFile) error {
+	if fc, ok := cl.(client.FilesCreator); ok {
+		return fc.CreateFiles(ctx, author, repo, msg, files)
+	}
+	for _, file := range files {
+		if err := cl.CreateFile(ctx, author, repo, file.Content, file.Path, msg); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func doRun(ctx *context.Context, brew config.Homebrew, cl client.ReleaserURLTemplater) error {
-	if brew.Repository.Name == "" {
-		return pipe.Skip("brew.repository.name is not set")
+// generateFormula templates the brew configuration and builds its formula,
+// running all the validations that don't need to write nor publish anything.
+func generateFormula(ctx *context.Context, brew config.Homebrew, cl client.ReleaserURLTemplater) (config.Homebrew, string, error) {
+	if len(repositoriesFor(brew)) == 0 {
+		return brew, "", pipe.Skip("brew.repository.name is not set")
 	}
 
 	filters := []artifact.Filter{
@@ -199,19 +727,7 @@ func doRun(ctx *context.Context, brew config.Homebrew, cl client.ReleaserURLTemp
 		),
 		artifact.Or(
 			artifact.And(
-				artifact.ByGoarch("amd64"),
-				artifact.ByGoamd64(brew.Goamd64),
-			),
-			artifact.ByGoarch("arm64"),
-			artifact.ByGoarch("all"),
-			artifact.And(
-				artifact.ByGoarch("arm"),
-				artifact.ByGoarm(brew.Goarm),
-			),
-		),
-		artifact.Or(
-			artifact.And(
-				artifact.ByFormats("zip", "tar.gz"),
+				artifact.ByFormats("zip", "tar.gz", "tar.xz", "tar.bz2"),
 				artifact.ByType(artifact.UploadableArchive),
 			),
 			artifact.ByType(artifact.UploadableBinary),
@@ -221,11 +737,33 @@ func doRun(ctx *context.Context, brew config.Homebrew, cl client.ReleaserURLTemp
 	if len(brew.IDs) > 0 {
 		filters = append(filters, artifact.ByIDs(brew.IDs...))
 	}
+	if brew.ExcludeNameRegex != "" {
+		filters = append(filters, excludeByName(brew.ExcludeNameRegex))
+	}
+	if brew.Goamd64Fallback {
+		brew.Goamd64 = goamd64Fallback(ctx, brew.Goamd64, artifact.And(filters...))
+	}
+	filters = append(filters, artifact.Or(
+		artifact.And(
+			artifact.ByGoarch("amd64"),
+			artifact.ByGoamd64(brew.Goamd64),
+		),
+		artifact.And(
+			artifact.ByGoarch("arm64"),
+			byGoarm64(brew.Goarm64),
+		),
+		artifact.ByGoarch("all"),
+		artifact.And(
+			artifact.ByGoarch("arm"),
+			artifact.ByGoarm(brew.Goarm),
+		),
+	))
 
 	archives := ctx.Artifacts.Filter(artifact.And(filters...)).List()
-	if len(archives) == 0 {
-		return ErrNoArchivesFound{
+	if len(archives) == 0 && !brew.SourceBuild {
+		return brew, "", ErrNoArchivesFound{
 			goamd64: brew.Goamd64,
+			goarm64: brew.Goarm64,
 			goarm:   brew.Goarm,
 			ids:     brew.IDs,
 		}
@@ -233,28 +771,110 @@ func doRun(ctx *context.Context, brew config.Homebrew, cl client.ReleaserURLTemp
 
 	name, err := tmpl.New(ctx).Apply(brew.Name)
 	if err != nil {
-		return err
+		return brew, "", err
+	}
+	if brew.VersionedName {
+		// both the file and the class names derive from it, e.g. foo@2.rb
+		// and FooAT2.
+		name = fmt.Sprintf("%s@%d", name, ctx.Semver.Major)
 	}
 	brew.Name = name
 
-	ref, err := client.TemplateRef(tmpl.New(ctx).Apply, brew.Repository)
-	if err != nil {
-		return err
+	if brew.Repository.Name != "" {
+		ref, err := templateRef(ctx, brew.Repository)
+		if err != nil {
+			return brew, "", err
+		}
+		brew.Repository = ref
+
+		if err := validateRepository(brew.Repository); err != nil {
+			return brew, "", err
+		}
+	}
+
+	repos := make([]config.RepoRef, 0, len(brew.Repositories))
+	for _, repo := range brew.Repositories {
+		ref, err := templateRef(ctx, repo)
+		if err != nil {
+			return brew, "", err
+		}
+		if err := validateRepository(ref); err != nil {
+			return brew, "", err
+		}
+		repos = append(repos, ref)
+	}
+	brew.Repositories = repos
+
+	for _, skip := range []*string{&brew.SkipUpload, &brew.SkipUploadMacOS, &brew.SkipUploadLinux} {
+		value, err := tmpl.New(ctx).Apply(*skip)
+		if err != nil {
+			return brew, "", err
+		}
+		*skip = value
 	}
-	brew.Repository = ref
 
-	skipUpload, err := tmpl.New(ctx).Apply(brew.SkipUpload)
+	headURL, err := tmpl.New(ctx).Apply(brew.Head.URL)
 	if err != nil {
-		return err
+		return brew, "", err
+	}
+	brew.Head.URL = headURL
+
+	if err := tmpl.New(ctx).ApplyAll(&brew.URLUsing); err != nil {
+		return brew, "", err
 	}
-	brew.SkipUpload = skipUpload
+	headers := make([]string, 0, len(brew.URLHeaders))
+	for _, header := range brew.URLHeaders {
+		header, err := tmpl.New(ctx).Apply(header)
+		if err != nil {
+			return brew, "", err
+		}
+		headers = append(headers, header)
+	}
+	brew.URLHeaders = headers
 
 	content, err := buildFormula(ctx, brew, cl, archives)
+	if err != nil {
+		return brew, "", err
+	}
+
+	// the folder might use the formula hash, e.g. for content-addressed taps,
+	// so it can only be templated once the formula is built.
+	folder, err := tmpl.New(ctx).WithExtraFields(tmpl.Fields{
+		"FormulaSHA": formulaSHA([]byte(content)),
+	}).Apply(brew.Folder)
+	if err != nil {
+		return brew, "", err
+	}
+	brew.Folder = folder
+
+	return brew, content, nil
+}
+
+// formulaVersion returns the version of the formula, without the SemVer build
+// metadata unless brew.strip_build_metadata is disabled, as Homebrew does not
+// handle it well.
+// Prerelease identifiers are kept.
+func formulaVersion(version string, strip *bool) string {
+	if strip != nil && !*strip {
+		return version
+	}
+	version, _, _ = strings.Cut(version, "+")
+	return version
+}
+
+// formulaSHA returns the sha256 hash of the formula content.
+func formulaSHA(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func doRun(ctx *context.Context, brew config.Homebrew, cl client.Client) error {
+	brew, content, err := generateFormula(ctx, brew, cl)
 	if err != nil {
 		return err
 	}
 
-	filename := brew.Name + ".rb"
+	filename := formulaFileName(brew)
 	path := filepath.Join(ctx.Config.Dist, "homebrew", brew.Folder, filename)
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
@@ -265,34 +885,400 @@ func doRun(ctx *context.Context, brew config.Homebrew, cl client.ReleaserURLTemp
 		return fmt.Errorf("failed to write brew formula: %w", err)
 	}
 
-	ctx.Artifacts.Add(&artifact.Artifact{
-		Name: filename,
-		Path: path,
-		Type: artifact.BrewTap,
-		Extra: map[string]interface{}{
-			brewConfigExtra: brew,
-		},
-	})
-
-	return nil
-}
+	if brew.FormatCommand != "" {
+		// the formatted formula is the one previewed and published.
+		content, err = formatFormula(ctx, brew, path)
+		if err != nil {
+			return err
+		}
+	}
 
-func buildFormulaPath(folder, filename string) string {
+	if brew.Preview {
+		preview := filepath.Join(ctx.Config.Dist, "homebrew-preview", brew.Folder, filename)
+		if err := os.MkdirAll(filepath.Dir(preview), 0o755); err != nil {
+			return err
+		}
+		log.WithField("formula", preview).Info("writing preview")
+		if err := os.WriteFile(preview, []byte(content), 0o644); err != nil { //nolint: gosec
+			return fmt.Errorf("failed to write brew formula preview: %w", err)
+		}
+	}
+
+	if brew.DryRun {
+		if err := printDiff(ctx, brew, cl, buildFormulaPath(brew.Folder, filename), content); err != nil {
+			return err
+		}
+	}
+
+	if brew.SharedStrategyFile != "" {
+		if err := writeSharedStrategy(ctx, brew.SharedStrategyFile); err != nil {
+			return err
+		}
+	}
+
+	// fail early if any of the extra files is missing.
+	if _, err := extraFilesFor(ctx, brew); err != nil {
+		return err
+	}
+
+	repo := firstRepository(brew)
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name: filename,
+		Path: path,
+		Type: artifact.BrewTap,
+		Extra: map[string]interface{}{
+			brewConfigExtra:       brew,
+			ExtraFormulaName:      brew.Name,
+			ExtraFormulaClassName: classNameFor(brew),
+			ExtraVersion:          formulaVersion(ctx.Version, brew.StripBuildMetadata),
+			ExtraRepository: Repository{
+				Owner:  repo.Owner,
+				Name:   repo.Name,
+				Branch: repo.Branch,
+				GitURL: repo.Git.URL,
+				Path:   buildFormulaPath(brew.Folder, filename),
+			},
+		},
+	})
+
+	return nil
+}
+
+// formulaFileName returns the name of the formula file.
+func formulaFileName(brew config.Homebrew) string {
+	filename := brew.Name + ".rb"
+	if brew.LowercaseFileName {
+		return strings.ToLower(filename)
+	}
+	return filename
+}
+
+// diffOutput is where brew.dry_run writes its diffs to.
+// Formulas are generated concurrently, so writes to it must hold diffLock.
+var (
+	diffOutput io.Writer = os.Stdout
+	diffLock   sync.Mutex
+)
+
+// printDiff prints an unified diff between the formula currently in the
+// repository and the one we just generated.
+// If there is no formula in the repository yet, the whole new file is shown.
+// With multiple repositories, the first one is used.
+func printDiff(ctx *context.Context, brew config.Homebrew, cl client.Client, gpath, content string) error {
+	ref := firstRepository(brew)
+	if ref.Git.URL != "" {
+		log.Warn("brew.dry_run diffs are not supported with git repositories, skipping")
+		return nil
+	}
+
+	cl, err := client.NewForRepository(ctx, cl, ref, false)
+	if err != nil {
+		return err
+	}
+
+	getter, ok := cl.(client.FileGetter)
+	if !ok {
+		log.Warn("client does not support getting files, skipping the brew.dry_run diff")
+		return nil
+	}
+
+	current, err := getter.GetFile(ctx, repoFor(ref), gpath)
+	if err != nil && !errors.Is(err, client.ErrFileNotFound) {
+		return fmt.Errorf("could not get current formula: %w", err)
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(current)),
+		B:        splitLines(content),
+		FromFile: "a/" + gpath,
+		ToFile:   "b/" + gpath,
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+
+	if diff == "" {
+		log.WithField("formula", gpath).Info("no changes")
+		return nil
+	}
+
+	diffLock.Lock()
+	defer diffLock.Unlock()
+	_, err = fmt.Fprint(diffOutput, diff)
+	return err
+}
+
+// splitLines splits s into lines, keeping the line endings.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// excludeByName filters out the artifacts whose names match the given
+// regular expression, which is validated in the defaults.
+func excludeByName(expr string) artifact.Filter {
+	re := regexp.MustCompile(expr)
+	return func(a *artifact.Artifact) bool {
+		return !re.MatchString(a.Name)
+	}
+}
+
+// goamd64Fallback returns the goamd64 level to use: the given one, if there
+// are amd64 artifacts matching the filter built for it, or the highest
+// lower level available otherwise.
+// If there is no lower level either, the given one is returned, so the
+// formula still fails to find its archives.
+func goamd64Fallback(ctx *context.Context, goamd64 string, filter artifact.Filter) string {
+	fallback := ""
+	for _, art := range ctx.Artifacts.Filter(artifact.And(filter, artifact.ByGoarch("amd64"))).List() {
+		if art.Goamd64 == goamd64 {
+			return goamd64
+		}
+		// levels are v1 to v4, so they sort as strings.
+		if art.Goamd64 < goamd64 && art.Goamd64 > fallback {
+			fallback = art.Goamd64
+		}
+	}
+	if fallback == "" {
+		return goamd64
+	}
+	log.WithField("goamd64", goamd64).
+		WithField("fallback", fallback).
+		Warn("no amd64 archives found for the given goamd64, falling back to a lower level")
+	return fallback
+}
+
+// byGoarm64 filters by the given goarm64.
+// Artifacts without it are considered to be of the base level.
+func byGoarm64(s string) artifact.Filter {
+	if s == defaultGoarm64 {
+		return artifact.Or(artifact.ByGoarm64(s), artifact.ByGoarm64(""))
+	}
+	return artifact.ByGoarm64(s)
+}
+
+// pullRequestBase returns the pull request base of the given repository.
+// Its owner and name default to the repository ones, and its branch to the
+// default branch.
+func pullRequestBase(ref config.RepoRef) client.Repo {
+	base := client.Repo{
+		Owner:  ref.PullRequest.Base.Owner,
+		Name:   ref.PullRequest.Base.Name,
+		Branch: ref.PullRequest.Base.Branch,
+	}
+	if base.Owner == "" {
+		base.Owner = ref.Owner
+	}
+	if base.Name == "" {
+		base.Name = ref.Name
+	}
+	return base
+}
+
+// templateRef templates the given repository, including its commit_branch,
+// which is only supported by brew.
+func templateRef(ctx *context.Context, ref config.RepoRef) (config.RepoRef, error) {
+	result, err := client.TemplateRef(tmpl.New(ctx).Apply, ref)
+	if err != nil {
+		return ref, err
+	}
+	result.CommitBranch, err = tmpl.New(ctx).Apply(ref.CommitBranch)
+	return result, err
+}
+
+// repoFor returns the client repository for the given one, committing to its
+// commit_branch, if set, instead of its branch.
+func repoFor(ref config.RepoRef) client.Repo {
+	repo := client.RepoFromRef(ref)
+	if ref.CommitBranch != "" {
+		repo.Branch = ref.CommitBranch
+	}
+	return repo
+}
+
+// repositoriesFor returns all the repositories the formula is published to:
+// the repository, if set, followed by the repositories list.
+func repositoriesFor(brew config.Homebrew) []config.RepoRef {
+	var repos []config.RepoRef
+	if brew.Repository.Name != "" {
+		repos = append(repos, brew.Repository)
+	}
+	return append(repos, brew.Repositories...)
+}
+
+// validateRepository checks the templated repository has both owner and name.
+// Git repositories don't need an owner.
+func validateRepository(repo config.RepoRef) error {
+	if repo.Owner == "" && repo.Git.URL == "" {
+		return ErrMissingRepository{field: "owner"}
+	}
+	if repo.Name == "" {
+		return ErrMissingRepository{field: "name"}
+	}
+	return nil
+}
+
+func buildFormulaPath(folder, filename string) string {
 	return path.Join(folder, filename)
 }
 
+// sharedStrategyPath returns the path of the shared strategy file within the
+// tap.
+func sharedStrategyPath(file string) string {
+	return path.Join("lib", filepath.Base(file))
+}
+
+// vendoredDownloadStrategyFile is where taps usually vendor the download
+// strategies removed from Homebrew, in their lib folder.
+const vendoredDownloadStrategyFile = "custom_download_strategy.rb"
+
+// vendoredDownloadStrategies are the download strategies that were removed
+// from Homebrew, and so need to be required from the tap.
+// Other strategies are either built into Homebrew, or need an explicit
+// custom_require.
+var vendoredDownloadStrategies = map[string]bool{
+	"GitHubPrivateRepositoryDownloadStrategy":        true,
+	"GitHubPrivateRepositoryReleaseDownloadStrategy": true,
+	"S3DownloadStrategy":                             true,
+	"ScpDownloadStrategy":                            true,
+}
+
+// sharedStrategyRequire returns the require_relative path of the shared
+// strategy file, relative to the formula folder.
+func sharedStrategyRequire(folder, file string) string {
+	req := strings.TrimSuffix(sharedStrategyPath(file), ".rb")
+	if folder = path.Clean(folder); folder == "." {
+		return req
+	}
+	return strings.Repeat("../", len(strings.Split(folder, "/"))) + req
+}
+
+// extraFilesFor returns the extra files to commit alongside the formula, in
+// the formula folder.
+func extraFilesFor(ctx *context.Context, brew config.Homebrew) ([]client.RepoFile, error) {
+	var result []client.RepoFile
+	for _, extra := range brew.ExtraFiles {
+		files, err := extrafiles.Find(ctx, []config.ExtraFile{extra})
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("brew: no extra files found matching %q", extra.Glob)
+		}
+		names := keys(files)
+		sort.Strings(names)
+		for _, name := range names {
+			content, err := os.ReadFile(files[name])
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, client.RepoFile{
+				Content: content,
+				Path:    path.Join(brew.Folder, name),
+			})
+		}
+	}
+	return result, nil
+}
+
+func writeSharedStrategy(ctx *context.Context, file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read brew shared strategy file: %w", err)
+	}
+	path := filepath.Join(ctx.Config.Dist, "homebrew", sharedStrategyPath(file))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	log.WithField("strategy", path).Info("writing")
+	if err := os.WriteFile(path, content, 0o644); err != nil { //nolint: gosec
+		return fmt.Errorf("failed to write brew shared strategy file: %w", err)
+	}
+	return nil
+}
+
+// formatFormula runs brew.format_command on the formula written at path, and
+// returns the formatted formula.
+func formatFormula(ctx *context.Context, brew config.Homebrew, path string) (string, error) {
+	env := append(ctx.Env.Strings(), "FORMULA_PATH="+path)
+	if err := runCommand(ctx, "format command", brew.FormatCommand, env); err != nil {
+		return "", err
+	}
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read formatted brew formula: %w", err)
+	}
+	return string(bts), nil
+}
+
+// BuildFormula renders the formula of the given configuration and artifacts,
+// without writing it nor adding it to the context artifacts, e.g. to preview
+// it.
+// The configuration is used as is, so it should have its defaults set already,
+// and its name and repository templates are not applied.
+// The format command, if any, runs on a temporary copy of the formula, so the
+// result matches the published formula.
+func BuildFormula(ctx *context.Context, cfg config.Homebrew, cl client.ReleaserURLTemplater, artifacts []*artifact.Artifact) (string, error) {
+	content, err := buildFormula(ctx, cfg, cl, artifacts)
+	if err != nil || cfg.FormatCommand == "" {
+		return content, err
+	}
+	dir, err := os.MkdirTemp("", "goreleaser-brew-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, formulaFileName(cfg))
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil { //nolint: gosec
+		return "", fmt.Errorf("failed to write brew formula: %w", err)
+	}
+	return formatFormula(ctx, cfg, path)
+}
+
 func buildFormula(ctx *context.Context, brew config.Homebrew, client client.ReleaserURLTemplater, artifacts []*artifact.Artifact) (string, error) {
 	data, err := dataFor(ctx, brew, client, artifacts)
 	if err != nil {
 		return "", err
 	}
-	return doBuildFormula(ctx, data)
+	if err := runHooks(ctx, &data); err != nil {
+		return "", fmt.Errorf("brew: hook failed: %w", err)
+	}
+	content, err := doBuildFormula(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	if brew.ValidateFormula {
+		if err := checkRubySyntax(ctx, content); err != nil {
+			return "", err
+		}
+	}
+	return content, nil
 }
 
 func doBuildFormula(ctx *context.Context, data templateData) (string, error) {
+	text := formulaTemplate
+	if data.Template != "" {
+		bts, err := os.ReadFile(data.Template)
+		if err != nil {
+			return "", fmt.Errorf("brew: failed to read template: %w", err)
+		}
+		text = string(bts)
+	}
 	t, err := template.
 		New(data.Name).
-		Parse(formulaTemplate)
+		Funcs(template.FuncMap{
+			"quote":   func(s string) string { return quote(data.QuoteStyle, s) },
+			"dquote":  doubleQuote,
+			"license": func(s string) string { return licenseDSL(data.QuoteStyle, s) },
+		}).
+		Parse(text)
 	if err != nil {
 		return "", err
 	}
@@ -301,29 +1287,73 @@ func doBuildFormula(ctx *context.Context, data templateData) (string, error) {
 		return "", err
 	}
 
-	content, err := tmpl.New(ctx).Apply(out.String())
+	content, err := tmpl.New(ctx).WithExtraFields(tmpl.Fields{
+		"TapName": data.TapName,
+	}).Apply(out.String())
 	if err != nil {
 		return "", err
 	}
 	out.Reset()
 
+	eol := "\n"
+	if data.LineEnding == "crlf" {
+		eol = "\r\n"
+	}
+
 	// Sanitize the template output and get rid of trailing whitespace.
 	var (
 		r = strings.NewReader(content)
 		s = bufio.NewScanner(r)
 	)
 	for s.Scan() {
-		l := strings.TrimRight(s.Text(), " ")
+		l := s.Text()
+		if !data.SkipSanitize {
+			l = strings.TrimRight(l, " ")
+		}
+		l = reindent(l, data.IndentWidth)
 		_, _ = out.WriteString(l)
-		_ = out.WriteByte('\n')
+		_, _ = out.WriteString(eol)
 	}
 	if err := s.Err(); err != nil {
 		return "", err
 	}
 
+	// The inline patch is appended verbatim, as whitespace is meaningful in
+	// diffs and they might contain template-like strings.
+	if data.InlinePatch != "" {
+		_, _ = out.WriteString("__END__" + eol)
+		_, _ = out.WriteString(data.InlinePatch)
+		if !strings.HasSuffix(data.InlinePatch, "\n") {
+			// keep the line endings of the patch, which may differ from the
+			// formula ones.
+			if strings.Contains(data.InlinePatch, "\r\n") {
+				_, _ = out.WriteString("\r\n")
+			} else {
+				_ = out.WriteByte('\n')
+			}
+		}
+	}
+
 	return out.String(), nil
 }
 
+// defaultIndentWidth is the indentation width of the formula template, the one
+// used by Homebrew.
+const defaultIndentWidth = 2
+
+// reindent changes the indentation of the given line from the width used by
+// the template to the given one.
+// Leftover spaces, e.g. aligning continuation lines, are kept as is.
+func reindent(line string, width int) string {
+	if width <= 0 || width == defaultIndentWidth {
+		return line
+	}
+	content := strings.TrimLeft(line, " ")
+	spaces := len(line) - len(content)
+	levels, rest := spaces/defaultIndentWidth, spaces%defaultIndentWidth
+	return strings.Repeat(" ", levels*width+rest) + content
+}
+
 func installs(ctx *context.Context, cfg config.Homebrew, art *artifact.Artifact) ([]string, error) {
 	tpl := tmpl.New(ctx).WithArtifact(art)
 
@@ -336,19 +1366,56 @@ func installs(ctx *context.Context, cfg config.Homebrew, art *artifact.Artifact)
 	if err != nil {
 		return nil, err
 	}
+
+	// lines rendered after the binaries are installed.
+	after := append(shareInstalls(cfg), split(extraInstall)...)
+	after = append(after, chmods(cfg)...)
+	if line := completionsFromExecutable(cfg); line != "" {
+		after = append(after, line)
+	}
+
 	if install != "" {
-		return append(split(install), split(extraInstall)...), nil
+		return append(split(install), after...), nil
 	}
 
+	result, err := guessInstalls(cfg, art)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, after...), nil
+}
+
+// guessInstalls returns the bin.install lines of the binaries of the given
+// artifact.
+func guessInstalls(cfg config.Homebrew, art *artifact.Artifact) ([]string, error) {
 	installMap := map[string]bool{}
 	switch art.Type {
 	case artifact.UploadableBinary:
 		name := art.Name
 		bin := artifact.ExtraOr(*art, artifact.ExtraBinary, art.Name)
-		installMap[fmt.Sprintf("bin.install %q => %q", name, bin)] = true
+		installMap[fmt.Sprintf("bin.install %s => %s", quote(cfg.QuoteStyle, name), quote(cfg.QuoteStyle, bin))] = true
 	case artifact.UploadableArchive:
+		if len(cfg.InstallExclude) > 0 {
+			excludes := make([]string, 0, len(cfg.InstallExclude))
+			for _, exclude := range cfg.InstallExclude {
+				excludes = append(excludes, quote(cfg.QuoteStyle, exclude))
+			}
+			installMap[fmt.Sprintf("bin.install Dir[%s] - Dir[%s]", quote(cfg.QuoteStyle, "*"), strings.Join(excludes, ", "))] = true
+			break
+		}
+		only, restricted := fromArchiveBinaries(cfg)
 		for _, bin := range artifact.ExtraOr(*art, artifact.ExtraBinaries, []string{}) {
-			installMap[fmt.Sprintf("bin.install %q", bin)] = true
+			if restricted && !only[bin] && !only[path.Base(bin)] {
+				continue
+			}
+			bin = stripComponents(bin, cfg.StripComponents)
+			// binaries in nested folders are renamed to their base name, so
+			// they end up directly in the bin folder.
+			if name := path.Base(bin); name != bin {
+				installMap[fmt.Sprintf("bin.install %s => %s", quote(cfg.QuoteStyle, bin), quote(cfg.QuoteStyle, name))] = true
+				continue
+			}
+			installMap[fmt.Sprintf("bin.install %s", quote(cfg.QuoteStyle, bin))] = true
 		}
 	}
 
@@ -356,10 +1423,241 @@ func installs(ctx *context.Context, cfg config.Homebrew, art *artifact.Artifact)
 	sort.Strings(result)
 	log.WithField("install", result).Info("guessing install")
 
-	return append(result, split(extraInstall)...), nil
+	if len(result) == 0 && cfg.StrictInstall {
+		return nil, fmt.Errorf("brew: could not guess install lines for %s: set brews.install or make sure the archive has binaries", art.Name)
+	}
+
+	return result, nil
+}
+
+// stripComponents removes the given number of leading directories from the
+// path, like tar's --strip-components, keeping at least the file name.
+func stripComponents(p string, n int) string {
+	if n <= 0 {
+		return p
+	}
+	parts := strings.Split(path.Clean(p), "/")
+	if n >= len(parts) {
+		n = len(parts) - 1
+	}
+	return path.Join(parts[n:]...)
+}
+
+// shareInstalls returns the install lines of the share assets.
+// Without a destination, they are installed in the formula's own share folder.
+func shareInstalls(cfg config.Homebrew) []string {
+	result := make([]string, 0, len(cfg.ShareInstall))
+	for _, share := range cfg.ShareInstall {
+		if share.Dst == "" {
+			result = append(result, fmt.Sprintf("pkgshare.install %s", quote(cfg.QuoteStyle, share.Src)))
+			continue
+		}
+		result = append(result, fmt.Sprintf("(share/%s).install %s", quote(cfg.QuoteStyle, share.Dst), quote(cfg.QuoteStyle, share.Src)))
+	}
+	return result
+}
+
+// chmods returns the chmod lines for the configured binaries.
+func chmods(cfg config.Homebrew) []string {
+	result := make([]string, 0, len(cfg.Chmod))
+	for _, chmod := range cfg.Chmod {
+		mode := chmod.Mode
+		if mode == "" {
+			mode = "0755"
+		}
+		// ruby octal literals must start with a 0.
+		if !strings.HasPrefix(mode, "0") {
+			mode = "0" + mode
+		}
+		result = append(result, fmt.Sprintf("chmod %s, bin/%s", mode, quote(cfg.QuoteStyle, chmod.Path)))
+	}
+	return result
+}
+
+// completionsFromExecutable returns the line generating the shell completions
+// from the configured binary, if any.
+// It goes after the chmods, as the binary needs to be executable.
+func completionsFromExecutable(cfg config.Homebrew) string {
+	completions := cfg.CompletionsFromExecutable
+	if completions.Binary == "" {
+		return ""
+	}
+	args := []string{"bin/" + quote(cfg.QuoteStyle, completions.Binary)}
+	for _, arg := range strings.Fields(completions.Args) {
+		args = append(args, quote(cfg.QuoteStyle, arg))
+	}
+	if len(completions.Shells) > 0 {
+		shells := make([]string, 0, len(completions.Shells))
+		for _, shell := range completions.Shells {
+			shells = append(shells, ":"+shell)
+		}
+		args = append(args, fmt.Sprintf("shells: [%s]", strings.Join(shells, ", ")))
+	}
+	return fmt.Sprintf("generate_completions_from_executable(%s)", strings.Join(args, ", "))
+}
+
+// directiveFor templates the date and reason of the deprecate! or disable!
+// directive of the formula, returning nil if it isn't set.
+// Reasons starting with a colon are symbols, e.g. :unmaintained, anything else
+// is a string.
+func directiveFor(ctx *context.Context, cfg config.Homebrew, name, date, reason, renamedTo string) (*directive, error) {
+	if renamedTo != "" {
+		reason = ":renamed"
+	}
+	if reason == "" {
+		return nil, nil
+	}
+	var err error
+	date, err = tmpl.New(ctx).Apply(date)
+	if err != nil {
+		return nil, fmt.Errorf("brew: %s.date: %w", name, err)
+	}
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("brew: %s.date: invalid value %q, must be a date, e.g. 2024-01-31", name, date)
+		}
+	}
+	reason, err = tmpl.New(ctx).Apply(reason)
+	if err != nil {
+		return nil, fmt.Errorf("brew: %s.reason: %w", name, err)
+	}
+	if !strings.HasPrefix(reason, ":") {
+		reason = quote(cfg.QuoteStyle, reason)
+	}
+	return &directive{Date: date, Because: reason}, nil
+}
+
+// noAutobumpReason returns the reason of the no_autobump! directive: reasons
+// known by Homebrew are symbols, anything else is a string.
+func noAutobumpReason(cfg config.Homebrew) string {
+	if cfg.NoAutobump == "" {
+		return ""
+	}
+	reason := strings.TrimPrefix(cfg.NoAutobump, ":")
+	for _, known := range config.HomebrewNoAutobumpReasons {
+		if reason == known {
+			return ":" + reason
+		}
+	}
+	return quote(cfg.QuoteStyle, cfg.NoAutobump)
 }
 
-func keys(m map[string]bool) []string {
+// hasNoTest tells whether the formula has no test configured.
+func hasNoTest(cfg config.Homebrew) bool {
+	return strings.TrimSpace(cfg.Test) == "" && cfg.TestConfig.Command == "" && len(cfg.TestConfig.Binaries) == 0 && len(cfg.Tests) == 0
+}
+
+// assertionsFor renders the structured tests of the formula.
+// A test without a binary runs the first installed one, and a match equal to
+// the formula version is rendered as `version.to_s`.
+func assertionsFor(ctx *context.Context, cfg config.Homebrew, version string, artifacts []*artifact.Artifact) ([]string, error) {
+	result := make([]string, 0, len(cfg.Tests))
+	for i, test := range cfg.Tests {
+		if err := tmpl.New(ctx).ApplyAll(
+			&test.Binary,
+			&test.Command,
+			&test.Match,
+		); err != nil {
+			return nil, err
+		}
+		if test.Binary == "" {
+			test.Binary = helpTestBinary(cfg, artifacts)
+		}
+		if test.Binary == "" {
+			return nil, fmt.Errorf("brew: could not guess the binary of tests[%d]: set its binary instead", i)
+		}
+
+		cmd := "#{bin}/" + test.Binary
+		if test.Command != "" {
+			cmd += " " + test.Command
+		}
+		cmd = doubleQuote(cmd)
+
+		switch test.Match {
+		case "":
+			result = append(result, "system "+cmd)
+		case version:
+			result = append(result, fmt.Sprintf("assert_match version.to_s, shell_output(%s)", cmd))
+		default:
+			result = append(result, fmt.Sprintf("assert_match %s, shell_output(%s)", quote(cfg.QuoteStyle, test.Match), cmd))
+		}
+	}
+	return result, nil
+}
+
+// helpTestBinary guesses the binary to test with --help: the first one
+// installed from the artifacts.
+func helpTestBinary(cfg config.Homebrew, artifacts []*artifact.Artifact) string {
+	only, restricted := fromArchiveBinaries(cfg)
+	for _, art := range artifacts {
+		switch art.Type {
+		case artifact.UploadableBinary:
+			return path.Base(artifact.ExtraOr(*art, artifact.ExtraBinary, art.Name))
+		case artifact.UploadableArchive:
+			for _, bin := range artifact.ExtraOr(*art, artifact.ExtraBinaries, []string{}) {
+				if restricted && !only[bin] && !only[path.Base(bin)] {
+					continue
+				}
+				return path.Base(bin)
+			}
+		}
+	}
+	return ""
+}
+
+// serviceFor returns the lines of the service block of the formula.
+//
+// The first item of service.run is the binary to run, installed into opt_bin,
+// unless it's an absolute path.
+func serviceFor(cfg config.Homebrew) []string {
+	service := cfg.Service
+	if len(service.Run) == 0 {
+		return split(service.Raw)
+	}
+	run := make([]string, 0, len(service.Run))
+	for i, arg := range service.Run {
+		if i == 0 && !strings.HasPrefix(arg, "/") {
+			run = append(run, "opt_bin/"+quote(cfg.QuoteStyle, arg))
+			continue
+		}
+		run = append(run, quote(cfg.QuoteStyle, arg))
+	}
+	result := []string{fmt.Sprintf("run [%s]", strings.Join(run, ", "))}
+	if service.RunType != "" {
+		result = append(result, "run_type :"+service.RunType)
+	}
+	if service.KeepAlive {
+		result = append(result, "keep_alive true")
+	}
+	if service.WorkingDir != "" {
+		result = append(result, "working_dir "+quote(cfg.QuoteStyle, service.WorkingDir))
+	}
+	if len(service.EnvironmentVariables) > 0 {
+		names := keys(service.EnvironmentVariables)
+		sort.Strings(names)
+		env := make([]string, 0, len(names))
+		for _, name := range names {
+			env = append(env, fmt.Sprintf("%s: %s", name, quote(cfg.QuoteStyle, service.EnvironmentVariables[name])))
+		}
+		result = append(result, "environment_variables "+strings.Join(env, ", "))
+	}
+	return result
+}
+
+// dependenciesFor returns a copy of the given dependencies with their names
+// and versions templated.
+func dependenciesFor(ctx *context.Context, deps []config.HomebrewDependency) ([]config.HomebrewDependency, error) {
+	result := make([]config.HomebrewDependency, 0, len(deps))
+	for _, dep := range deps {
+		if err := tmpl.New(ctx).ApplyAll(&dep.Name, &dep.Version); err != nil {
+			return nil, err
+		}
+		result = append(result, dep)
+	}
+	return result, nil
+}
+
+func keys[T any](m map[string]T) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
@@ -368,32 +1666,184 @@ func keys(m map[string]bool) []string {
 }
 
 func dataFor(ctx *context.Context, cfg config.Homebrew, cl client.ReleaserURLTemplater, artifacts []*artifact.Artifact) (templateData, error) {
-	sort.Slice(cfg.Dependencies, func(i, j int) bool {
+	deps, err := dependenciesFor(ctx, cfg.Dependencies)
+	if err != nil {
+		return templateData{}, err
+	}
+	cfg.Dependencies = deps
+	headDeps, err := dependenciesFor(ctx, cfg.Head.Dependencies)
+	if err != nil {
+		return templateData{}, err
+	}
+	cfg.Head.Dependencies = headDeps
+	sort.SliceStable(cfg.Dependencies, func(i, j int) bool {
 		return cfg.Dependencies[i].Name < cfg.Dependencies[j].Name
 	})
+	className := classNameFor(cfg)
+	if cfg.ClassSuffix != "" && !rubyConstantRe.MatchString(className) {
+		return templateData{}, fmt.Errorf("brew: invalid class name: %s", className)
+	}
 	result := templateData{
-		Name:          formulaNameFor(cfg.Name),
-		Desc:          cfg.Description,
-		Homepage:      cfg.Homepage,
-		Version:       ctx.Version,
-		License:       cfg.License,
-		Caveats:       split(cfg.Caveats),
-		Dependencies:  cfg.Dependencies,
-		Conflicts:     cfg.Conflicts,
-		Plist:         cfg.Plist,
-		Service:       split(cfg.Service),
-		PostInstall:   split(cfg.PostInstall),
-		Tests:         split(cfg.Test),
-		CustomRequire: cfg.CustomRequire,
-		CustomBlock:   split(cfg.CustomBlock),
-	}
-
-	counts := map[string]int{}
-	for _, art := range artifacts {
-		sum, err := art.Checksum("sha256")
+		Name:                className,
+		Desc:                cfg.Description,
+		Homepage:            cfg.Homepage,
+		Version:             formulaVersion(ctx.Version, cfg.StripBuildMetadata),
+		License:             cfg.License,
+		Caveats:             split(cfg.Caveats),
+		MacOSCaveats:        split(cfg.MacOSCaveats),
+		LinuxCaveats:        split(cfg.LinuxCaveats),
+		Conflicts:           cfg.Conflicts,
+		OldNames:            cfg.OldNames,
+		Plist:               cfg.Plist,
+		Service:             serviceFor(cfg),
+		PreInstall:          split(cfg.PreInstall),
+		PostInstall:         split(cfg.PostInstall),
+		MacOSPostInstall:    split(cfg.MacOSPostInstall),
+		LinuxPostInstall:    split(cfg.LinuxPostInstall),
+		Tests:               split(cfg.Test),
+		CustomRequire:       cfg.CustomRequire,
+		CustomBlock:         split(cfg.CustomBlock),
+		QuoteStyle:          cfg.QuoteStyle,
+		FrozenStringLiteral: cfg.FrozenStringLiteral == nil || *cfg.FrozenStringLiteral,
+		RenamedBinaries:     cfg.RenamedBinaries,
+		TapName:             tapNameFor(firstRepository(cfg)),
+		RequireArch:         cfg.RequireArch,
+		TestConfig:          cfg.TestConfig,
+		Deprecate:           cfg.Deprecate,
+		Sorbet:              cfg.Sorbet,
+		SorbetSigs:          cfg.Sorbet == "strict" || cfg.Sorbet == "strong",
+		RequireOS:           cfg.RequireOS,
+		LegacyOS:            cfg.Compat == "3.0",
+		InlinePatch:         cfg.InlinePatch,
+		Head:                cfg.Head,
+		Template:            cfg.Template,
+		NoAutobump:          noAutobumpReason(cfg),
+		IndentWidth:         cfg.IndentWidth,
+		LineEnding:          cfg.LineEnding,
+		SkipSanitize:        cfg.SkipSanitize,
+	}
+
+	if cfg.IncludeCommitComment {
+		result.Commit = ctx.Git.ShortCommit
+	}
+
+	headInstall, err := tmpl.New(ctx).Apply(cfg.Head.Install)
+	if err != nil {
+		return result, err
+	}
+	result.HeadInstall = split(headInstall)
+
+	for _, dep := range cfg.Dependencies {
+		switch dep.OS {
+		case "macos":
+			result.MacOSDependencies = append(result.MacOSDependencies, dep)
+		case "linux":
+			result.LinuxDependencies = append(result.LinuxDependencies, dep)
+		default:
+			result.Dependencies = append(result.Dependencies, dep)
+		}
+	}
+
+	if cfg.HelpTest && hasNoTest(cfg) {
+		bin := helpTestBinary(cfg, artifacts)
+		if bin == "" {
+			return result, fmt.Errorf("brew: could not guess the binary to test with --help: set brews.test instead")
+		}
+		result.HelpTest = bin
+	}
+
+	assertions, err := assertionsFor(ctx, cfg, result.Version, artifacts)
+	if err != nil {
+		return result, err
+	}
+	result.Assertions = assertions
+
+	resources, err := resourcesFor(ctx, cfg.Resources)
+	if err != nil {
+		return result, err
+	}
+	result.Resources = resources
+
+	livecheck, err := livecheckFor(ctx, cfg.Livecheck)
+	if err != nil {
+		return result, err
+	}
+	result.Livecheck = livecheck
+
+	if cfg.BottleManifest != "" {
+		bottle, err := bottleFor(ctx, cfg)
+		if err != nil {
+			return result, err
+		}
+		result.Bottle = bottle
+	}
+	if len(cfg.Bottle.Checksums) > 0 {
+		bottle, err := bottleFromConfig(ctx, cfg.Bottle)
 		if err != nil {
 			return result, err
 		}
+		result.Bottle = bottle
+	}
+
+	result.DeprecateDirective, err = directiveFor(ctx, cfg, "deprecate", cfg.Deprecate.Date, cfg.Deprecate.Reason, cfg.Deprecate.RenamedTo)
+	if err != nil {
+		return result, err
+	}
+	result.DisableDirective, err = directiveFor(ctx, cfg, "disable", cfg.Disable.Date, cfg.Disable.Reason, "")
+	if err != nil {
+		return result, err
+	}
+
+	if to := cfg.Deprecate.RenamedTo; to != "" {
+		result.Caveats = append(
+			result.Caveats,
+			fmt.Sprintf("The %s formula has been renamed to %s, please install it instead:", cfg.Name, to),
+			"  brew install "+to,
+		)
+	}
+
+	if cfg.SharedStrategyFile != "" {
+		result.CustomRequire = sharedStrategyRequire(cfg.Folder, cfg.SharedStrategyFile)
+	}
+
+	if result.CustomRequire == "" && vendoredDownloadStrategies[cfg.DownloadStrategy] {
+		result.CustomRequire = sharedStrategyRequire(cfg.Folder, vendoredDownloadStrategyFile)
+	}
+
+	if cfg.SourceBuild {
+		source, err := sourcePackageFor(ctx, cfg)
+		if err != nil {
+			return result, err
+		}
+		result.Source = source
+		return result, nil
+	}
+
+	if len(cfg.FormatPriority) == 0 {
+		artifacts = withoutFallbackFormats(artifacts)
+	}
+	artifacts = prefer(artifacts, cfg.PreferIDs, func(a *artifact.Artifact) string {
+		return artifact.ExtraOr(*a, artifact.ExtraID, "")
+	})
+	artifacts = prefer(artifacts, cfg.FormatPriority, func(a *artifact.Artifact) string {
+		return artifact.ExtraOr(*a, artifact.ExtraFormat, "")
+	})
+
+	skipOS := map[string]bool{
+		"darwin": skipsUpload(ctx, cfg.SkipUploadMacOS),
+		"linux":  skipsUpload(ctx, cfg.SkipUploadLinux),
+	}
+
+	// archives holds the names of the archives of each OS/Arch combination,
+	// so collisions can be reported.
+	archives := map[string][]string{}
+	for _, art := range artifacts {
+		if skipOS[art.Goos] {
+			log.WithField("os", art.Goos).
+				WithField("archive", art.Name).
+				Debug("skip_upload is set for this os, not adding it to the formula")
+			continue
+		}
 
 		if cfg.URLTemplate == "" {
 			url, err := cl.ReleaseURLTemplate(ctx)
@@ -403,7 +1853,14 @@ func dataFor(ctx *context.Context, cfg config.Homebrew, cl client.ReleaserURLTem
 			cfg.URLTemplate = url
 		}
 
-		url, err := tmpl.New(ctx).WithArtifact(art).Apply(cfg.URLTemplate)
+		url, err := tmpl.New(ctx).WithArtifact(art).WithExtraFields(tmpl.Fields{
+			"ArtifactUploadName": artifact.ExtraOr(*art, artifact.ExtraUploadName, art.Name),
+		}).Apply(cfg.URLTemplate)
+		if err != nil {
+			return result, err
+		}
+
+		sum, err := checksumFor(ctx, cfg, art, url)
 		if err != nil {
 			return result, err
 		}
@@ -414,15 +1871,40 @@ func dataFor(ctx *context.Context, cfg config.Homebrew, cl client.ReleaserURLTem
 		}
 
 		pkg := releasePackage{
-			DownloadURL:      url,
-			SHA256:           sum,
-			OS:               art.Goos,
-			Arch:             art.Goarch,
-			DownloadStrategy: cfg.DownloadStrategy,
-			Install:          install,
+			DownloadURL:       url,
+			SHA256:            sum,
+			OS:                art.Goos,
+			Arch:              art.Goarch,
+			DownloadStrategy:  cfg.DownloadStrategy,
+			URLUsing:          cfg.URLUsing,
+			URLHeaders:        cfg.URLHeaders,
+			Install:           install,
+			ChecksumAlgorithm: checksumAlgorithm(cfg.ChecksumAlgorithm),
+			Goarm:             art.Goarm,
+			Goamd64:           art.Goamd64,
+			Goarm64:           art.Goarm64,
 		}
 
-		counts[pkg.OS+pkg.Arch]++
+		if cfg.AllowMultipleArchives {
+			if main := findPackage(&result, pkg.OS, pkg.Arch); main != nil {
+				// resources only install their own binaries, the other
+				// install lines are already in the main install.
+				resourceInstall, err := guessInstalls(cfg, art)
+				if err != nil {
+					return result, err
+				}
+				main.Resources = append(main.Resources, releaseResource{
+					Name:        art.Name,
+					DownloadURL: url,
+					SHA256:      sum,
+					Install:     resourceInstall,
+				})
+				continue
+			}
+		}
+
+		key := pkg.OS + "/" + pkg.Arch
+		archives[key] = append(archives[key], archiveName(art))
 
 		switch pkg.OS {
 		case "darwin":
@@ -432,25 +1914,235 @@ func dataFor(ctx *context.Context, cfg config.Homebrew, cl client.ReleaserURLTem
 		}
 	}
 
-	for _, v := range counts {
-		if v > 1 {
-			return result, ErrMultipleArchivesSameOS
+	combinations := keys(archives)
+	sort.Strings(combinations)
+	for _, key := range combinations {
+		if names := archives[key]; len(names) > 1 {
+			return result, fmt.Errorf("archives %s all map to %s: %w", strings.Join(names, ", "), key, ErrMultipleArchivesSameOS)
 		}
 	}
 
 	if len(result.MacOSPackages) == 1 && result.MacOSPackages[0].Arch == "amd64" {
 		result.HasOnlyAmd64MacOsPkg = true
 	}
+	result.MacOSArchBlocks = !result.LegacyOS && macOSInstallsDiffer(result.MacOSPackages)
+	result.MacOSInstall = sharedInstall(result.MacOSPackages)
+	result.LinuxInstall = sharedInstall(result.LinuxPackages)
+
+	if cfg.PreserveArtifactOrder {
+		return result, nil
+	}
 
 	sort.Slice(result.LinuxPackages, lessFnFor(result.LinuxPackages))
 	sort.Slice(result.MacOSPackages, lessFnFor(result.MacOSPackages))
 	return result, nil
 }
 
+// macOSInstallsDiffer tells whether the given macOS packages have both an
+// amd64 and an arm64 archive, with different install steps.
+func macOSInstallsDiffer(pkgs []releasePackage) bool {
+	var intel, arm *releasePackage
+	for i := range pkgs {
+		switch pkgs[i].Arch {
+		case "amd64":
+			intel = &pkgs[i]
+		case "arm64":
+			arm = &pkgs[i]
+		}
+	}
+	if intel == nil || arm == nil {
+		return false
+	}
+	return strings.Join(intel.Install, "\n") != strings.Join(arm.Install, "\n")
+}
+
+// sharedInstall returns the install steps of the given packages of an OS if
+// they all have the same ones, so they are rendered once instead of per
+// arch.
+// Packages with resources are installed per arch, as are single packages.
+func sharedInstall(pkgs []releasePackage) []string {
+	if len(pkgs) < 2 {
+		return nil
+	}
+	for _, pkg := range pkgs {
+		if pkg.Arch == "all" || len(pkg.Resources) > 0 || len(pkg.Install) == 0 ||
+			strings.Join(pkg.Install, "\n") != strings.Join(pkgs[0].Install, "\n") {
+			return nil
+		}
+	}
+	return pkgs[0].Install
+}
+
+// archiveName returns the name of the given archive, along with its ID, if
+// any.
+func archiveName(art *artifact.Artifact) string {
+	if id := artifact.ExtraOr(*art, artifact.ExtraID, ""); id != "" {
+		return fmt.Sprintf("%s (id %s)", art.Name, id)
+	}
+	return art.Name
+}
+
+// checksumFor returns the checksum of the given artifact, either calculating
+// it or reading it from brew.checksums_file.
+// On snapshots, a placeholder may be used instead for local download URLs,
+// which is useful when testing formulas locally, e.g. with file:// URLs.
+func checksumFor(ctx *context.Context, cfg config.Homebrew, art *artifact.Artifact, downloadURL string) (string, error) {
+	if ctx.Snapshot && cfg.AllowPlaceholderChecksum && isLocalURL(downloadURL) {
+		log.WithField("artifact", art.Name).Debug("snapshot: using placeholder checksum")
+		return placeholderChecksumFor(cfg), nil
+	}
+	if cfg.ChecksumsFile != "" {
+		return checksumFromFile(ctx, cfg.ChecksumsFile, art.Name, checksumAlgorithm(cfg.ChecksumAlgorithm))
+	}
+	return archiveChecksums.get(art, checksumAlgorithm(cfg.ChecksumAlgorithm))
+}
+
+// isLocalURL tells whether the given URL is a file:// or a localhost one.
+func isLocalURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "file" {
+		return true
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// placeholderChecksumFor returns the placeholder checksum with the size of
+// the checksum algorithm being used.
+func placeholderChecksumFor(cfg config.Homebrew) string {
+	if checksumAlgorithm(cfg.ChecksumAlgorithm) == "sha512" {
+		return placeholderChecksum + placeholderChecksum
+	}
+	return placeholderChecksum
+}
+
+// checksumAlgorithm returns the given checksum algorithm, defaulting to
+// sha256.
+func checksumAlgorithm(s string) string {
+	if s == "sha512" {
+		return s
+	}
+	return "sha256"
+}
+
+// findPackage returns the package already added for the given OS/arch, if any.
+func findPackage(data *templateData, goos, goarch string) *releasePackage {
+	pkgs := data.LinuxPackages
+	if goos == "darwin" {
+		pkgs = data.MacOSPackages
+	}
+	for i := range pkgs {
+		if pkgs[i].Arch == goarch {
+			return &pkgs[i]
+		}
+	}
+	return nil
+}
+
+// fallbackFormats are only used for the platforms that have no archive in any
+// other format, so releases that also have them keep working as before.
+var fallbackFormats = map[string]bool{
+	"tar.xz":  true,
+	"tar.bz2": true,
+}
+
+// withoutFallbackFormats removes the artifacts in fallbackFormats from the
+// platforms that have artifacts in other formats.
+func withoutFallbackFormats(artifacts []*artifact.Artifact) []*artifact.Artifact {
+	isFallback := func(a *artifact.Artifact) bool {
+		return fallbackFormats[artifact.ExtraOr(*a, artifact.ExtraFormat, "")]
+	}
+	hasOthers := map[string]bool{}
+	for _, art := range artifacts {
+		if !isFallback(art) {
+			hasOthers[art.Goos+art.Goarch] = true
+		}
+	}
+	result := make([]*artifact.Artifact, 0, len(artifacts))
+	for _, art := range artifacts {
+		if isFallback(art) && hasOthers[art.Goos+art.Goarch] {
+			continue
+		}
+		result = append(result, art)
+	}
+	return result
+}
+
+// prefer resolves artifacts colliding on the same OS/arch by keeping only the
+// one whose key appears first in the given priority list.
+// Collisions that can't be resolved this way are kept as-is, so they are still
+// reported as errors later on.
+func prefer(artifacts []*artifact.Artifact, priority []string, key func(a *artifact.Artifact) string) []*artifact.Artifact {
+	if len(priority) == 0 {
+		return artifacts
+	}
+
+	rank := func(a *artifact.Artifact) int {
+		for i, p := range priority {
+			if key(a) == p {
+				return i
+			}
+		}
+		return len(priority)
+	}
+
+	var platforms []string
+	groups := map[string][]*artifact.Artifact{}
+	for _, art := range artifacts {
+		platform := art.Goos + art.Goarch
+		if _, ok := groups[platform]; !ok {
+			platforms = append(platforms, platform)
+		}
+		groups[platform] = append(groups[platform], art)
+	}
+
+	result := make([]*artifact.Artifact, 0, len(artifacts))
+	for _, platform := range platforms {
+		group := groups[platform]
+		var best []*artifact.Artifact
+		for _, art := range group {
+			switch {
+			case len(best) == 0 || rank(art) < rank(best[0]):
+				best = []*artifact.Artifact{art}
+			case rank(art) == rank(best[0]):
+				best = append(best, art)
+			}
+		}
+		if len(best) > 1 || rank(best[0]) == len(priority) {
+			result = append(result, group...)
+			continue
+		}
+		result = append(result, best[0])
+	}
+	return result
+}
+
 func lessFnFor(list []releasePackage) func(i, j int) bool {
 	return func(i, j int) bool { return list[i].OS > list[j].OS && list[i].Arch > list[j].Arch }
 }
 
+// quote quotes the given string as a Ruby string literal, using either double
+// (default) or single quotes.
+func quote(style, s string) string {
+	if style == quoteSingle {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+	}
+	return `"` + s + `"`
+}
+
+// doubleQuote always double quotes s, regardless of brew.quote_style, so
+// Ruby interpolations like #{bin} in it still work.
+func doubleQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
 func split(s string) []string {
 	strings := strings.Split(strings.TrimSpace(s), "\n")
 	if len(strings) == 1 && strings[0] == "" {
@@ -459,6 +2151,66 @@ func split(s string) []string {
 	return strings
 }
 
+// firstRepository returns the first repository the formula is published to,
+// or an empty one if there is none.
+func firstRepository(cfg config.Homebrew) config.RepoRef {
+	if repos := repositoriesFor(cfg); len(repos) > 0 {
+		return repos[0]
+	}
+	return config.RepoRef{}
+}
+
+// tapNameFor returns the name of the tap as used by `brew tap`, e.g. the
+// repository foo/homebrew-bar is the tap foo/bar.
+func tapNameFor(repo config.RepoRef) string {
+	if repo.Owner == "" || repo.Name == "" {
+		return ""
+	}
+	return repo.Owner + "/" + strings.TrimPrefix(repo.Name, "homebrew-")
+}
+
+// resourcesFor templates the resources of the formula.
+func resourcesFor(ctx *context.Context, resources []config.HomebrewResource) ([]releaseResource, error) {
+	result := make([]releaseResource, 0, len(resources))
+	for _, res := range resources {
+		if err := tmpl.New(ctx).ApplyAll(
+			&res.Name,
+			&res.URL,
+			&res.SHA256,
+		); err != nil {
+			return nil, err
+		}
+		result = append(result, releaseResource{
+			Name:        res.Name,
+			DownloadURL: res.URL,
+			SHA256:      res.SHA256,
+		})
+	}
+	return result, nil
+}
+
+// skipsUpload tells whether the given, already templated, skip_upload value
+// skips the current release: either it is true, or it is auto and the
+// release is a prerelease.
+func skipsUpload(ctx *context.Context, value string) bool {
+	switch strings.TrimSpace(value) {
+	case "true":
+		return true
+	case "auto":
+		return ctx.Semver.Prerelease != ""
+	default:
+		return false
+	}
+}
+
+// classNameFor returns the Ruby class name of the formula.
+func classNameFor(cfg config.Homebrew) string {
+	if cfg.ClassName != "" {
+		return cfg.ClassName
+	}
+	return formulaNameFor(cfg.Name) + cfg.ClassSuffix
+}
+
 // formulaNameFor transforms the formula name into a form
 // that more resembles a valid Ruby class name
 // e.g. foo_bar@v6.0.0-rc is turned into FooBarATv6_0_0RC
@@ -1,15 +1,28 @@
 package brew
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/charmbracelet/keygen"
 	"github.com/goreleaser/goreleaser/internal/artifact"
 	"github.com/goreleaser/goreleaser/internal/client"
 	"github.com/goreleaser/goreleaser/internal/golden"
+	"github.com/goreleaser/goreleaser/internal/pipe"
+	"github.com/goreleaser/goreleaser/internal/pipe/archive"
+	"github.com/goreleaser/goreleaser/internal/pipe/build"
 	"github.com/goreleaser/goreleaser/internal/testctx"
 	"github.com/goreleaser/goreleaser/internal/testlib"
 	"github.com/goreleaser/goreleaser/pkg/config"
@@ -41,6 +54,171 @@ func TestNameWithAT(t *testing.T) {
 	require.Equal(t, formulaNameFor("some_binary@1"), "SomeBinaryAT1")
 }
 
+func TestClassName(t *testing.T) {
+	ctx := testctx.New()
+	for name, tt := range map[string]struct {
+		cfg      config.Homebrew
+		expected string
+	}{
+		"default":           {config.Homebrew{Name: "foo-bar"}, "FooBar"},
+		"default versioned": {config.Homebrew{Name: "foo@2"}, "FooAT2"},
+		"override":          {config.Homebrew{Name: "foo@2", ClassName: "FooV2"}, "FooV2"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := dataFor(ctx, tt.cfg, client.NewMock(), nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, data.Name)
+		})
+	}
+
+	t.Run("filename", func(t *testing.T) {
+		folder := t.TempDir()
+		ctx := testctx.NewWithCfg(
+			config.Project{
+				Dist:        folder,
+				ProjectName: "foo",
+				Brews: []config.Homebrew{
+					{
+						Name:      "foo@2",
+						ClassName: "FooV2",
+						Repository: config.RepoRef{
+							Owner: "foo",
+							Name:  "bar",
+						},
+					},
+				},
+			},
+			testctx.WithVersion("1.2.1"),
+			testctx.WithCurrentTag("v1.2.1"),
+		)
+		path := filepath.Join(folder, "bin.tar.gz")
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:   "bin.tar.gz",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		})
+		require.NoError(t, Pipe{}.Default(ctx))
+		require.NoError(t, runAll(ctx, client.NewMock()))
+		formula, err := os.ReadFile(filepath.Join(folder, "homebrew", "foo@2.rb"))
+		require.NoError(t, err)
+		require.Contains(t, string(formula), "class FooV2 < Formula")
+	})
+}
+
+func TestHelpTest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("fake"), 0o644))
+	artifacts := []*artifact.Artifact{
+		{
+			Name:   "bin.tar.gz",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"tool-1.0/bin/tool", "other"},
+			},
+		},
+	}
+
+	t.Run("guessed", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), config.Homebrew{Name: "tool", HelpTest: true}, client.NewMock(), artifacts)
+		require.NoError(t, err)
+		require.Equal(t, "tool", data.HelpTest)
+
+		formulae, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, "  test do\n    system \"#{bin}/tool\", \"--help\"\n  end\n")
+	})
+
+	t.Run("single quotes", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), config.Homebrew{Name: "tool", HelpTest: true, QuoteStyle: "single"}, client.NewMock(), artifacts)
+		require.NoError(t, err)
+
+		formulae, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, "  test do\n    system \"#{bin}/tool\", '--help'\n  end\n")
+	})
+
+	t.Run("from archive", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), config.Homebrew{
+			Name:        "other",
+			HelpTest:    true,
+			FromArchive: map[string][]string{"other": {"other"}},
+		}, client.NewMock(), artifacts)
+		require.NoError(t, err)
+		require.Equal(t, "other", data.HelpTest)
+	})
+
+	t.Run("raw test wins", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), config.Homebrew{
+			Name:     "tool",
+			HelpTest: true,
+			Test:     `system "#{bin}/tool", "version"`,
+		}, client.NewMock(), artifacts)
+		require.NoError(t, err)
+		require.Empty(t, data.HelpTest)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), config.Homebrew{Name: "tool"}, client.NewMock(), artifacts)
+		require.NoError(t, err)
+		require.Empty(t, data.HelpTest)
+	})
+
+	t.Run("nothing to guess", func(t *testing.T) {
+		_, err := dataFor(testctx.New(), config.Homebrew{Name: "tool", HelpTest: true, SourceBuild: true, Install: "x"}, client.NewMock(), nil)
+		require.EqualError(t, err, "brew: could not guess the binary to test with --help: set brews.test instead")
+	})
+}
+
+func TestClassSuffix(t *testing.T) {
+	ctx := testctx.New()
+	for name, expected := range map[string]string{
+		"tool":   "ToolCLI",
+		"tool@2": "ToolAT2CLI",
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := dataFor(ctx, config.Homebrew{Name: name, ClassSuffix: "CLI"}, client.NewMock(), nil)
+			require.NoError(t, err)
+			require.Equal(t, expected, data.Name)
+		})
+	}
+
+	t.Run("invalid class name", func(t *testing.T) {
+		_, err := dataFor(ctx, config.Homebrew{Name: "1tool", ClassSuffix: "CLI"}, client.NewMock(), nil)
+		require.EqualError(t, err, "brew: invalid class name: 1toolCLI")
+	})
+
+	t.Run("invalid suffix", func(t *testing.T) {
+		ctx := testctx.NewWithCfg(config.Project{
+			Brews: []config.Homebrew{{ClassSuffix: "-cli"}},
+		})
+		require.EqualError(t, Pipe{}.Default(ctx), `brews[0]: class_suffix: invalid value "-cli", must contain only letters, digits and underscores`)
+	})
+}
+
+func TestTapName(t *testing.T) {
+	require.Equal(t, "foo/bar", tapNameFor(config.RepoRef{Owner: "foo", Name: "homebrew-bar"}))
+	require.Equal(t, "foo/bar", tapNameFor(config.RepoRef{Owner: "foo", Name: "bar"}))
+	require.Equal(t, "", tapNameFor(config.RepoRef{Name: "homebrew-bar"}))
+
+	data := defaultTemplateData
+	data.TapName = "foo/bar"
+	data.Caveats = []string{"brew tap {{ .TapName }}"}
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, "      brew tap foo/bar\n")
+}
+
 func TestSimpleName(t *testing.T) {
 	require.Equal(t, formulaNameFor("binary"), "Binary")
 }
@@ -91,6 +269,7 @@ var defaultTemplateData = templateData{
 	Version:              "0.1.3",
 	Caveats:              []string{},
 	HasOnlyAmd64MacOsPkg: false,
+	FrozenStringLiteral:  true,
 }
 
 func assertDefaultTemplateData(t *testing.T, formulae string) {
@@ -120,6 +299,21 @@ func TestFullFormulae(t *testing.T) {
 	golden.RequireEqualRb(t, []byte(formulae))
 }
 
+func TestFullFormulaeDependencyComment(t *testing.T) {
+	data := defaultTemplateData
+	data.Dependencies = []config.HomebrewDependency{
+		{Name: "gtk+"},
+		{Name: "zsh", Comment: "needs zsh >= 5.8"},
+	}
+	formulae, err := doBuildFormula(testctx.NewWithCfg(config.Project{
+		ProjectName: "foo",
+	}), data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, "  depends_on \"zsh\" # needs zsh >= 5.8\n")
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
 func TestFullFormulaeLinuxOnly(t *testing.T) {
 	data := defaultTemplateData
 	data.MacOSPackages = []releasePackage{}
@@ -142,833 +336,4476 @@ func TestFullFormulaeMacOSOnly(t *testing.T) {
 	golden.RequireEqualRb(t, []byte(formulae))
 }
 
-func TestFormulaeSimple(t *testing.T) {
-	formulae, err := doBuildFormula(testctx.NewWithCfg(config.Project{}), defaultTemplateData)
+func TestFullFormulaeRequireArch(t *testing.T) {
+	data := defaultTemplateData
+	data.LinuxPackages = []releasePackage{}
+	data.RequireArch = "arm64"
+	formulae, err := doBuildFormula(testctx.NewWithCfg(config.Project{
+		ProjectName: "foo",
+	}), data)
 	require.NoError(t, err)
-	assertDefaultTemplateData(t, formulae)
-	require.NotContains(t, formulae, "def caveats")
-	require.NotContains(t, formulae, "def plist;")
+	require.Equal(t, 1, strings.Count(formulae, "depends_on arch:"))
+
+	golden.RequireEqualRb(t, []byte(formulae))
 }
 
-func TestSplit(t *testing.T) {
-	parts := split("system \"true\"\nsystem \"#{bin}/foo\", \"-h\"")
-	require.Equal(t, []string{"system \"true\"", "system \"#{bin}/foo\", \"-h\""}, parts)
-	parts = split("")
-	require.Equal(t, []string{}, parts)
-	parts = split("\n  ")
-	require.Equal(t, []string{}, parts)
+func TestFullFormulaeRequireOS(t *testing.T) {
+	for _, goos := range []string{"macos", "linux"} {
+		t.Run(goos, func(t *testing.T) {
+			data := defaultTemplateData
+			data.RequireOS = goos
+			formulae, err := doBuildFormula(testctx.New(), data)
+			require.NoError(t, err)
+			require.Equal(t, 1, strings.Count(formulae, "depends_on :"))
+			require.Contains(t, formulae, "\n  depends_on :"+goos+"\n")
+		})
+	}
 }
 
-func TestFullPipe(t *testing.T) {
-	type testcase struct {
-		prepare              func(ctx *context.Context)
-		expectedRunError     string
-		expectedPublishError string
+func TestFullFormulaeRenamedBinaries(t *testing.T) {
+	data := defaultTemplateData
+	data.Caveats = []string{"Here are some caveats"}
+	data.RenamedBinaries = []config.HomebrewRenamedBinary{
+		{Old: "foo", New: "bar"},
+		{Old: "baz"},
 	}
-	for name, tt := range map[string]testcase{
-		"default": {
-			prepare: func(ctx *context.Context) {
-				ctx.TokenType = context.TokenTypeGitHub
-				ctx.Config.Brews[0].Repository.Owner = "test"
-				ctx.Config.Brews[0].Repository.Name = "test"
-				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
-			},
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestFullFormulaeSorbetStrict(t *testing.T) {
+	data := defaultTemplateData
+	data.Sorbet = "strict"
+	data.SorbetSigs = true
+	data.Caveats = []string{"Here are some caveats"}
+	data.PostInstall = []string{`touch "/tmp/foo"`}
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestFullFormulaeBottle(t *testing.T) {
+	data := defaultTemplateData
+	data.Bottle = &bottle{
+		RootURL: "https://ghcr.io/v2/foo/tap",
+		Rebuild: 1,
+		Checksums: []bottleChecksum{
+			{Cellar: ":any_skip_relocation", Tag: "arm64_sonoma", SHA256: "1df5fdc2bad4ed4c28fbdc77b6c542988c0dc0e2ae34e0dc912bbb1c66646c58"},
+			{Cellar: "/home/linuxbrew/.linuxbrew/Cellar", Tag: "x86_64_linux", SHA256: "1633f61598ab0791e213135923624eb342196b3494909c91899bcd0560f84c67"},
 		},
-		"git_remote": {
-			prepare: func(ctx *context.Context) {
-				ctx.TokenType = context.TokenTypeGitHub
-				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
-				ctx.Config.Brews[0].Repository = config.RepoRef{
-					Name:   "test",
-					Branch: "main",
-					Git: config.GitRepoRef{
-						URL:        testlib.GitMakeBareRepository(t),
-						PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
-					},
-				}
-			},
+	}
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestFullFormulaeDependencyTypes(t *testing.T) {
+	data := defaultTemplateData
+	data.Dependencies = []config.HomebrewDependency{
+		{Name: "bash", Type: "recommended"},
+		{Name: "curl"},
+		{Name: "expect", Type: "test"},
+		{Name: "git"},
+		{Name: "go", Type: "build"},
+		{Name: "zsh", Type: "optional"},
+	}
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestFullFormulaeUsesFromMacOS(t *testing.T) {
+	data := defaultTemplateData
+	data.Dependencies = []config.HomebrewDependency{
+		{Name: "curl", UsesFromMacOS: true},
+		{Name: "python", Type: "build", UsesFromMacOS: true},
+		{Name: "zlib", UsesFromMacOS: true, Since: "catalina"},
+	}
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, `  uses_from_macos "curl"
+  uses_from_macos "python" => :build
+  uses_from_macos "zlib", since: :catalina
+`)
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestFullFormulaeOSDependencies(t *testing.T) {
+	data, err := dataFor(testctx.New(), config.Homebrew{
+		Name: "test",
+		Dependencies: []config.HomebrewDependency{
+			{Name: "openssl", OS: "linux"},
+			{Name: "git"},
+			{Name: "gnu-sed", OS: "macos", Type: "build"},
+			{Name: "glibc", OS: "linux"},
 		},
-		"open_pr": {
-			prepare: func(ctx *context.Context) {
-				ctx.TokenType = context.TokenTypeGitHub
-				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
-				ctx.Config.Brews[0].Repository = config.RepoRef{
-					Owner:  "test",
-					Name:   "test",
-					Branch: "update-{{.Version}}",
-					PullRequest: config.PullRequest{
-						Enabled: true,
+	}, client.NewMock(), nil)
+	require.NoError(t, err)
+	require.Equal(t, []config.HomebrewDependency{{Name: "git"}}, data.Dependencies)
+	require.Equal(t, []config.HomebrewDependency{{Name: "gnu-sed", OS: "macos", Type: "build"}}, data.MacOSDependencies)
+	require.Equal(t, []config.HomebrewDependency{{Name: "glibc", OS: "linux"}, {Name: "openssl", OS: "linux"}}, data.LinuxDependencies)
+
+	tpl := defaultTemplateData
+	tpl.Dependencies = data.Dependencies
+	tpl.MacOSDependencies = data.MacOSDependencies
+	tpl.LinuxDependencies = data.LinuxDependencies
+	formulae, err := doBuildFormula(testctx.New(), tpl)
+	require.NoError(t, err)
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestFullFormulaeSourceBuild(t *testing.T) {
+	data := defaultTemplateData
+	data.LinuxPackages = nil
+	data.MacOSPackages = nil
+	data.Dependencies = []config.HomebrewDependency{{Name: "go", Type: "build"}}
+	data.Source = &releasePackage{
+		DownloadURL: "https://github.com/caarlos0/test/archive/refs/tags/v0.1.3.tar.gz",
+		SHA256:      "1633f61598ab0791e213135923624eb342196b3494909c91899bcd0560f84c67",
+		Install:     []string{`system "go", "build", *std_go_args(ldflags: "-s -w")`},
+	}
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestRunPipeSourceBuild(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/foo/bar/archive/refs/tags/v1.2.1.tar.gz" {
+			fmt.Fprint(w, "source")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	newCtx := func(t *testing.T, tag string) *context.Context {
+		t.Helper()
+		return testctx.NewWithCfg(
+			config.Project{
+				Dist:        t.TempDir(),
+				ProjectName: "foo",
+				GitHubURLs: config.GitHubURLs{
+					Download: srv.URL,
+				},
+				Release: config.Release{
+					GitHub: config.Repo{
+						Owner: "foo",
+						Name:  "bar",
 					},
-				}
+				},
+				Brews: []config.Homebrew{
+					{
+						Name:        "foo",
+						SourceBuild: true,
+						Install:     `system "go", "build", *std_go_args`,
+						Repository: config.RepoRef{
+							Owner: "foo",
+							Name:  "homebrew-tap",
+						},
+					},
+				},
 			},
-		},
-		"custom_download_strategy": {
-			prepare: func(ctx *context.Context) {
-				ctx.TokenType = context.TokenTypeGitHub
-				ctx.Config.Brews[0].Repository.Owner = "test"
-				ctx.Config.Brews[0].Repository.Name = "test"
-				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
+			testctx.WithVersion("1.2.1"),
+			testctx.WithCurrentTag(tag),
+		)
+	}
 
-				ctx.Config.Brews[0].DownloadStrategy = "GitHubPrivateRepositoryReleaseDownloadStrategy"
-			},
-		},
-		"custom_require": {
-			prepare: func(ctx *context.Context) {
-				ctx.TokenType = context.TokenTypeGitHub
-				ctx.Config.Brews[0].Repository.Owner = "test"
-				ctx.Config.Brews[0].Repository.Name = "test"
-				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
+	t.Run("valid", func(t *testing.T) {
+		ctx := newCtx(t, "v1.2.1")
+		client := client.NewMock()
+		require.NoError(t, runAll(ctx, client))
+		require.NoError(t, publishAll(ctx, client))
+		require.Contains(t, client.Content, `  url "`+srv.URL+`/foo/bar/archive/refs/tags/v1.2.1.tar.gz"
+  sha256 "41cf6794ba4200b839c53531555f0f3998df4cbb01a4d5cb0b94e3ca5e23947d"
+`)
+		require.Contains(t, client.Content, `system "go", "build", *std_go_args`)
+		require.NotContains(t, client.Content, "on_macos")
+		require.NotContains(t, client.Content, "on_linux")
+	})
 
-				ctx.Config.Brews[0].DownloadStrategy = "CustomDownloadStrategy"
-				ctx.Config.Brews[0].CustomRequire = "custom_download_strategy"
-			},
-		},
-		"custom_block": {
-			prepare: func(ctx *context.Context) {
-				ctx.TokenType = context.TokenTypeGitHub
-				ctx.Config.Brews[0].Repository.Owner = "test"
-				ctx.Config.Brews[0].Repository.Name = "test"
-				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
+	t.Run("not found", func(t *testing.T) {
+		ctx := newCtx(t, "v1.2.2")
+		require.ErrorContains(t, runAll(ctx, client.NewMock()), "could not download source tarball")
+	})
+}
 
-				ctx.Config.Brews[0].CustomBlock = `head "https://github.com/caarlos0/test.git"`
+func TestBottleFor(t *testing.T) {
+	const sum = "1df5fdc2bad4ed4c28fbdc77b6c542988c0dc0e2ae34e0dc912bbb1c66646c58"
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "bottles.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		path := write(t, `{
+			"foo/tap/foo": {
+				"bottle": {
+					"root_url": "https://ghcr.io/v2/foo/tap",
+					"cellar": "any_skip_relocation",
+					"rebuild": 2,
+					"tags": {
+						"x86_64_linux": {"cellar": "/home/linuxbrew/.linuxbrew/Cellar", "sha256": "`+sum+`"},
+						"arm64_sonoma": {"sha256": "`+sum+`"}
+					}
+				}
 			},
-		},
-		"default_gitlab": {
-			prepare: func(ctx *context.Context) {
-				ctx.TokenType = context.TokenTypeGitLab
-				ctx.Config.Brews[0].Repository.Owner = "test"
-				ctx.Config.Brews[0].Repository.Name = "test"
-				ctx.Config.Brews[0].Homepage = "https://gitlab.com/goreleaser"
+			"bar": {"bottle": {"tags": {"sonoma": {"sha256": "`+sum+`"}}}}
+		}`)
+		got, err := bottleFor(testctx.New(), config.Homebrew{Name: "foo", BottleManifest: path})
+		require.NoError(t, err)
+		require.Equal(t, &bottle{
+			RootURL: "https://ghcr.io/v2/foo/tap",
+			Rebuild: 2,
+			Checksums: []bottleChecksum{
+				{Cellar: ":any_skip_relocation", Tag: "arm64_sonoma", SHA256: sum},
+				{Cellar: "/home/linuxbrew/.linuxbrew/Cellar", Tag: "x86_64_linux", SHA256: sum},
 			},
+		}, got)
+	})
+
+	for name, tt := range map[string]struct {
+		content string
+		err     string
+	}{
+		"invalid json": {
+			content: `{`,
+			err:     "bottle_manifest: invalid manifest",
 		},
-		"invalid_commit_template": {
-			prepare: func(ctx *context.Context) {
-				ctx.Config.Brews[0].Repository.Owner = "test"
-				ctx.Config.Brews[0].Repository.Name = "test"
-				ctx.Config.Brews[0].CommitMessageTemplate = "{{ .Asdsa }"
-			},
-			expectedPublishError: `template: tmpl:1: unexpected "}" in operand`,
+		"not found": {
+			content: `{"bar": {}, "baz": {}}`,
+			err:     "bottle_manifest: no bottle found for foo",
 		},
-		"valid_repository_templates": {
-			prepare: func(ctx *context.Context) {
-				ctx.TokenType = context.TokenTypeGitHub
-				ctx.Env = map[string]string{
-					"FOO": "templated",
-				}
-				ctx.Config.Brews[0].Repository.Owner = "{{.Env.FOO}}"
-				ctx.Config.Brews[0].Repository.Name = "{{.Env.FOO}}"
-			},
+		"no tags": {
+			content: `{"foo": {"bottle": {}}}`,
+			err:     "bottle_manifest: foo has no bottle tags",
 		},
-		"invalid_repository_name_template": {
-			prepare: func(ctx *context.Context) {
-				ctx.Config.Brews[0].Repository.Owner = "test"
-				ctx.Config.Brews[0].Repository.Name = "{{ .Asdsa }"
-			},
-			expectedRunError: `template: tmpl:1: unexpected "}" in operand`,
+		"invalid tag": {
+			content: `{"foo": {"bottle": {"tags": {"Sonoma!": {"sha256": "` + sum + `"}}}}}`,
+			err:     `bottle_manifest: foo: invalid tag "Sonoma!"`,
 		},
-		"invalid_repository_owner_template": {
-			prepare: func(ctx *context.Context) {
-				ctx.Config.Brews[0].Repository.Owner = "{{ .Asdsa }"
-				ctx.Config.Brews[0].Repository.Name = "test"
-			},
-			expectedRunError: `template: tmpl:1: unexpected "}" in operand`,
+		"invalid sha256": {
+			content: `{"foo": {"bottle": {"tags": {"sonoma": {"sha256": "abc"}}}}}`,
+			err:     `bottle_manifest: foo: invalid sha256 for sonoma: "abc"`,
 		},
-		"invalid_repository_skip_upload_template": {
-			prepare: func(ctx *context.Context) {
-				ctx.Config.Brews[0].SkipUpload = "{{ .Asdsa }"
-				ctx.Config.Brews[0].Repository.Owner = "test"
-				ctx.Config.Brews[0].Repository.Name = "test"
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := bottleFor(testctx.New(), config.Homebrew{Name: "foo", BottleManifest: write(t, tt.content)})
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := bottleFor(testctx.New(), config.Homebrew{Name: "foo", BottleManifest: filepath.Join(t.TempDir(), "nope.json")})
+		require.ErrorContains(t, err, "bottle_manifest:")
+	})
+}
+
+func TestBottleFromConfig(t *testing.T) {
+	const sum = "1df5fdc2bad4ed4c28fbdc77b6c542988c0dc0e2ae34e0dc912bbb1c66646c58"
+	ctx := testctx.New(
+		testctx.WithVersion("1.2.3"),
+		testctx.WithEnv(map[string]string{"BOTTLE_SHA256": sum}),
+	)
+
+	t.Run("valid", func(t *testing.T) {
+		data, err := dataFor(ctx, config.Homebrew{
+			Name: "foo",
+			Bottle: config.HomebrewBottle{
+				RootURL: "https://ghcr.io/v2/foo/tap/{{ .Version }}",
+				Checksums: []config.HomebrewBottleChecksum{
+					{Tag: "arm64_sonoma", Cellar: "any_skip_relocation", SHA256: "{{ .Env.BOTTLE_SHA256 }}"},
+					{Tag: "x86_64_linux", Cellar: "/home/linuxbrew/.linuxbrew/Cellar", SHA256: sum},
+				},
 			},
-			expectedRunError: `template: tmpl:1: unexpected "}" in operand`,
+		}, client.NewMock(), nil)
+		require.NoError(t, err)
+		formulae, err := doBuildFormula(ctx, data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, `
+  bottle do
+    root_url "https://ghcr.io/v2/foo/tap/1.2.3"
+    sha256 cellar: :any_skip_relocation, arm64_sonoma: "`+sum+`"
+    sha256 cellar: "/home/linuxbrew/.linuxbrew/Cellar", x86_64_linux: "`+sum+`"
+  end
+`)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		data, err := dataFor(ctx, config.Homebrew{Name: "foo"}, client.NewMock(), nil)
+		require.NoError(t, err)
+		require.Nil(t, data.Bottle)
+	})
+
+	for name, tt := range map[string]struct {
+		sum config.HomebrewBottleChecksum
+		err string
+	}{
+		"invalid tag": {
+			sum: config.HomebrewBottleChecksum{Tag: "Sonoma!", SHA256: sum},
+			err: `bottle: invalid tag "Sonoma!"`,
 		},
-		"invalid_install_template": {
-			prepare: func(ctx *context.Context) {
-				ctx.Config.Brews[0].Repository.Owner = "test"
-				ctx.Config.Brews[0].Repository.Name = "test"
-				ctx.Config.Brews[0].Install = "{{ .aaaa }"
-			},
-			expectedRunError: `template: tmpl:1: unexpected "}" in operand`,
+		"invalid sha256": {
+			sum: config.HomebrewBottleChecksum{Tag: "sonoma", SHA256: "abc"},
+			err: `bottle: invalid sha256 for sonoma: "abc"`,
 		},
 	} {
 		t.Run(name, func(t *testing.T) {
-			folder := t.TempDir()
-			ctx := testctx.NewWithCfg(
-				config.Project{
-					Dist:        folder,
-					ProjectName: name,
-					Brews: []config.Homebrew{
-						{
-							Name: name,
-							IDs: []string{
-								"foo",
-							},
-							Description: "Run pipe test formula and FOO={{ .Env.FOO }}",
-							Caveats:     "don't do this {{ .ProjectName }}",
-							Test:        "system \"true\"\nsystem \"#{bin}/foo\", \"-h\"",
-							Plist:       `<xml>whatever</xml>`,
-							Dependencies: []config.HomebrewDependency{
-								{Name: "zsh", Type: "optional"},
-								{Name: "bash", Version: "3.2.57"},
-								{Name: "fish", Type: "optional", Version: "v1.2.3"},
-							},
-							Conflicts:   []string{"gtk+", "qt"},
-							Service:     "run foo/bar\nkeep_alive true",
-							PostInstall: "system \"echo\"\ntouch \"/tmp/hi\"",
-							Install:     `bin.install "{{ .ProjectName }}_{{.Os}}_{{.Arch}} => {{.ProjectName}}"`,
-							Goamd64:     "v1",
-						},
-					},
-					Env: []string{"FOO=foo_is_bar"},
-				},
-				testctx.WithVersion("1.0.1"),
-				testctx.WithCurrentTag("v1.0.1"),
-			)
-			tt.prepare(ctx)
-			ctx.Artifacts.Add(&artifact.Artifact{
-				Name:    "bar_bin.tar.gz",
-				Path:    "doesnt matter",
-				Goos:    "darwin",
-				Goarch:  "amd64",
-				Goamd64: "v1",
-				Type:    artifact.UploadableArchive,
-				Extra: map[string]interface{}{
-					artifact.ExtraID:     "bar",
-					artifact.ExtraFormat: "tar.gz",
-				},
-			})
-			path := filepath.Join(folder, "bin.tar.gz")
-			ctx.Artifacts.Add(&artifact.Artifact{
-				Name:    "bin.tar.gz",
-				Path:    path,
-				Goos:    "darwin",
-				Goarch:  "amd64",
-				Goamd64: "v1",
-				Type:    artifact.UploadableArchive,
-				Extra: map[string]interface{}{
-					artifact.ExtraID:     "foo",
-					artifact.ExtraFormat: "tar.gz",
-				},
-			})
-			ctx.Artifacts.Add(&artifact.Artifact{
-				Name:   "bin.tar.gz",
-				Path:   path,
-				Goos:   "darwin",
-				Goarch: "arm64",
-				Type:   artifact.UploadableArchive,
-				Extra: map[string]interface{}{
-					artifact.ExtraID:     "foo",
-					artifact.ExtraFormat: "tar.gz",
-				},
-			})
-			ctx.Artifacts.Add(&artifact.Artifact{
-				Name:    "bin.tar.gz",
-				Path:    path,
-				Goos:    "linux",
-				Goarch:  "amd64",
-				Goamd64: "v1",
-				Type:    artifact.UploadableArchive,
-				Extra: map[string]interface{}{
-					artifact.ExtraID:     "foo",
-					artifact.ExtraFormat: "tar.gz",
-				},
-			})
+			_, err := bottleFromConfig(ctx, config.HomebrewBottle{Checksums: []config.HomebrewBottleChecksum{tt.sum}})
+			require.EqualError(t, err, tt.err)
+		})
+	}
 
-			f, err := os.Create(path)
-			require.NoError(t, err)
-			require.NoError(t, f.Close())
-			client := client.NewMock()
-			distFile := filepath.Join(folder, "homebrew", name+".rb")
+	t.Run("invalid template", func(t *testing.T) {
+		_, err := bottleFromConfig(ctx, config.HomebrewBottle{Checksums: []config.HomebrewBottleChecksum{{Tag: "{{ .Nope }"}}})
+		testlib.RequireTemplateError(t, err)
+	})
+}
 
-			require.NoError(t, Pipe{}.Default(ctx))
+func TestFullFormulaeTestConfig(t *testing.T) {
+	data := defaultTemplateData
+	data.TestConfig = config.HomebrewTestConfig{
+		Fixtures: []config.HomebrewTestFixture{
+			{Path: "input.txt", Content: "hello world"},
+		},
+		Command: "#{bin}/{{ .ProjectName }} count input.txt",
+		Output:  "2",
+	}
+	formulae, err := doBuildFormula(testctx.NewWithCfg(config.Project{
+		ProjectName: "foo",
+	}), data)
+	require.NoError(t, err)
 
-			if tt.expectedRunError == "" {
-				require.NoError(t, runAll(ctx, client))
-			} else {
-				require.EqualError(t, runAll(ctx, client), tt.expectedRunError)
-				return
-			}
-			if tt.expectedPublishError != "" {
-				require.EqualError(t, publishAll(ctx, client), tt.expectedPublishError)
-				return
-			}
+	golden.RequireEqualRb(t, []byte(formulae))
+}
 
-			require.NoError(t, publishAll(ctx, client))
+func TestFullFormulaeLivecheck(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		formulae, err := doBuildFormula(testctx.New(), defaultTemplateData)
+		require.NoError(t, err)
+		require.NotContains(t, formulae, "livecheck")
+	})
 
-			content := []byte(client.Content)
-			if url := ctx.Config.Brews[0].Repository.Git.URL; url == "" {
-				require.True(t, client.CreatedFile, "should have created a file")
-			} else {
-				content = testlib.CatFileFromBareRepository(t, url, name+".rb")
-			}
+	t.Run("full", func(t *testing.T) {
+		data := defaultTemplateData
+		data.Livecheck = &livecheck{
+			URL:      "https://github.com/caarlos0/test/releases",
+			Strategy: "github_releases",
+			Regex:    `^v?(\d+(?:\.\d+)+)$`,
+		}
+		formulae, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
 
-			golden.RequireEqualRb(t, content)
+		golden.RequireEqualRb(t, []byte(formulae))
+	})
+}
 
-			distBts, err := os.ReadFile(distFile)
-			require.NoError(t, err)
-			require.Equal(t, string(content), string(distBts))
+func TestLivecheckFor(t *testing.T) {
+	ctx := testctx.New(testctx.WithVersion("1.2.3"))
+
+	t.Run("empty", func(t *testing.T) {
+		got, err := livecheckFor(ctx, config.HomebrewLivecheck{})
+		require.NoError(t, err)
+		require.Nil(t, got)
+	})
+
+	t.Run("only url", func(t *testing.T) {
+		got, err := livecheckFor(ctx, config.HomebrewLivecheck{URL: ":stable"})
+		require.NoError(t, err)
+		require.Equal(t, &livecheck{URL: ":stable", Strategy: "github_latest"}, got)
+		require.True(t, got.URLIsSymbol())
+	})
+
+	t.Run("templated", func(t *testing.T) {
+		got, err := livecheckFor(ctx, config.HomebrewLivecheck{
+			URL:      "https://example.com/{{ .Version }}",
+			Strategy: ":page_match",
+			Regex:    "foo-{{ .Version }}",
 		})
-	}
-}
+		require.NoError(t, err)
+		require.Equal(t, &livecheck{
+			URL:      "https://example.com/1.2.3",
+			Strategy: "page_match",
+			Regex:    "foo-1.2.3",
+		}, got)
+		require.False(t, got.URLIsSymbol())
+	})
 
-func TestRunPipeNameTemplate(t *testing.T) {
-	folder := t.TempDir()
-	ctx := testctx.NewWithCfg(
-		config.Project{
-			Dist:        folder,
-			ProjectName: "foo",
-			Brews: []config.Homebrew{
-				{
-					Name:        "foo_{{ .Env.FOO_BAR }}",
-					Description: "Foo bar",
-					Homepage:    "https://goreleaser.com",
-					Goamd64:     "v1",
-					Install:     `bin.install "foo"`,
-					Repository: config.RepoRef{
-						Owner: "foo",
-						Name:  "bar",
-					},
-					IDs: []string{
-						"foo",
-					},
-				},
-			},
-			Env: []string{"FOO_BAR=is_bar"},
-		},
-		testctx.WithVersion("1.0.1"),
-		testctx.WithCurrentTag("v1.0.1"),
-	)
-	path := filepath.Join(folder, "bin.tar.gz")
-	ctx.Artifacts.Add(&artifact.Artifact{
-		Name:    "bin.tar.gz",
-		Path:    path,
-		Goos:    "darwin",
-		Goarch:  "amd64",
-		Goamd64: "v1",
-		Type:    artifact.UploadableArchive,
-		Extra: map[string]interface{}{
-			artifact.ExtraID:     "foo",
-			artifact.ExtraFormat: "tar.gz",
-		},
+	t.Run("slashes", func(t *testing.T) {
+		got, err := livecheckFor(ctx, config.HomebrewLivecheck{
+			Regex: `href=.*?/v?(\d+(?:\.\d+)+)/foo\/bar\\/`,
+		})
+		require.NoError(t, err)
+		require.Equal(t, `href=.*?\/v?(\d+(?:\.\d+)+)\/foo\/bar\\\/`, got.Regex)
 	})
 
-	f, err := os.Create(path)
+	t.Run("invalid template", func(t *testing.T) {
+		_, err := livecheckFor(ctx, config.HomebrewLivecheck{Regex: "{{ .Foo }"})
+		testlib.RequireTemplateError(t, err)
+	})
+}
+
+func TestFullFormulaeInlinePatch(t *testing.T) {
+	const patch = "diff --git a/main.go b/main.go\n" +
+		"--- a/main.go\n" +
+		"+++ b/main.go\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" package main\n" +
+		" \n" +
+		"-var msg = \"{{ .Version }}\"  \n" +
+		"+var msg = \"hi\""
+	data := defaultTemplateData
+	data.InlinePatch = patch
+	formulae, err := doBuildFormula(testctx.New(), data)
 	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	client := client.NewMock()
-	distFile := filepath.Join(folder, "homebrew", "foo_is_bar.rb")
+	require.True(t, strings.HasSuffix(formulae, "end\n__END__\n"+patch+"\n"))
 
-	require.NoError(t, runAll(ctx, client))
-	require.NoError(t, publishAll(ctx, client))
-	require.True(t, client.CreatedFile)
-	golden.RequireEqualRb(t, []byte(client.Content))
-	distBts, err := os.ReadFile(distFile)
+	golden.RequireEqualRb(t, []byte(formulae))
+
+	t.Run("crlf", func(t *testing.T) {
+		patch := strings.ReplaceAll(patch, "\n", "\r\n")
+		data := defaultTemplateData
+		data.InlinePatch = patch
+		formulae, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
+		require.True(t, strings.HasSuffix(formulae, "end\n__END__\n"+patch+"\r\n"))
+	})
+}
+
+func TestFullFormulaePlatformCaveatsAndPostInstall(t *testing.T) {
+	data := defaultTemplateData
+	data.Caveats = []string{"Global caveat"}
+	data.MacOSCaveats = []string{"Allow it in the Security settings", "if macOS blocks it"}
+	data.PostInstall = []string{`touch "global"`}
+	data.LinuxPostInstall = []string{`system "setcap", "cap_net_raw+ep", bin/"test"`}
+	formulae, err := doBuildFormula(testctx.New(), data)
 	require.NoError(t, err)
-	require.Equal(t, client.Content, string(distBts))
+
+	golden.RequireEqualRb(t, []byte(formulae))
 }
 
-func TestRunPipeMultipleBrewsWithSkip(t *testing.T) {
-	folder := t.TempDir()
-	ctx := testctx.NewWithCfg(
-		config.Project{
-			Dist:        folder,
-			ProjectName: "foo",
-			Brews: []config.Homebrew{
-				{
-					Name:    "foo",
-					Goamd64: "v1",
-					Repository: config.RepoRef{
-						Owner: "foo",
-						Name:  "bar",
-					},
-					IDs: []string{
-						"foo",
-					},
-					SkipUpload: "true",
-				},
-				{
-					Name:    "bar",
-					Goamd64: "v1",
-					Repository: config.RepoRef{
-						Owner: "foo",
-						Name:  "bar",
-					},
-					IDs: []string{
-						"foo",
-					},
-				},
-				{
-					Name:    "foobar",
-					Goamd64: "v1",
-					Repository: config.RepoRef{
-						Owner: "foo",
-						Name:  "bar",
-					},
-					IDs: []string{
-						"foo",
-					},
-					SkipUpload: "true",
-				},
-				{
-					Name:    "baz",
-					Goamd64: "v1",
-					Repository: config.RepoRef{
-						Owner: "foo",
-						Name:  "bar",
-					},
-					IDs: []string{
-						"foo",
-					},
-					SkipUpload: "{{ .Env.SKIP_UPLOAD }}",
-				},
-			},
-			Env: []string{
-				"FOO_BAR=is_bar",
-				"SKIP_UPLOAD=true",
-			},
-		},
-		testctx.WithVersion("1.0.1"),
-		testctx.WithCurrentTag("v1.0.1"),
-	)
-	path := filepath.Join(folder, "bin.tar.gz")
-	ctx.Artifacts.Add(&artifact.Artifact{
-		Name:    "bin.tar.gz",
+func TestFullFormulaeCustomTemplate(t *testing.T) {
+	ctx := testctx.New(testctx.WithVersion("1.2.3"))
+
+	t.Run("custom", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "formula.rb.tmpl")
+		require.NoError(t, os.WriteFile(file, []byte(`class {{ .Name }} < Formula
+  url {{ quote (index .LinuxPackages 0).DownloadURL }}
+  version "{{ "{{ .Version }}" }}"
+end
+`), 0o644))
+		data := defaultTemplateData
+		data.Name = "Test"
+		data.Template = file
+		formulae, err := doBuildFormula(ctx, data)
+		require.NoError(t, err)
+		require.Equal(t, `class Test < Formula
+  url "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Linux_x86_64.tar.gz"
+  version "1.2.3"
+end
+`, formulae)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		data := defaultTemplateData
+		data.Template = filepath.Join(t.TempDir(), "nope.rb.tmpl")
+		_, err := doBuildFormula(ctx, data)
+		require.ErrorIs(t, err, os.ErrNotExist)
+		require.ErrorContains(t, err, "brew: failed to read template")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "formula.rb.tmpl")
+		require.NoError(t, os.WriteFile(file, []byte(`class {{ .Name }`), 0o644))
+		data := defaultTemplateData
+		data.Template = file
+		_, err := doBuildFormula(ctx, data)
+		require.Error(t, err)
+	})
+}
+
+func TestFullFormulaeCommitComment(t *testing.T) {
+	ctx := testctx.New(testctx.WithGitInfo(context.GitInfo{ShortCommit: "abc1234"}))
+
+	t.Run("disabled", func(t *testing.T) {
+		data, err := dataFor(ctx, config.Homebrew{Name: "test"}, client.NewMock(), nil)
+		require.NoError(t, err)
+		require.Empty(t, data.Commit)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		data, err := dataFor(ctx, config.Homebrew{Name: "test", IncludeCommitComment: true}, client.NewMock(), nil)
+		require.NoError(t, err)
+		require.Equal(t, "abc1234", data.Commit)
+	})
+
+	data := defaultTemplateData
+	data.Commit = "abc1234"
+	formulae, err := doBuildFormula(ctx, data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, "# This file was generated by GoReleaser. DO NOT EDIT.\n# built from abc1234\n")
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestFullFormulaeNoAutobump(t *testing.T) {
+	for name, tt := range map[string]struct {
+		reason   string
+		expected string
+	}{
+		"symbol":          {":requires_manual_review", ":requires_manual_review"},
+		"symbol no colon": {"latest_version", ":latest_version"},
+		"string":          {"the version is bumped by hand", `"the version is bumped by hand"`},
+		"disabled":        {"", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tt.expected, noAutobumpReason(config.Homebrew{NoAutobump: tt.reason}))
+		})
+	}
+
+	data := defaultTemplateData
+	data.NoAutobump = ":requires_manual_review"
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, "\n  no_autobump! because: :requires_manual_review\n")
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestBuildFormula(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(config.Project{
+		Dist:        folder,
+		ProjectName: "foo",
+	}, testctx.WithVersion("1.2.1"), testctx.WithCurrentTag("v1.2.1"))
+	path := filepath.Join(t.TempDir(), "foo.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("foo"), 0o644))
+	archive := &artifact.Artifact{
+		Name:    "foo.tar.gz",
 		Path:    path,
 		Goos:    "darwin",
-		Goarch:  "amd64",
-		Goamd64: "v1",
+		Goarch:  "arm64",
+		Goarm64: "v8.0",
 		Type:    artifact.UploadableArchive,
 		Extra: map[string]interface{}{
-			artifact.ExtraID:     "foo",
-			artifact.ExtraFormat: "tar.gz",
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	}
+
+	formulae, err := BuildFormula(ctx, config.Homebrew{
+		Name:        "foo",
+		Description: "A foo formula",
+	}, client.NewMock(), []*artifact.Artifact{archive})
+	require.NoError(t, err)
+	require.Contains(t, formulae, "class Foo < Formula\n")
+	require.Contains(t, formulae, `desc "A foo formula"`)
+	require.Contains(t, formulae, `url "https://dummyhost/download/v1.2.1/foo.tar.gz"`)
+	require.Contains(t, formulae, `sha256 "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"`)
+	require.Contains(t, formulae, `bin.install "foo"`)
+
+	require.Empty(t, ctx.Artifacts.List())
+	entries, err := os.ReadDir(folder)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestBuildFormulaFormatCommand(t *testing.T) {
+	ctx := testctx.New(testctx.WithVersion("1.2.1"), testctx.WithCurrentTag("v1.2.1"))
+	path := filepath.Join(t.TempDir(), "foo.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("foo"), 0o644))
+	formulae, err := BuildFormula(ctx, config.Homebrew{
+		Name:          "foo",
+		FormatCommand: `sh -c 'echo "# formatted" >> "$FORMULA_PATH"'`,
+	}, client.NewMock(), []*artifact.Artifact{{
+		Name:   "foo.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
 		},
+	}})
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(formulae, "end\n# formatted\n"))
+}
+
+func TestFullFormulaePreInstall(t *testing.T) {
+	data := defaultTemplateData
+	data.PreInstall = []string{`system "true"`, `(var/"test").mkpath`}
+	data.PostInstall = []string{`touch "/tmp/hi"`}
+	data.HeadInstall = []string{`system "go", "build", *std_go_args`}
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+	require.NotContains(t, formulae, "def pre_install")
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestFullFormulaeSkipSanitize(t *testing.T) {
+	data := defaultTemplateData
+	data.CustomBlock = []string{
+		"def message",
+		"  <<~EOS",
+		"    keep these  ",
+		"  EOS",
+		"end",
+	}
+
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, "\n      keep these\n")
+
+	data.SkipSanitize = true
+	formulae, err = doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, "\n      keep these  \n")
+}
+
+func TestFullFormulaeLineEnding(t *testing.T) {
+	lf, err := doBuildFormula(testctx.New(), defaultTemplateData)
+	require.NoError(t, err)
+	require.NotContains(t, lf, "\r")
+
+	t.Run("lf", func(t *testing.T) {
+		data := defaultTemplateData
+		data.LineEnding = "lf"
+		formulae, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
+		require.Equal(t, []byte(lf), []byte(formulae))
 	})
 
-	f, err := os.Create(path)
+	t.Run("crlf", func(t *testing.T) {
+		data := defaultTemplateData
+		data.LineEnding = "crlf"
+		formulae, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
+		require.Equal(t, []byte(strings.ReplaceAll(lf, "\n", "\r\n")), []byte(formulae))
+	})
+}
+
+func TestFullFormulaeIndentWidth(t *testing.T) {
+	data := defaultTemplateData
+	data.IndentWidth = 4
+	data.Caveats = []string{"Nested caveat"}
+	formulae, err := doBuildFormula(testctx.New(), data)
 	require.NoError(t, err)
-	require.NoError(t, f.Close())
+	require.Contains(t, formulae, "\nclass Test < Formula\n    desc \"Some desc\"\n")
+	require.Contains(t, formulae, "\n    on_macos do\n        if Hardware::CPU.intel?\n            url ")
+	for _, line := range strings.Split(formulae, "\n") {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		require.Zero(t, indent%4, "line not indented with 4 spaces: %q", line)
+	}
 
-	cli := client.NewMock()
-	require.NoError(t, runAll(ctx, cli))
-	require.EqualError(t, publishAll(ctx, cli), `brew.skip_upload is set`)
-	require.True(t, cli.CreatedFile)
+	golden.RequireEqualRb(t, []byte(formulae))
 
-	for _, brew := range ctx.Config.Brews {
-		distFile := filepath.Join(folder, "homebrew", brew.Name+".rb")
-		_, err := os.Stat(distFile)
-		require.NoError(t, err, "file should exist: "+distFile)
+	t.Run("default", func(t *testing.T) {
+		data := defaultTemplateData
+		expected, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
+		data.IndentWidth = 2
+		formulae, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
+		require.Equal(t, expected, formulae)
+	})
+}
+
+func TestReindent(t *testing.T) {
+	for line, expected := range map[string]string{
+		"":                    "",
+		"class Foo < Formula": "class Foo < Formula",
+		"  desc \"foo\"":      "    desc \"foo\"",
+		"    url \"foo\"":     "        url \"foo\"",
+		"   aligned":          "     aligned",
+	} {
+		require.Equal(t, expected, reindent(line, 4))
 	}
+	require.Equal(t, "  desc", reindent("  desc", 0))
 }
 
-func TestRunPipeForMultipleAmd64Versions(t *testing.T) {
-	for name, fn := range map[string]func(ctx *context.Context){
-		"v1": func(ctx *context.Context) {
-			ctx.Config.Brews[0].Goamd64 = "v1"
+func TestFullFormulaeCompat3(t *testing.T) {
+	data := defaultTemplateData
+	data.LegacyOS = true
+	data.LinuxDependencies = []config.HomebrewDependency{{Name: "glibc", OS: "linux"}}
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+	require.NotContains(t, formulae, "on_macos")
+	require.NotContains(t, formulae, "on_linux")
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestFullFormulaeBinaryTests(t *testing.T) {
+	data := defaultTemplateData
+	data.Tests = []string{`system "true"`}
+	data.TestConfig = config.HomebrewTestConfig{
+		Fixtures: []config.HomebrewTestFixture{
+			{Path: "input.txt", Content: "hello world"},
 		},
-		"v2": func(ctx *context.Context) {
-			ctx.Config.Brews[0].Goamd64 = "v2"
+		Binaries: []config.HomebrewBinaryTest{
+			{Binary: "foo", Args: "--version", Output: "{{ .Version }}"},
+			{Binary: "foo-cli", Args: "count input.txt", Output: "2"},
+			{Binary: "bar"},
 		},
-		"v3": func(ctx *context.Context) {
-			ctx.Config.Brews[0].Goamd64 = "v3"
+	}
+	formulae, err := doBuildFormula(testctx.New(testctx.WithVersion("0.1.3")), data)
+	require.NoError(t, err)
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestFullFormulaeBinaryTestsSingleQuotes(t *testing.T) {
+	data := defaultTemplateData
+	data.QuoteStyle = "single"
+	data.TestConfig = config.HomebrewTestConfig{
+		Binaries: []config.HomebrewBinaryTest{
+			{Binary: "foo", Args: "--version", Output: "{{ .Version }}"},
+			{Binary: "bar"},
 		},
-		"v4": func(ctx *context.Context) {
-			ctx.Config.Brews[0].Goamd64 = "v4"
+	}
+	formulae, err := doBuildFormula(testctx.New(testctx.WithVersion("0.1.3")), data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, `assert_match '0.1.3', shell_output("#{bin}/foo --version")`)
+	require.Contains(t, formulae, `system "#{bin}/bar"`)
+}
+
+func TestFullFormulaeStructuredTests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("fake"), 0o644))
+	artifacts := []*artifact.Artifact{{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
 		},
-	} {
-		t.Run(name, func(t *testing.T) {
-			folder := t.TempDir()
-			ctx := testctx.NewWithCfg(
-				config.Project{
-					Dist:        folder,
-					ProjectName: name,
-					Brews: []config.Homebrew{
-						{
-							Name:        name,
-							Description: "Run pipe test formula",
-							Repository: config.RepoRef{
-								Owner: "test",
-								Name:  "test",
-							},
-							Homepage:     "https://github.com/goreleaser",
-							Install:      `bin.install "foo"`,
-							ExtraInstall: `man1.install "./man/foo.1.gz"`,
-						},
-					},
-					GitHubURLs: config.GitHubURLs{
-						Download: "https://github.com",
-					},
-					Release: config.Release{
-						GitHub: config.Repo{
-							Owner: "test",
-							Name:  "test",
-						},
-					},
-					Env: []string{"FOO=foo_is_bar"},
-				},
-				testctx.GitHubTokenType,
-				testctx.WithVersion("1.0.1"),
-				testctx.WithCurrentTag("v1.0.1"),
-			)
-			fn(ctx)
-			for _, a := range []struct {
-				name    string
-				goos    string
-				goarch  string
-				goamd64 string
-			}{
-				{
-					name:   "bin",
-					goos:   "darwin",
-					goarch: "arm64",
-				},
-				{
-					name:   "arm64",
-					goos:   "linux",
-					goarch: "arm64",
-				},
-				{
-					name:    "amd64v2",
-					goos:    "linux",
-					goarch:  "amd64",
-					goamd64: "v1",
-				},
-				{
-					name:    "amd64v2",
-					goos:    "linux",
-					goarch:  "amd64",
-					goamd64: "v2",
-				},
-				{
-					name:    "amd64v3",
-					goos:    "linux",
-					goarch:  "amd64",
-					goamd64: "v3",
+	}}
+	cfg := config.Homebrew{
+		Name:        "foo",
+		Description: "Foo",
+		Homepage:    "https://goreleaser.com",
+		URLTemplate: "https://example.com/{{ .ArtifactName }}",
+		HelpTest:    true,
+		Test:        `system "#{bin}/foo", "doctor"`,
+		Tests: []config.HomebrewTest{
+			{Command: "--version", Match: "{{ .Version }}"},
+			{Binary: "foo", Command: `greet "world"`, Match: "hello world"},
+			{Command: "check"},
+		},
+	}
+
+	formulae, err := buildFormula(testctx.New(testctx.WithVersion("1.0.0")), cfg, client.NewMock(), artifacts)
+	require.NoError(t, err)
+	require.Contains(t, formulae, `
+  test do
+    assert_match version.to_s, shell_output("#{bin}/foo --version")
+    assert_match "hello world", shell_output("#{bin}/foo greet \"world\"")
+    system "#{bin}/foo check"
+    system "#{bin}/foo", "doctor"
+  end
+`)
+	require.NotContains(t, formulae, "--help")
+
+	t.Run("no binary", func(t *testing.T) {
+		_, err := buildFormula(testctx.New(), cfg, client.NewMock(), nil)
+		require.EqualError(t, err, "brew: could not guess the binary of tests[0]: set its binary instead")
+	})
+}
+
+func TestFullFormulaeDownloadStrategyRequire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("fake"), 0o644))
+	artifacts := []*artifact.Artifact{{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	}}
+	build := func(tb testing.TB, cfg config.Homebrew) string {
+		tb.Helper()
+		cfg.Name = "foo"
+		cfg.URLTemplate = "https://example.com/{{ .ArtifactName }}"
+		formulae, err := buildFormula(testctx.New(), cfg, client.NewMock(), artifacts)
+		require.NoError(tb, err)
+		return formulae
+	}
+
+	t.Run("vendored strategy", func(t *testing.T) {
+		formulae := build(t, config.Homebrew{
+			DownloadStrategy: "GitHubPrivateRepositoryReleaseDownloadStrategy",
+		})
+		require.Contains(t, formulae, "require_relative \"lib/custom_download_strategy\"\nclass Foo < Formula")
+		require.Contains(t, formulae, ", using: GitHubPrivateRepositoryReleaseDownloadStrategy")
+	})
+
+	t.Run("vendored strategy in folder", func(t *testing.T) {
+		formulae := build(t, config.Homebrew{
+			DownloadStrategy: "S3DownloadStrategy",
+			Folder:           "Formula",
+		})
+		require.Contains(t, formulae, `require_relative "../lib/custom_download_strategy"`)
+	})
+
+	t.Run("explicit custom require", func(t *testing.T) {
+		formulae := build(t, config.Homebrew{
+			DownloadStrategy: "GitHubPrivateRepositoryReleaseDownloadStrategy",
+			CustomRequire:    "my_strategy",
+		})
+		require.Contains(t, formulae, `require_relative "my_strategy"`)
+		require.Equal(t, 1, strings.Count(formulae, "require_relative"))
+	})
+
+	t.Run("built-in strategy", func(t *testing.T) {
+		formulae := build(t, config.Homebrew{
+			DownloadStrategy: "CurlDownloadStrategy",
+		})
+		require.NotContains(t, formulae, "require_relative")
+	})
+
+	t.Run("unknown strategy", func(t *testing.T) {
+		formulae := build(t, config.Homebrew{
+			DownloadStrategy: "MyDownloadStrategy",
+		})
+		require.NotContains(t, formulae, "require_relative")
+	})
+}
+
+func TestRunPipeDeprecateRenamed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("fake"), 0o644))
+	formulae, err := buildFormula(testctx.New(testctx.WithVersion("1.0.0")), config.Homebrew{
+		Name:        "foo",
+		Description: "Foo",
+		Homepage:    "https://goreleaser.com",
+		URLTemplate: "https://example.com/{{ .ArtifactName }}",
+		Caveats:     "Some caveat",
+		Deprecate: config.HomebrewDeprecate{
+			RenamedTo: "bar",
+		},
+	}, client.NewMock(), []*artifact.Artifact{{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	}})
+	require.NoError(t, err)
+
+	golden.RequireEqualRb(t, []byte(formulae))
+}
+
+func TestDirectives(t *testing.T) {
+	ctx := testctx.New(testctx.WithVersion("1.0.0"), testctx.WithEnv(map[string]string{"EOL": "2024-01-31"}))
+
+	t.Run("deprecate", func(t *testing.T) {
+		data, err := dataFor(ctx, config.Homebrew{
+			Name: "foo",
+			Deprecate: config.HomebrewDeprecate{
+				Date:   "{{ .Env.EOL }}",
+				Reason: "is replaced by {{ .ProjectName }}-ng",
+			},
+		}, client.NewMock(), nil)
+		require.NoError(t, err)
+		require.Nil(t, data.DisableDirective)
+		formulae, err := doBuildFormula(ctx, data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, "\n  deprecate! date: \"2024-01-31\", because: \"is replaced by -ng\"\n")
+		require.NotContains(t, formulae, "disable!")
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		data, err := dataFor(ctx, config.Homebrew{
+			Name: "foo",
+			Disable: config.HomebrewDisable{
+				Date:   "2024-01-31",
+				Reason: ":unmaintained",
+			},
+		}, client.NewMock(), nil)
+		require.NoError(t, err)
+		require.Nil(t, data.DeprecateDirective)
+		formulae, err := doBuildFormula(ctx, data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, "\n  disable! date: \"2024-01-31\", because: :unmaintained\n")
+		require.NotContains(t, formulae, "deprecate!")
+	})
+
+	t.Run("none", func(t *testing.T) {
+		data, err := dataFor(ctx, config.Homebrew{Name: "foo"}, client.NewMock(), nil)
+		require.NoError(t, err)
+		require.Nil(t, data.DeprecateDirective)
+		require.Nil(t, data.DisableDirective)
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		_, err := dataFor(ctx, config.Homebrew{
+			Name:    "foo",
+			Disable: config.HomebrewDisable{Date: "tomorrow", Reason: "old"},
+		}, client.NewMock(), nil)
+		require.EqualError(t, err, `brew: disable.date: invalid value "tomorrow", must be a date, e.g. 2024-01-31`)
+	})
+
+	t.Run("both", func(t *testing.T) {
+		ctx := testctx.NewWithCfg(config.Project{
+			Brews: []config.Homebrew{{
+				Deprecate: config.HomebrewDeprecate{Reason: "old"},
+				Disable:   config.HomebrewDisable{Reason: "old"},
+			}},
+		})
+		require.EqualError(t, Pipe{}.Default(ctx), "brews[0]: disable: can't be used together with deprecate")
+	})
+}
+
+func TestBuildFormulaValidateFormula(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	build := func(t *testing.T, block string) error {
+		t.Helper()
+		_, err := buildFormula(testctx.New(testctx.WithVersion("1.0.0")), config.Homebrew{
+			Name:            "foo",
+			URLTemplate:     "https://example.com/{{ .ArtifactName }}",
+			CustomBlock:     block,
+			ValidateFormula: true,
+		}, client.NewMock(), []*artifact.Artifact{{
+			Name:   "bin.tar.gz",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		}})
+		return err
+	}
+	fakeRuby := func(t *testing.T, script string) {
+		t.Helper()
+		bin := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(bin, "ruby"), []byte("#!/bin/sh\n"+script), 0o755))
+		t.Setenv("PATH", bin)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		testlib.CheckPath(t, "ruby")
+		require.ErrorContains(t, build(t, "if true"), "brew: invalid formula: line ")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		testlib.CheckPath(t, "ruby")
+		require.NoError(t, build(t, `head "https://github.com/foo/bar.git"`))
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		fakeRuby(t, "echo '-:7: syntax error, unexpected end-of-input, expecting end' >&2\nexit 1\n")
+		require.EqualError(t, build(t, "if true"), "brew: invalid formula: line 7: syntax error, unexpected end-of-input, expecting end")
+	})
+
+	t.Run("unknown error", func(t *testing.T) {
+		fakeRuby(t, "echo 'something broke' >&2\nexit 1\n")
+		require.EqualError(t, build(t, "if true"), "brew: invalid formula: something broke")
+	})
+
+	t.Run("ruby not found", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		require.NoError(t, build(t, "if true"))
+	})
+}
+
+func TestHooks(t *testing.T) {
+	t.Cleanup(func() { hooks = nil })
+
+	path := filepath.Join(t.TempDir(), "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	artifacts := []*artifact.Artifact{{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	}}
+	cfg := config.Homebrew{
+		Name:        "foo",
+		URLTemplate: "https://example.com/{{ .ArtifactName }}",
+	}
+
+	RegisterHook(func(_ *context.Context, data *FormulaData) error {
+		data.Desc = "changed by a hook"
+		return nil
+	})
+	RegisterHook(func(_ *context.Context, data *FormulaData) error {
+		data.Desc += ", twice"
+		return nil
+	})
+
+	formula, err := buildFormula(testctx.New(), cfg, client.NewMock(), artifacts)
+	require.NoError(t, err)
+	require.Contains(t, formula, `desc "changed by a hook, twice"`)
+
+	RegisterHook(func(_ *context.Context, _ *FormulaData) error {
+		return fmt.Errorf("nope")
+	})
+	_, err = buildFormula(testctx.New(), cfg, client.NewMock(), artifacts)
+	require.EqualError(t, err, "brew: hook failed: nope")
+}
+
+func TestFormulaeSimple(t *testing.T) {
+	formulae, err := doBuildFormula(testctx.NewWithCfg(config.Project{}), defaultTemplateData)
+	require.NoError(t, err)
+	assertDefaultTemplateData(t, formulae)
+	require.NotContains(t, formulae, "def caveats")
+	require.NotContains(t, formulae, "def plist;")
+}
+
+func TestFormulaeFrozenStringLiteral(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), config.Homebrew{Name: "foo"}, client.NewMock(), nil)
+		require.NoError(t, err)
+		require.True(t, data.FrozenStringLiteral)
+
+		formulae, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(formulae, "# typed: false\n# frozen_string_literal: true\n\n"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		disabled := false
+		data, err := dataFor(testctx.New(), config.Homebrew{
+			Name:                "foo",
+			FrozenStringLiteral: &disabled,
+		}, client.NewMock(), nil)
+		require.NoError(t, err)
+		require.False(t, data.FrozenStringLiteral)
+
+		formulae, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
+		require.NotContains(t, formulae, "frozen_string_literal")
+	})
+}
+
+func TestFormulaeSingleQuotes(t *testing.T) {
+	data := defaultTemplateData
+	data.QuoteStyle = quoteSingle
+	data.License = "MIT"
+	data.Dependencies = []config.HomebrewDependency{{Name: "git"}, {Name: "bash", Version: "3.2.57"}}
+	data.Conflicts = []string{"svn"}
+	var pkgs []releasePackage
+	for _, pkg := range data.LinuxPackages {
+		pkg.Install = []string{`bin.install 'test'`}
+		pkgs = append(pkgs, pkg)
+	}
+	data.LinuxPackages = pkgs
+	data.MacOSPackages = nil
+	formulae, err := doBuildFormula(testctx.New(), data)
+	require.NoError(t, err)
+	require.NotContains(t, formulae, `"`)
+	require.Contains(t, formulae, `homepage 'https://google.com'`)
+	require.Contains(t, formulae, `license 'MIT'`)
+	require.Contains(t, formulae, `depends_on 'bash' => { version: '3.2.57' }`)
+	require.Contains(t, formulae, `depends_on 'git'`)
+	require.Contains(t, formulae, `url 'https://github.com/caarlos0/test/releases/download/v0.1.3/test_Linux_x86_64.tar.gz'`)
+	require.Contains(t, formulae, `sha256 '1633f61598ab0791e213135923624eb342196b3494909c91899bcd0560f84c67'`)
+	require.Contains(t, formulae, `conflicts_with 'svn'`)
+}
+
+func TestFormulaeResources(t *testing.T) {
+	ctx := testctx.New(testctx.WithVersion("1.2.3"))
+	resources, err := resourcesFor(ctx, []config.HomebrewResource{
+		{
+			Name:   "helper",
+			URL:    "https://example.com/helper-{{ .Version }}.tar.gz",
+			SHA256: "1111111111111111111111111111111111111111111111111111111111111111",
+		},
+		{
+			Name:   "assets",
+			URL:    "https://example.com/assets.zip",
+			SHA256: "2222222222222222222222222222222222222222222222222222222222222222",
+		},
+	})
+	require.NoError(t, err)
+
+	data := defaultTemplateData
+	data.Resources = resources
+	formulae, err := doBuildFormula(ctx, data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, `
+  resource "helper" do
+    url "https://example.com/helper-1.2.3.tar.gz"
+    sha256 "1111111111111111111111111111111111111111111111111111111111111111"
+  end
+`)
+	require.Contains(t, formulae, `
+  resource "assets" do
+    url "https://example.com/assets.zip"
+    sha256 "2222222222222222222222222222222222222222222222222222222222222222"
+  end
+`)
+	require.Less(t, strings.Index(formulae, `resource "assets"`), strings.Index(formulae, "def install"))
+
+	t.Run("none", func(t *testing.T) {
+		formulae, err := doBuildFormula(ctx, defaultTemplateData)
+		require.NoError(t, err)
+		require.NotContains(t, formulae, "resource ")
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		_, err := resourcesFor(ctx, []config.HomebrewResource{{Name: "{{ .Nope }"}})
+		testlib.RequireTemplateError(t, err)
+	})
+}
+
+func TestFormulaeTemplatedDependencies(t *testing.T) {
+	ctx := testctx.New(
+		testctx.WithVersion("1.2.3"),
+		testctx.WithEnv(map[string]string{"SOME_DEP": "zsh"}),
+	)
+	deps := []config.HomebrewDependency{
+		{Name: "{{ .Env.SOME_DEP }}", Version: "v{{ .Version }}"},
+		{Name: "git"},
+	}
+	data, err := dataFor(ctx, config.Homebrew{Name: "test", Dependencies: deps}, client.NewMock(), nil)
+	require.NoError(t, err)
+	require.Equal(t, []config.HomebrewDependency{
+		{Name: "git"},
+		{Name: "zsh", Version: "v1.2.3"},
+	}, data.Dependencies)
+	require.Equal(t, "{{ .Env.SOME_DEP }}", deps[0].Name, "config should not be modified")
+
+	formulae, err := doBuildFormula(ctx, data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, `depends_on "git"`)
+	require.Contains(t, formulae, `depends_on "zsh" => { version: "v1.2.3" }`)
+
+	t.Run("type and version", func(t *testing.T) {
+		data, err := dataFor(ctx, config.Homebrew{
+			Name:         "test",
+			Dependencies: []config.HomebrewDependency{{Name: "zsh", Type: "optional", Version: "v{{ .Version }}"}},
+		}, client.NewMock(), nil)
+		require.NoError(t, err)
+		formulae, err := doBuildFormula(ctx, data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, `depends_on "zsh" => :optional`)
+		require.NotContains(t, formulae, "v1.2.3")
+	})
+
+	t.Run("head", func(t *testing.T) {
+		data, err := dataFor(ctx, config.Homebrew{
+			Name: "test",
+			Head: config.HomebrewHead{
+				URL:          "https://github.com/foo/bar.git",
+				Dependencies: []config.HomebrewDependency{{Name: "{{ .Env.SOME_DEP }}", Type: "build"}},
+			},
+		}, client.NewMock(), nil)
+		require.NoError(t, err)
+		formulae, err := doBuildFormula(ctx, data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, `
+  head do
+    url "https://github.com/foo/bar.git"
+    depends_on "zsh" => :build
+  end
+`)
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		_, err := dataFor(ctx, config.Homebrew{
+			Name:         "test",
+			Dependencies: []config.HomebrewDependency{{Name: "{{ .Nope }"}},
+		}, client.NewMock(), nil)
+		testlib.RequireTemplateError(t, err)
+	})
+}
+
+func TestFormulaeSharedInstall(t *testing.T) {
+	ctx := testctx.New()
+	pkgs := []releasePackage{
+		{
+			DownloadURL: "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Linux_x86_64.tar.gz",
+			SHA256:      "1633f61598ab0791e213135923624eb342196b3494909c91899bcd0560f84c68",
+			OS:          "linux",
+			Arch:        "amd64",
+			Install:     []string{`bin.install "test"`},
+		},
+		{
+			DownloadURL: "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Linux_arm64.tar.gz",
+			SHA256:      "1df5fdc2bad4ed4c28fbdc77b6c542988c0dc0e2ae34e0dc912bbb1c66646c58",
+			OS:          "linux",
+			Arch:        "arm64",
+			Install:     []string{`bin.install "test"`},
+		},
+	}
+
+	t.Run("same installs", func(t *testing.T) {
+		data := defaultTemplateData
+		data.MacOSPackages = nil
+		data.LinuxPackages = pkgs
+		data.LinuxInstall = sharedInstall(pkgs)
+		require.Equal(t, []string{`bin.install "test"`}, data.LinuxInstall)
+		formulae, err := doBuildFormula(ctx, data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, `
+  on_linux do
+    if Hardware::CPU.intel?
+      url "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Linux_x86_64.tar.gz"
+      sha256 "1633f61598ab0791e213135923624eb342196b3494909c91899bcd0560f84c68"
+    end
+    if Hardware::CPU.arm? && Hardware::CPU.is_64_bit?
+      url "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Linux_arm64.tar.gz"
+      sha256 "1df5fdc2bad4ed4c28fbdc77b6c542988c0dc0e2ae34e0dc912bbb1c66646c58"
+    end
+
+    def install
+      bin.install "test"
+    end
+  end
+`)
+		require.Equal(t, 1, strings.Count(formulae, "def install"))
+	})
+
+	t.Run("different installs", func(t *testing.T) {
+		different := []releasePackage{pkgs[0], pkgs[1]}
+		different[1].Install = []string{`bin.install "test-arm" => "test"`}
+		data := defaultTemplateData
+		data.LinuxPackages = different
+		data.LinuxInstall = sharedInstall(different)
+		require.Empty(t, data.LinuxInstall)
+		formulae, err := doBuildFormula(ctx, data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, `
+  on_linux do
+    if Hardware::CPU.intel?
+      url "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Linux_x86_64.tar.gz"
+      sha256 "1633f61598ab0791e213135923624eb342196b3494909c91899bcd0560f84c68"
+
+      def install
+        bin.install "test"
+      end
+    end
+    if Hardware::CPU.arm? && Hardware::CPU.is_64_bit?
+      url "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Linux_arm64.tar.gz"
+      sha256 "1df5fdc2bad4ed4c28fbdc77b6c542988c0dc0e2ae34e0dc912bbb1c66646c58"
+
+      def install
+        bin.install "test-arm" => "test"
+      end
+    end
+  end
+`)
+	})
+
+	t.Run("with resources", func(t *testing.T) {
+		withResources := []releasePackage{pkgs[0], pkgs[1]}
+		withResources[1].Resources = []releaseResource{{Name: "extra"}}
+		require.Empty(t, sharedInstall(withResources))
+	})
+
+	t.Run("single arch", func(t *testing.T) {
+		require.Empty(t, sharedInstall(pkgs[:1]))
+	})
+}
+
+func TestFormulaeMacOSArchBlocks(t *testing.T) {
+	ctx := testctx.New()
+	pkgs := []releasePackage{
+		{
+			DownloadURL: "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Darwin_x86_64.tar.gz",
+			SHA256:      "1633f61598ab0791e213135923624eb342196b3494909c91899bcd0560f84c68",
+			OS:          "darwin",
+			Arch:        "amd64",
+			Install:     []string{`bin.install "test"`},
+		},
+		{
+			DownloadURL: "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Darwin_arm64.tar.gz",
+			SHA256:      "1df5fdc2bad4ed4c28fbdc77b6c542988c0dc0e2ae34e0dc912bbb1c66646c58",
+			OS:          "darwin",
+			Arch:        "arm64",
+			Install:     []string{`bin.install "test-arm" => "test"`},
+		},
+	}
+
+	t.Run("different installs", func(t *testing.T) {
+		require.True(t, macOSInstallsDiffer(pkgs))
+		data := defaultTemplateData
+		data.MacOSPackages = pkgs
+		data.MacOSArchBlocks = true
+		formulae, err := doBuildFormula(ctx, data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, `
+  on_macos do
+    on_intel do
+      url "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Darwin_x86_64.tar.gz"
+      sha256 "1633f61598ab0791e213135923624eb342196b3494909c91899bcd0560f84c68"
+
+      def install
+        bin.install "test"
+      end
+    end
+    on_arm do
+      url "https://github.com/caarlos0/test/releases/download/v0.1.3/test_Darwin_arm64.tar.gz"
+      sha256 "1df5fdc2bad4ed4c28fbdc77b6c542988c0dc0e2ae34e0dc912bbb1c66646c58"
+
+      def install
+        bin.install "test-arm" => "test"
+      end
+    end
+  end
+`)
+	})
+
+	t.Run("same installs", func(t *testing.T) {
+		same := []releasePackage{pkgs[0], pkgs[1]}
+		same[1].Install = pkgs[0].Install
+		require.False(t, macOSInstallsDiffer(same))
+	})
+
+	t.Run("single arch", func(t *testing.T) {
+		require.False(t, macOSInstallsDiffer(pkgs[1:]))
+		data := defaultTemplateData
+		data.MacOSPackages = pkgs[1:]
+		formulae, err := doBuildFormula(ctx, data)
+		require.NoError(t, err)
+		require.NotContains(t, formulae, "on_arm do")
+		require.NotContains(t, formulae, "on_intel do")
+	})
+}
+
+func TestFormulaeOldNames(t *testing.T) {
+	ctx := testctx.New()
+	data, err := dataFor(ctx, config.Homebrew{
+		Name:     "test",
+		OldNames: []string{"test-legacy", "test@1"},
+	}, client.NewMock(), nil)
+	require.NoError(t, err)
+	formulae, err := doBuildFormula(ctx, data)
+	require.NoError(t, err)
+	require.Contains(t, formulae, `
+  oldname "test-legacy"
+  oldname "test@1"
+`)
+
+	t.Run("none", func(t *testing.T) {
+		formulae, err := doBuildFormula(ctx, defaultTemplateData)
+		require.NoError(t, err)
+		require.NotContains(t, formulae, "oldname")
+	})
+}
+
+func TestQuote(t *testing.T) {
+	require.Equal(t, `"foo"`, quote(quoteDouble, "foo"))
+	require.Equal(t, `"foo"`, quote("", "foo"))
+	require.Equal(t, `'foo'`, quote(quoteSingle, "foo"))
+	require.Equal(t, `'it\'s'`, quote(quoteSingle, "it's"))
+	require.Equal(t, `'a\\b'`, quote(quoteSingle, `a\b`))
+}
+
+func TestSplit(t *testing.T) {
+	parts := split("system \"true\"\nsystem \"#{bin}/foo\", \"-h\"")
+	require.Equal(t, []string{"system \"true\"", "system \"#{bin}/foo\", \"-h\""}, parts)
+	parts = split("")
+	require.Equal(t, []string{}, parts)
+	parts = split("\n  ")
+	require.Equal(t, []string{}, parts)
+}
+
+func TestFullPipe(t *testing.T) {
+	type testcase struct {
+		prepare              func(ctx *context.Context)
+		expectedRunError     string
+		expectedPublishError string
+	}
+	for name, tt := range map[string]testcase{
+		"default": {
+			prepare: func(ctx *context.Context) {
+				ctx.TokenType = context.TokenTypeGitHub
+				ctx.Config.Brews[0].Repository.Owner = "test"
+				ctx.Config.Brews[0].Repository.Name = "test"
+				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
+			},
+		},
+		"git_remote": {
+			prepare: func(ctx *context.Context) {
+				ctx.TokenType = context.TokenTypeGitHub
+				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
+				ctx.Config.Brews[0].Repository = config.RepoRef{
+					Name:   "test",
+					Branch: "main",
+					Git: config.GitRepoRef{
+						URL:        testlib.GitMakeBareRepository(t),
+						PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+					},
+				}
+			},
+		},
+		"open_pr": {
+			prepare: func(ctx *context.Context) {
+				ctx.TokenType = context.TokenTypeGitHub
+				ctx.Token = "token"
+				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
+				ctx.Config.Brews[0].Repository = config.RepoRef{
+					Owner:  "test",
+					Name:   "test",
+					Branch: "update-{{.Version}}",
+					PullRequest: config.PullRequest{
+						Enabled: true,
+					},
+				}
+			},
+		},
+		"open_pr_without_token": {
+			prepare: func(ctx *context.Context) {
+				ctx.TokenType = context.TokenTypeGitHub
+				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
+				ctx.Config.Brews[0].Repository = config.RepoRef{
+					Owner:    "test",
+					Name:     "test",
+					Branch:   "update-{{.Version}}",
+					TokenEnv: "HOMEBREW_TAP_TOKEN",
+					PullRequest: config.PullRequest{
+						Enabled: true,
+					},
+				}
+			},
+			expectedPublishError: client.ErrNoToken.Error(),
+		},
+		"custom_download_strategy": {
+			prepare: func(ctx *context.Context) {
+				ctx.TokenType = context.TokenTypeGitHub
+				ctx.Config.Brews[0].Repository.Owner = "test"
+				ctx.Config.Brews[0].Repository.Name = "test"
+				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
+
+				ctx.Config.Brews[0].DownloadStrategy = "GitHubPrivateRepositoryReleaseDownloadStrategy"
+			},
+		},
+		"custom_require": {
+			prepare: func(ctx *context.Context) {
+				ctx.TokenType = context.TokenTypeGitHub
+				ctx.Config.Brews[0].Repository.Owner = "test"
+				ctx.Config.Brews[0].Repository.Name = "test"
+				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
+
+				ctx.Config.Brews[0].DownloadStrategy = "CustomDownloadStrategy"
+				ctx.Config.Brews[0].CustomRequire = "custom_download_strategy"
+			},
+		},
+		"custom_block": {
+			prepare: func(ctx *context.Context) {
+				ctx.TokenType = context.TokenTypeGitHub
+				ctx.Config.Brews[0].Repository.Owner = "test"
+				ctx.Config.Brews[0].Repository.Name = "test"
+				ctx.Config.Brews[0].Homepage = "https://github.com/goreleaser"
+
+				ctx.Config.Brews[0].CustomBlock = `head "https://github.com/caarlos0/test.git"`
+			},
+		},
+		"default_gitlab": {
+			prepare: func(ctx *context.Context) {
+				ctx.TokenType = context.TokenTypeGitLab
+				ctx.Config.Brews[0].Repository.Owner = "test"
+				ctx.Config.Brews[0].Repository.Name = "test"
+				ctx.Config.Brews[0].Homepage = "https://gitlab.com/goreleaser"
+			},
+		},
+		"invalid_commit_template": {
+			prepare: func(ctx *context.Context) {
+				ctx.Config.Brews[0].Repository.Owner = "test"
+				ctx.Config.Brews[0].Repository.Name = "test"
+				ctx.Config.Brews[0].CommitMessageTemplate = "{{ .Asdsa }"
+			},
+			expectedPublishError: `template: tmpl:1: unexpected "}" in operand`,
+		},
+		"valid_repository_templates": {
+			prepare: func(ctx *context.Context) {
+				ctx.TokenType = context.TokenTypeGitHub
+				ctx.Env = map[string]string{
+					"FOO": "templated",
+				}
+				ctx.Config.Brews[0].Repository.Owner = "{{.Env.FOO}}"
+				ctx.Config.Brews[0].Repository.Name = "{{.Env.FOO}}"
+			},
+		},
+		"invalid_repository_name_template": {
+			prepare: func(ctx *context.Context) {
+				ctx.Config.Brews[0].Repository.Owner = "test"
+				ctx.Config.Brews[0].Repository.Name = "{{ .Asdsa }"
+			},
+			expectedRunError: `template: tmpl:1: unexpected "}" in operand`,
+		},
+		"invalid_repository_owner_template": {
+			prepare: func(ctx *context.Context) {
+				ctx.Config.Brews[0].Repository.Owner = "{{ .Asdsa }"
+				ctx.Config.Brews[0].Repository.Name = "test"
+			},
+			expectedRunError: `template: tmpl:1: unexpected "}" in operand`,
+		},
+		"invalid_repository_skip_upload_template": {
+			prepare: func(ctx *context.Context) {
+				ctx.Config.Brews[0].SkipUpload = "{{ .Asdsa }"
+				ctx.Config.Brews[0].Repository.Owner = "test"
+				ctx.Config.Brews[0].Repository.Name = "test"
+			},
+			expectedRunError: `template: tmpl:1: unexpected "}" in operand`,
+		},
+		"invalid_install_template": {
+			prepare: func(ctx *context.Context) {
+				ctx.Config.Brews[0].Repository.Owner = "test"
+				ctx.Config.Brews[0].Repository.Name = "test"
+				ctx.Config.Brews[0].Install = "{{ .aaaa }"
+			},
+			expectedRunError: `template: tmpl:1: unexpected "}" in operand`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			folder := t.TempDir()
+			ctx := testctx.NewWithCfg(
+				config.Project{
+					Dist:        folder,
+					ProjectName: name,
+					Brews: []config.Homebrew{
+						{
+							Name: name,
+							IDs: []string{
+								"foo",
+							},
+							Description: "Run pipe test formula and FOO={{ .Env.FOO }}",
+							Caveats:     "don't do this {{ .ProjectName }}",
+							Test:        "system \"true\"\nsystem \"#{bin}/foo\", \"-h\"",
+							Dependencies: []config.HomebrewDependency{
+								{Name: "zsh", Type: "optional"},
+								{Name: "bash", Version: "3.2.57"},
+								{Name: "fish", Type: "optional", Version: "v1.2.3"},
+							},
+							Conflicts:   []string{"gtk+", "qt"},
+							Service:     config.HomebrewService{Raw: "run foo/bar\nkeep_alive true"},
+							PostInstall: "system \"echo\"\ntouch \"/tmp/hi\"",
+							Install:     `bin.install "{{ .ProjectName }}_{{.Os}}_{{.Arch}} => {{.ProjectName}}"`,
+							Goamd64:     "v1",
+						},
+					},
+					Env: []string{"FOO=foo_is_bar"},
+				},
+				testctx.WithVersion("1.0.1"),
+				testctx.WithCurrentTag("v1.0.1"),
+			)
+			tt.prepare(ctx)
+			ctx.Artifacts.Add(&artifact.Artifact{
+				Name:    "bar_bin.tar.gz",
+				Path:    "doesnt matter",
+				Goos:    "darwin",
+				Goarch:  "amd64",
+				Goamd64: "v1",
+				Type:    artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraID:     "bar",
+					artifact.ExtraFormat: "tar.gz",
+				},
+			})
+			path := filepath.Join(folder, "bin.tar.gz")
+			ctx.Artifacts.Add(&artifact.Artifact{
+				Name:    "bin.tar.gz",
+				Path:    path,
+				Goos:    "darwin",
+				Goarch:  "amd64",
+				Goamd64: "v1",
+				Type:    artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraID:     "foo",
+					artifact.ExtraFormat: "tar.gz",
+				},
+			})
+			ctx.Artifacts.Add(&artifact.Artifact{
+				Name:   "bin.tar.gz",
+				Path:   path,
+				Goos:   "darwin",
+				Goarch: "arm64",
+				Type:   artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraID:     "foo",
+					artifact.ExtraFormat: "tar.gz",
+				},
+			})
+			ctx.Artifacts.Add(&artifact.Artifact{
+				Name:    "bin.tar.gz",
+				Path:    path,
+				Goos:    "linux",
+				Goarch:  "amd64",
+				Goamd64: "v1",
+				Type:    artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraID:     "foo",
+					artifact.ExtraFormat: "tar.gz",
+				},
+			})
+
+			f, err := os.Create(path)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+			client := client.NewMock()
+			distFile := filepath.Join(folder, "homebrew", name+".rb")
+
+			require.NoError(t, Pipe{}.Default(ctx))
+
+			if tt.expectedRunError == "" {
+				require.NoError(t, runAll(ctx, client))
+			} else {
+				require.EqualError(t, runAll(ctx, client), tt.expectedRunError)
+				return
+			}
+			if tt.expectedPublishError != "" {
+				require.EqualError(t, publishAll(ctx, client), tt.expectedPublishError)
+				return
+			}
+
+			require.NoError(t, publishAll(ctx, client))
+
+			content := []byte(client.Content)
+			if url := ctx.Config.Brews[0].Repository.Git.URL; url == "" {
+				require.True(t, client.CreatedFile, "should have created a file")
+			} else {
+				content = testlib.CatFileFromBareRepository(t, url, name+".rb")
+			}
+
+			golden.RequireEqualRb(t, content)
+
+			distBts, err := os.ReadFile(distFile)
+			require.NoError(t, err)
+			require.Equal(t, string(content), string(distBts))
+		})
+	}
+}
+
+func TestRunPipeNameTemplate(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:        "foo_{{ .Env.FOO_BAR }}",
+					Description: "Foo bar",
+					Homepage:    "https://goreleaser.com",
+					Goamd64:     "v1",
+					Install:     `bin.install "foo"`,
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
+					IDs: []string{
+						"foo",
+					},
+				},
+			},
+			Env: []string{"FOO_BAR=is_bar"},
+		},
+		testctx.WithVersion("1.0.1"),
+		testctx.WithCurrentTag("v1.0.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:    "bin.tar.gz",
+		Path:    path,
+		Goos:    "darwin",
+		Goarch:  "amd64",
+		Goamd64: "v1",
+		Type:    artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraID:     "foo",
+			artifact.ExtraFormat: "tar.gz",
+		},
+	})
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	client := client.NewMock()
+	distFile := filepath.Join(folder, "homebrew", "foo_is_bar.rb")
+
+	require.NoError(t, runAll(ctx, client))
+	require.NoError(t, publishAll(ctx, client))
+	require.True(t, client.CreatedFile)
+	golden.RequireEqualRb(t, []byte(client.Content))
+	distBts, err := os.ReadFile(distFile)
+	require.NoError(t, err)
+	require.Equal(t, client.Content, string(distBts))
+}
+
+func TestRunPipeMultipleBrewsWithSkip(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:    "foo",
+					Goamd64: "v1",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
+					IDs: []string{
+						"foo",
+					},
+					SkipUpload: "true",
+				},
+				{
+					Name:    "bar",
+					Goamd64: "v1",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
+					IDs: []string{
+						"foo",
+					},
+				},
+				{
+					Name:    "foobar",
+					Goamd64: "v1",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
+					IDs: []string{
+						"foo",
+					},
+					SkipUpload: "true",
+				},
+				{
+					Name:    "baz",
+					Goamd64: "v1",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
+					IDs: []string{
+						"foo",
+					},
+					SkipUpload: "{{ .Env.SKIP_UPLOAD }}",
+				},
+			},
+			Env: []string{
+				"FOO_BAR=is_bar",
+				"SKIP_UPLOAD=true",
+			},
+		},
+		testctx.WithVersion("1.0.1"),
+		testctx.WithCurrentTag("v1.0.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:    "bin.tar.gz",
+		Path:    path,
+		Goos:    "darwin",
+		Goarch:  "amd64",
+		Goamd64: "v1",
+		Type:    artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraID:     "foo",
+			artifact.ExtraFormat: "tar.gz",
+		},
+	})
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	cli := client.NewMock()
+	require.NoError(t, runAll(ctx, cli))
+	require.EqualError(t, publishAll(ctx, cli), `brew.skip_upload is set`)
+	require.True(t, cli.CreatedFile)
+
+	for _, brew := range ctx.Config.Brews {
+		distFile := filepath.Join(folder, "homebrew", brew.Name+".rb")
+		_, err := os.Stat(distFile)
+		require.NoError(t, err, "file should exist: "+distFile)
+	}
+}
+
+func TestRunPipeForMultipleAmd64Versions(t *testing.T) {
+	for name, fn := range map[string]func(ctx *context.Context){
+		"v1": func(ctx *context.Context) {
+			ctx.Config.Brews[0].Goamd64 = "v1"
+		},
+		"v2": func(ctx *context.Context) {
+			ctx.Config.Brews[0].Goamd64 = "v2"
+		},
+		"v3": func(ctx *context.Context) {
+			ctx.Config.Brews[0].Goamd64 = "v3"
+		},
+		"v4": func(ctx *context.Context) {
+			ctx.Config.Brews[0].Goamd64 = "v4"
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			folder := t.TempDir()
+			ctx := testctx.NewWithCfg(
+				config.Project{
+					Dist:        folder,
+					ProjectName: name,
+					Brews: []config.Homebrew{
+						{
+							Name:        name,
+							Description: "Run pipe test formula",
+							Repository: config.RepoRef{
+								Owner: "test",
+								Name:  "test",
+							},
+							Homepage:     "https://github.com/goreleaser",
+							Install:      `bin.install "foo"`,
+							ExtraInstall: `man1.install "./man/foo.1.gz"`,
+						},
+					},
+					GitHubURLs: config.GitHubURLs{
+						Download: "https://github.com",
+					},
+					Release: config.Release{
+						GitHub: config.Repo{
+							Owner: "test",
+							Name:  "test",
+						},
+					},
+					Env: []string{"FOO=foo_is_bar"},
+				},
+				testctx.GitHubTokenType,
+				testctx.WithVersion("1.0.1"),
+				testctx.WithCurrentTag("v1.0.1"),
+			)
+			fn(ctx)
+			for _, a := range []struct {
+				name    string
+				goos    string
+				goarch  string
+				goamd64 string
+			}{
+				{
+					name:   "bin",
+					goos:   "darwin",
+					goarch: "arm64",
+				},
+				{
+					name:   "arm64",
+					goos:   "linux",
+					goarch: "arm64",
+				},
+				{
+					name:    "amd64v2",
+					goos:    "linux",
+					goarch:  "amd64",
+					goamd64: "v1",
+				},
+				{
+					name:    "amd64v2",
+					goos:    "linux",
+					goarch:  "amd64",
+					goamd64: "v2",
+				},
+				{
+					name:    "amd64v3",
+					goos:    "linux",
+					goarch:  "amd64",
+					goamd64: "v3",
+				},
+				{
+					name:    "amd64v3",
+					goos:    "linux",
+					goarch:  "amd64",
+					goamd64: "v4",
+				},
+			} {
+				path := filepath.Join(folder, fmt.Sprintf("%s.tar.gz", a.name))
+				ctx.Artifacts.Add(&artifact.Artifact{
+					Name:    fmt.Sprintf("%s.tar.gz", a.name),
+					Path:    path,
+					Goos:    a.goos,
+					Goarch:  a.goarch,
+					Goamd64: a.goamd64,
+					Type:    artifact.UploadableArchive,
+					Extra: map[string]interface{}{
+						artifact.ExtraID:     a.name,
+						artifact.ExtraFormat: "tar.gz",
+					},
+				})
+				f, err := os.Create(path)
+				require.NoError(t, err)
+				require.NoError(t, f.Close())
+			}
+
+			client := client.NewMock()
+			distFile := filepath.Join(folder, "homebrew", name+".rb")
+
+			require.NoError(t, runAll(ctx, client))
+			require.NoError(t, publishAll(ctx, client))
+			require.True(t, client.CreatedFile)
+			golden.RequireEqualRb(t, []byte(client.Content))
+
+			distBts, err := os.ReadFile(distFile)
+			require.NoError(t, err)
+			require.Equal(t, client.Content, string(distBts))
+		})
+	}
+}
+
+func TestByGoarm64(t *testing.T) {
+	artifacts := artifact.New()
+	for _, goarm64 := range []string{"", "v8.0", "v9.0"} {
+		artifacts.Add(&artifact.Artifact{
+			Name:    "bin" + goarm64,
+			Goos:    "darwin",
+			Goarch:  "arm64",
+			Goarm64: goarm64,
+		})
+	}
+	require.Len(t, artifacts.Filter(byGoarm64("v8.0")).List(), 2)
+	require.Len(t, artifacts.Filter(byGoarm64("v9.0")).List(), 1)
+	require.Empty(t, artifacts.Filter(byGoarm64("v8.1")).List())
+}
+
+func TestRunPipeForMultipleArm64Versions(t *testing.T) {
+	folder := testlib.Mktmp(t)
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\nfunc main() {println(0)}"), 0o644))
+	require.NoError(t, os.WriteFile("go.mod", []byte("module foo\n"), 0o644))
+
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        filepath.Join(folder, "dist"),
+			ProjectName: "foo",
+			Builds: []config.Build{{
+				ID:      "foo",
+				Binary:  "foo",
+				Goos:    []string{"darwin"},
+				Goarch:  []string{"arm64"},
+				Goarm64: []string{"v8.0", "v9.0"},
+			}},
+			Archives: []config.Archive{{}},
+			Brews: []config.Homebrew{{
+				Name: "foo",
+				Repository: config.RepoRef{
+					Owner: "foo",
+					Name:  "bar",
+				},
+			}},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	require.NoError(t, build.Pipe{}.Default(ctx))
+	require.NoError(t, archive.Pipe{}.Default(ctx))
+	require.NoError(t, build.Pipe{}.Run(ctx))
+	require.NoError(t, archive.Pipe{}.Run(ctx))
+
+	for goarm64, expected := range map[string]string{
+		"":     "foo_1.2.1_darwin_arm64v8.0.tar.gz",
+		"v9.0": "foo_1.2.1_darwin_arm64v9.0.tar.gz",
+	} {
+		t.Run("goarm64="+goarm64, func(t *testing.T) {
+			ctx.Config.Brews[0].Goarm64 = goarm64
+			require.NoError(t, Pipe{}.Default(ctx))
+			client := client.NewMock()
+			require.NoError(t, runAll(ctx, client))
+			require.NoError(t, publishAll(ctx, client))
+			require.Contains(t, client.Content, expected)
+			require.Equal(t, 1, strings.Count(client.Content, "url \""))
+		})
+	}
+}
+
+func TestRunPipeForMultipleArmVersions(t *testing.T) {
+	for name, fn := range map[string]func(ctx *context.Context){
+		"multiple_armv5": func(ctx *context.Context) {
+			ctx.Config.Brews[0].Goarm = "5"
+		},
+		"multiple_armv6": func(ctx *context.Context) {
+			ctx.Config.Brews[0].Goarm = "6"
+		},
+		"multiple_armv7": func(ctx *context.Context) {
+			ctx.Config.Brews[0].Goarm = "7"
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			folder := t.TempDir()
+			ctx := testctx.NewWithCfg(
+				config.Project{
+					Dist:        folder,
+					ProjectName: name,
+					Brews: []config.Homebrew{
+						{
+							Name:         name,
+							Description:  "Run pipe test formula and FOO={{ .Env.FOO }}",
+							Caveats:      "don't do this {{ .ProjectName }}",
+							Test:         "system \"true\"\nsystem \"#{bin}/foo\", \"-h\"",
+							Plist:        `<xml>whatever</xml>`,
+							Dependencies: []config.HomebrewDependency{{Name: "zsh"}, {Name: "bash", Type: "recommended"}},
+							Conflicts:    []string{"gtk+", "qt"},
+							Install:      `bin.install "{{ .ProjectName }}"`,
+							Repository: config.RepoRef{
+								Owner: "test",
+								Name:  "test",
+							},
+							Homepage: "https://github.com/goreleaser",
+						},
+					},
+					GitHubURLs: config.GitHubURLs{
+						Download: "https://github.com",
+					},
+					Release: config.Release{
+						GitHub: config.Repo{
+							Owner: "test",
+							Name:  "test",
+						},
+					},
+					Env: []string{"FOO=foo_is_bar"},
+				},
+				testctx.GitHubTokenType,
+				testctx.WithVersion("1.0.1"),
+				testctx.WithCurrentTag("v1.0.1"),
+			)
+			fn(ctx)
+			for _, a := range []struct {
+				name   string
+				goos   string
+				goarch string
+				goarm  string
+			}{
+				{
+					name:   "bin",
+					goos:   "darwin",
+					goarch: "amd64",
+				},
+				{
+					name:   "arm64",
+					goos:   "linux",
+					goarch: "arm64",
+				},
+				{
+					name:   "armv5",
+					goos:   "linux",
+					goarch: "arm",
+					goarm:  "5",
+				},
+				{
+					name:   "armv6",
+					goos:   "linux",
+					goarch: "arm",
+					goarm:  "6",
+				},
+				{
+					name:   "armv7",
+					goos:   "linux",
+					goarch: "arm",
+					goarm:  "7",
+				},
+			} {
+				path := filepath.Join(folder, fmt.Sprintf("%s.tar.gz", a.name))
+				ctx.Artifacts.Add(&artifact.Artifact{
+					Name:   fmt.Sprintf("%s.tar.gz", a.name),
+					Path:   path,
+					Goos:   a.goos,
+					Goarch: a.goarch,
+					Goarm:  a.goarm,
+					Type:   artifact.UploadableArchive,
+					Extra: map[string]interface{}{
+						artifact.ExtraID:     a.name,
+						artifact.ExtraFormat: "tar.gz",
+					},
+				})
+				f, err := os.Create(path)
+				require.NoError(t, err)
+				require.NoError(t, f.Close())
+			}
+
+			client := client.NewMock()
+			distFile := filepath.Join(folder, "homebrew", name+".rb")
+
+			require.NoError(t, runAll(ctx, client))
+			require.NoError(t, publishAll(ctx, client))
+			require.True(t, client.CreatedFile)
+			golden.RequireEqualRb(t, []byte(client.Content))
+
+			distBts, err := os.ReadFile(distFile)
+			require.NoError(t, err)
+			require.Equal(t, client.Content, string(distBts))
+		})
+	}
+}
+
+func TestRunPipeNoBuilds(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		Brews: []config.Homebrew{
+			{
+				Repository: config.RepoRef{
+					Owner: "test",
+					Name:  "test",
+				},
+				IDs: []string{"foo"},
+			},
+		},
+	}, testctx.GitHubTokenType)
+	client := client.NewMock()
+	require.NoError(t, Pipe{}.Default(ctx))
+	require.EqualError(t, runAll(ctx, client), ErrNoArchivesFound{
+		ids:     []string{"foo"},
+		goarm:   "6",
+		goamd64: "v1",
+		goarm64: "v8.0",
+	}.Error())
+	require.False(t, client.CreatedFile)
+}
+
+func TestRunPipeGoamd64Fallback(t *testing.T) {
+	setup := func(tb testing.TB, fallback bool) *context.Context {
+		tb.Helper()
+		folder := tb.TempDir()
+		ctx := testctx.NewWithCfg(config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{{
+				Name:            "foo",
+				Goamd64:         "v3",
+				Goamd64Fallback: fallback,
+				Repository: config.RepoRef{
+					Owner: "test",
+					Name:  "test",
+				},
+			}},
+		}, testctx.GitHubTokenType, testctx.WithVersion("1.2.1"), testctx.WithCurrentTag("v1.2.1"))
+		path := filepath.Join(folder, "bin.tar.gz")
+		f, err := os.Create(path)
+		require.NoError(tb, err)
+		require.NoError(tb, f.Close())
+		for _, level := range []string{"v1", "v2"} {
+			ctx.Artifacts.Add(&artifact.Artifact{
+				Name:    "foo_linux_amd64_" + level + ".tar.gz",
+				Path:    path,
+				Goos:    "linux",
+				Goarch:  "amd64",
+				Goamd64: level,
+				Type:    artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraID:     "foo",
+					artifact.ExtraFormat: "tar.gz",
+				},
+			})
+		}
+		require.NoError(tb, Pipe{}.Default(ctx))
+		return ctx
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		ctx := setup(t, false)
+		cli := client.NewMock()
+		require.EqualError(t, runAll(ctx, cli), ErrNoArchivesFound{
+			goarm:   "6",
+			goamd64: "v3",
+			goarm64: "v8.0",
+		}.Error())
+		require.False(t, cli.CreatedFile)
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		ctx := setup(t, true)
+		cli := client.NewMock()
+		require.NoError(t, runAll(ctx, cli))
+		require.NoError(t, publishAll(ctx, cli))
+		require.Contains(t, cli.Content, "foo_linux_amd64_v2.tar.gz")
+		require.NotContains(t, cli.Content, "foo_linux_amd64_v1.tar.gz")
+	})
+
+	t.Run("no lower level", func(t *testing.T) {
+		ctx := setup(t, true)
+		ctx.Config.Brews[0].Goamd64 = "v1"
+		require.NoError(t, ctx.Artifacts.Remove(artifact.ByGoamd64("v1")))
+		require.ErrorAs(t, runAll(ctx, client.NewMock()), &ErrNoArchivesFound{})
+	})
+}
+
+func TestRunPipeMissingRepository(t *testing.T) {
+	for field, repo := range map[string]config.RepoRef{
+		"owner": {Owner: "{{ .Env.EMPTY }}", Name: "test"},
+		"name":  {Owner: "test", Name: "{{ .Env.EMPTY }}"},
+	} {
+		t.Run(field, func(t *testing.T) {
+			ctx := testctx.NewWithCfg(config.Project{
+				Brews: []config.Homebrew{{
+					Repository: repo,
+				}},
+			}, testctx.GitHubTokenType, testctx.WithEnv(map[string]string{"EMPTY": ""}))
+			ctx.Artifacts.Add(&artifact.Artifact{
+				Name:   "bin.tar.gz",
+				Goos:   "darwin",
+				Goarch: "arm64",
+				Type:   artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraFormat: "tar.gz",
+				},
+			})
+			require.NoError(t, Pipe{}.Default(ctx))
+			err := runAll(ctx, client.NewMock())
+			require.ErrorIs(t, err, ErrMissingRepository{field: field})
+			require.EqualError(t, err, "brew.repository."+field+" is empty, check your configuration and its templates")
+		})
+	}
+
+	t.Run("git", func(t *testing.T) {
+		require.NoError(t, validateRepository(config.RepoRef{
+			Name: "test",
+			Git:  config.GitRepoRef{URL: "git@example.com:test/test.git"},
+		}))
+	})
+}
+
+func TestRunPipeMultipleArchivesSameOsBuild(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		Brews: []config.Homebrew{
+			{
+				Repository: config.RepoRef{
+					Owner: "test",
+					Name:  "test",
+				},
+			},
+		},
+	}, testctx.GitHubTokenType)
+
+	f, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	tests := []struct {
+		expectedError error
+		osarchs       []struct {
+			goos   string
+			goarch string
+			goarm  string
+		}
+	}{
+		{
+			expectedError: ErrMultipleArchivesSameOS,
+			osarchs: []struct {
+				goos   string
+				goarch string
+				goarm  string
+			}{
+				{
+					goos:   "darwin",
+					goarch: "amd64",
+				},
+				{
+					goos:   "darwin",
+					goarch: "amd64",
+				},
+			},
+		},
+		{
+			expectedError: ErrMultipleArchivesSameOS,
+			osarchs: []struct {
+				goos   string
+				goarch string
+				goarm  string
+			}{
+				{
+					goos:   "linux",
+					goarch: "amd64",
+				},
+				{
+					goos:   "linux",
+					goarch: "amd64",
+				},
+			},
+		},
+		{
+			expectedError: ErrMultipleArchivesSameOS,
+			osarchs: []struct {
+				goos   string
+				goarch string
+				goarm  string
+			}{
+				{
+					goos:   "linux",
+					goarch: "arm64",
+				},
+				{
+					goos:   "linux",
+					goarch: "arm64",
+				},
+			},
+		},
+		{
+			expectedError: ErrMultipleArchivesSameOS,
+			osarchs: []struct {
+				goos   string
+				goarch string
+				goarm  string
+			}{
+				{
+					goos:   "linux",
+					goarch: "arm",
+					goarm:  "6",
+				},
+				{
+					goos:   "linux",
+					goarch: "arm",
+					goarm:  "6",
+				},
+			},
+		},
+		{
+			expectedError: ErrMultipleArchivesSameOS,
+			osarchs: []struct {
+				goos   string
+				goarch string
+				goarm  string
+			}{
+				{
+					goos:   "linux",
+					goarch: "arm",
+					goarm:  "5",
+				},
+				{
+					goos:   "linux",
+					goarch: "arm",
+					goarm:  "6",
+				},
+				{
+					goos:   "linux",
+					goarch: "arm",
+					goarm:  "7",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		for idx, ttt := range test.osarchs {
+			ctx.Artifacts.Add(&artifact.Artifact{
+				Name:   fmt.Sprintf("bin%d", idx),
+				Path:   f.Name(),
+				Goos:   ttt.goos,
+				Goarch: ttt.goarch,
+				Type:   artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraID:     fmt.Sprintf("foo%d", idx),
+					artifact.ExtraFormat: "tar.gz",
+				},
+			})
+		}
+		client := client.NewMock()
+		require.ErrorIs(t, runAll(ctx, client), test.expectedError)
+		require.False(t, client.CreatedFile)
+		// clean the artifacts for the next run
+		ctx.Artifacts = artifact.New()
+	}
+}
+
+func TestRunPipeMultipleArchivesSameOsBuildNames(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		Brews: []config.Homebrew{
+			{
+				Repository: config.RepoRef{
+					Owner: "test",
+					Name:  "test",
+				},
+			},
+		},
+	}, testctx.GitHubTokenType)
+	path := filepath.Join(t.TempDir(), "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	for _, name := range []string{"foo", "bar"} {
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:   name + ".tar.gz",
+			Path:   path,
+			Goos:   "linux",
+			Goarch: "amd64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraID:     name,
+				artifact.ExtraFormat: "tar.gz",
+			},
+		})
+	}
+	err := runAll(ctx, client.NewMock())
+	require.ErrorIs(t, err, ErrMultipleArchivesSameOS)
+	require.EqualError(t, err, "archives foo.tar.gz (id foo), bar.tar.gz (id bar) all map to linux/amd64: one tap can handle only one archive of an OS/Arch combination. Consider using ids in the brew section")
+}
+
+func TestRunPipeFormatPriority(t *testing.T) {
+	folder := t.TempDir()
+	path := filepath.Join(folder, "bin")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	artifacts := []*artifact.Artifact{}
+	for _, format := range []string{"tar.gz", "zip"} {
+		artifacts = append(artifacts, &artifact.Artifact{
+			Name:   "bin." + format,
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   format,
+				artifact.ExtraBinaries: []string{"bin"},
+			},
+		})
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := dataFor(testctx.New(), config.Homebrew{}, client.NewMock(), artifacts)
+		require.ErrorIs(t, err, ErrMultipleArchivesSameOS)
+	})
+
+	t.Run("zip first", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), config.Homebrew{
+			FormatPriority: []string{"zip", "tar.gz"},
+		}, client.NewMock(), artifacts)
+		require.NoError(t, err)
+		require.Len(t, data.MacOSPackages, 1)
+		require.Equal(t, "https://dummyhost/download//bin.zip", data.MacOSPackages[0].DownloadURL)
+	})
+
+	t.Run("tar.gz first", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), config.Homebrew{
+			FormatPriority: []string{"tar.gz"},
+		}, client.NewMock(), artifacts)
+		require.NoError(t, err)
+		require.Len(t, data.MacOSPackages, 1)
+		require.Equal(t, "https://dummyhost/download//bin.tar.gz", data.MacOSPackages[0].DownloadURL)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		_, err := dataFor(testctx.New(), config.Homebrew{
+			FormatPriority: []string{"binary"},
+		}, client.NewMock(), artifacts)
+		require.ErrorIs(t, err, ErrMultipleArchivesSameOS)
+	})
+}
+
+func TestRunPipeXZAndBZ2Archives(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:             "foo",
+					DownloadStrategy: "CurlDownloadStrategy",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "homebrew-tap",
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	for _, art := range []struct{ goos, format string }{
+		{"darwin", "tar.xz"},
+		{"linux", "tar.bz2"},
+		{"linux", "tar.gz"},
+	} {
+		name := "foo_" + art.goos + "." + art.format
+		path := filepath.Join(folder, name)
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:    name,
+			Path:    path,
+			Goos:    art.goos,
+			Goarch:  "amd64",
+			Goamd64: "v1",
+			Type:    artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   art.format,
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		})
+	}
+	require.NoError(t, Pipe{}.Default(ctx))
+
+	t.Run("fallback", func(t *testing.T) {
+		require.NoError(t, runAll(ctx, client.NewMock()))
+		formula, err := os.ReadFile(filepath.Join(folder, "homebrew", "foo.rb"))
+		require.NoError(t, err)
+		require.Contains(t, string(formula), `url "https://dummyhost/download/v1.2.1/foo_darwin.tar.xz", using: CurlDownloadStrategy`)
+		require.Contains(t, string(formula), `url "https://dummyhost/download/v1.2.1/foo_linux.tar.gz", using: CurlDownloadStrategy`)
+		require.NotContains(t, string(formula), "tar.bz2")
+	})
+
+	t.Run("format priority", func(t *testing.T) {
+		ctx.Config.Brews[0].FormatPriority = []string{"tar.bz2", "tar.gz"}
+		require.NoError(t, runAll(ctx, client.NewMock()))
+		formula, err := os.ReadFile(filepath.Join(folder, "homebrew", "foo.rb"))
+		require.NoError(t, err)
+		require.Contains(t, string(formula), `url "https://dummyhost/download/v1.2.1/foo_linux.tar.bz2", using: CurlDownloadStrategy`)
+		require.NotContains(t, string(formula), "foo_linux.tar.gz")
+	})
+}
+
+func TestDataForFeatureLevels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bin")
+	require.NoError(t, os.WriteFile(path, []byte("fake"), 0o644))
+
+	data, err := dataFor(testctx.New(), config.Homebrew{}, client.NewMock(), []*artifact.Artifact{
+		{
+			Name:    "bin_linux_amd64.tar.gz",
+			Path:    path,
+			Goos:    "linux",
+			Goarch:  "amd64",
+			Goamd64: "v3",
+			Type:    artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"bin"},
+			},
+		},
+		{
+			Name:   "bin_linux_arm.tar.gz",
+			Path:   path,
+			Goos:   "linux",
+			Goarch: "arm",
+			Goarm:  "7",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"bin"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, data.LinuxPackages, 2)
+	for _, pkg := range data.LinuxPackages {
+		switch pkg.Arch {
+		case "amd64":
+			require.Equal(t, "v3", pkg.Goamd64)
+			require.Empty(t, pkg.Goarm)
+		case "arm":
+			require.Equal(t, "7", pkg.Goarm)
+			require.Empty(t, pkg.Goamd64)
+		default:
+			t.Fatalf("unexpected arch %q", pkg.Arch)
+		}
+	}
+}
+
+func TestRunPipeFromArchive(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "homebrew-tap",
+					},
+					FromArchive: map[string][]string{
+						"foo":       {"foo"},
+						"foo-tools": {"bar", "baz"},
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "foo_darwin_arm64.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("fake"), 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "foo_darwin_arm64.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo", "bar", "baz"},
+		},
+	})
+	require.NoError(t, Pipe{}.Default(ctx))
+	require.NoError(t, runAll(ctx, client.NewMock()))
+
+	formulas := ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List()
+	require.Len(t, formulas, 2)
+
+	foo, err := os.ReadFile(filepath.Join(folder, "homebrew", "foo.rb"))
+	require.NoError(t, err)
+	tools, err := os.ReadFile(filepath.Join(folder, "homebrew", "foo-tools.rb"))
+	require.NoError(t, err)
+
+	require.Contains(t, string(foo), "class Foo < Formula")
+	require.Contains(t, string(foo), `bin.install "foo"`)
+	require.NotContains(t, string(foo), `bin.install "bar"`)
+	require.Contains(t, string(tools), "class FooTools < Formula")
+	require.Contains(t, string(tools), `bin.install "bar"`)
+	require.Contains(t, string(tools), `bin.install "baz"`)
+	require.NotContains(t, string(tools), `bin.install "foo"`)
+
+	// both formulas share the same download.
+	for _, formula := range []string{string(foo), string(tools)} {
+		require.Contains(t, formula, `url "https://dummyhost/download/v1.2.1/foo_darwin_arm64.tar.gz"`)
+		require.Contains(t, formula, `sha256 "b5d54c39e66671c9731b9f471e585d8262cd4f54963f0c93082d8dcf334d4c78"`)
+	}
+}
+
+func TestRunPipePreferIDs(t *testing.T) {
+	folder := t.TempDir()
+	path := filepath.Join(folder, "bin")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	artifacts := []*artifact.Artifact{}
+	for _, id := range []string{"foo", "bar", "baz"} {
+		for _, goos := range []string{"darwin", "linux"} {
+			artifacts = append(artifacts, &artifact.Artifact{
+				Name:   id + "_" + goos + ".tar.gz",
+				Path:   path,
+				Goos:   goos,
+				Goarch: "amd64",
+				Type:   artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraID:       id,
+					artifact.ExtraFormat:   "tar.gz",
+					artifact.ExtraBinaries: []string{"bin"},
+				},
+			})
+		}
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := dataFor(testctx.New(), config.Homebrew{}, client.NewMock(), artifacts)
+		require.ErrorIs(t, err, ErrMultipleArchivesSameOS)
+	})
+
+	t.Run("prefer bar", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), config.Homebrew{
+			PreferIDs: []string{"bar", "foo"},
+		}, client.NewMock(), artifacts)
+		require.NoError(t, err)
+		require.Len(t, data.MacOSPackages, 1)
+		require.Len(t, data.LinuxPackages, 1)
+		require.Equal(t, "https://dummyhost/download//bar_darwin.tar.gz", data.MacOSPackages[0].DownloadURL)
+		require.Equal(t, "https://dummyhost/download//bar_linux.tar.gz", data.LinuxPackages[0].DownloadURL)
+	})
+}
+
+func TestRunPipeMultipleArchives(t *testing.T) {
+	folder := t.TempDir()
+	path := filepath.Join(folder, "bin")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	artifacts := []*artifact.Artifact{}
+	for _, goos := range []string{"darwin", "linux"} {
+		for id, binaries := range map[string][]string{
+			"tool":    {"tool"},
+			"plugins": {"tool-plugin-a", "tool-plugin-b"},
+		} {
+			artifacts = append(artifacts, &artifact.Artifact{
+				Name:   id + "_" + goos + ".tar.gz",
+				Path:   path,
+				Goos:   goos,
+				Goarch: "arm64",
+				Type:   artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraID:       id,
+					artifact.ExtraFormat:   "tar.gz",
+					artifact.ExtraBinaries: binaries,
+				},
+			})
+		}
+	}
+	// make the order deterministic, so the "tool" archive is the main one.
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Name > artifacts[j].Name
+	})
+
+	ctx := testctx.New(testctx.WithVersion("1.0.0"), testctx.WithCurrentTag("v1.0.0"))
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := dataFor(ctx, config.Homebrew{}, client.NewMock(), artifacts)
+		require.ErrorIs(t, err, ErrMultipleArchivesSameOS)
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		formula, err := buildFormula(ctx, config.Homebrew{
+			Name:                  "tool",
+			AllowMultipleArchives: true,
+		}, client.NewMock(), artifacts)
+		require.NoError(t, err)
+		golden.RequireEqualRb(t, []byte(formula))
+	})
+
+	t.Run("resources only install their binaries", func(t *testing.T) {
+		formula, err := buildFormula(ctx, config.Homebrew{
+			Name:                  "tool",
+			AllowMultipleArchives: true,
+			ExtraInstall:          `man1.install "tool.1"`,
+		}, client.NewMock(), artifacts)
+		require.NoError(t, err)
+		// once per OS, not again in the resource stages.
+		require.Equal(t, 2, strings.Count(formula, `man1.install "tool.1"`))
+		golden.RequireEqualRb(t, []byte(formula))
+	})
+}
+
+func TestRunPipePlaceholderChecksum(t *testing.T) {
+	artifacts := []*artifact.Artifact{{
+		Name:   "bin.tar.gz",
+		Path:   filepath.Join(t.TempDir(), "does-not-exist.tar.gz"),
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"bin"},
+		},
+	}}
+	cfg := config.Homebrew{
+		AllowPlaceholderChecksum: true,
+		URLTemplate:              "file:///tmp/dist/{{ .ArtifactName }}",
+	}
+
+	t.Run("snapshot", func(t *testing.T) {
+		data, err := dataFor(testctx.New(testctx.Snapshot), cfg, client.NewMock(), artifacts)
+		require.NoError(t, err)
+		require.Equal(t, placeholderChecksum, data.MacOSPackages[0].SHA256)
+		require.Equal(t, "file:///tmp/dist/bin.tar.gz", data.MacOSPackages[0].DownloadURL)
+	})
+
+	t.Run("not a snapshot", func(t *testing.T) {
+		_, err := dataFor(testctx.New(), cfg, client.NewMock(), artifacts)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("remote url", func(t *testing.T) {
+		cfg := cfg
+		cfg.URLTemplate = "https://example.com/{{ .ArtifactName }}"
+		_, err := dataFor(testctx.New(testctx.Snapshot), cfg, client.NewMock(), artifacts)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestIsLocalURL(t *testing.T) {
+	for url, local := range map[string]bool{
+		"file:///tmp/dist/bin.tar.gz":              true,
+		"http://localhost:8080/bin.tar.gz":         true,
+		"http://127.0.0.1/bin.tar.gz":              true,
+		"http://[::1]:8080/bin.tar.gz":             true,
+		"https://example.com/bin.tar.gz":           false,
+		"https://localhost.example.com/bin.tar.gz": false,
+		"https://github.com/foo/bar/bin.tar.gz":    false,
+		"://nope":                                  false,
+	} {
+		t.Run(url, func(t *testing.T) {
+			require.Equal(t, local, isLocalURL(url))
+		})
+	}
+}
+
+func TestRunPipePreserveArtifactOrder(t *testing.T) {
+	var artifacts []*artifact.Artifact
+	for _, goarch := range []string{"arm", "amd64", "arm64"} {
+		path := filepath.Join(t.TempDir(), "bin.tar.gz")
+		require.NoError(t, os.WriteFile(path, []byte(goarch), 0o644))
+		artifacts = append(artifacts, &artifact.Artifact{
+			Name:   "bin_" + goarch + ".tar.gz",
+			Path:   path,
+			Goos:   "linux",
+			Goarch: goarch,
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"bin"},
+			},
+		})
+	}
+	archs := func(pkgs []releasePackage) []string {
+		var result []string
+		for _, pkg := range pkgs {
+			result = append(result, pkg.Arch)
+		}
+		return result
+	}
+
+	data, err := dataFor(testctx.New(), config.Homebrew{
+		PreserveArtifactOrder: true,
+	}, client.NewMock(), artifacts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"arm", "amd64", "arm64"}, archs(data.LinuxPackages))
+}
+
+func TestRunPipeArtifactUploadName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	newArtifact := func(extra map[string]interface{}) []*artifact.Artifact {
+		extra[artifact.ExtraFormat] = "tar.gz"
+		return []*artifact.Artifact{{
+			Name:   "my bin.tar.gz",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra:  extra,
+		}}
+	}
+	cfg := config.Homebrew{
+		URLTemplate: "https://example.com/{{ .ArtifactUploadName }}",
+	}
+
+	t.Run("renamed", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), cfg, client.NewMock(), newArtifact(map[string]interface{}{
+			artifact.ExtraUploadName: "my.bin.tar.gz",
+		}))
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/my.bin.tar.gz", data.MacOSPackages[0].DownloadURL)
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		data, err := dataFor(testctx.New(), cfg, client.NewMock(), newArtifact(map[string]interface{}{}))
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/my bin.tar.gz", data.MacOSPackages[0].DownloadURL)
+	})
+}
+
+func TestRunPipeBinaryRelease(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:        "foo",
+					Homepage:    "https://goreleaser.com",
+					Description: "Fake desc",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
+					ExtraInstall: `man1.install "./man/foo.1.gz"`,
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "dist/foo_darwin_all/foo")
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "foo_macos",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "all",
+		Type:   artifact.UploadableBinary,
+		Extra: map[string]interface{}{
+			artifact.ExtraID:     "foo",
+			artifact.ExtraFormat: "binary",
+			artifact.ExtraBinary: "foo",
+		},
+	})
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	client := client.NewMock()
+	require.NoError(t, runAll(ctx, client))
+	require.NoError(t, publishAll(ctx, client))
+	require.True(t, client.CreatedFile)
+	golden.RequireEqualRb(t, []byte(client.Content))
+}
+
+func TestRunPipeSharedStrategyFile(t *testing.T) {
+	folder := t.TempDir()
+	strategy := filepath.Join(t.TempDir(), "strategy.rb")
+	require.NoError(t, os.WriteFile(strategy, []byte("class CustomDownloadStrategy; end\n"), 0o644))
+	url := testlib.GitMakeBareRepository(t)
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:               "foo",
+					Homepage:           "https://goreleaser.com",
+					Description:        "Fake desc",
+					Folder:             "Formula",
+					DownloadStrategy:   "CustomDownloadStrategy",
+					SharedStrategyFile: strategy,
+					Repository: config.RepoRef{
+						Name: "bar",
+						Git: config.GitRepoRef{
+							URL:        url,
+							PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+						},
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "dist/foo_darwin_all/foo")
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "foo_macos",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "all",
+		Type:   artifact.UploadableBinary,
+		Extra: map[string]interface{}{
+			artifact.ExtraID:     "foo",
+			artifact.ExtraFormat: "binary",
+			artifact.ExtraBinary: "foo",
+		},
+	})
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, Pipe{}.Default(ctx))
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	require.NoError(t, publishAll(ctx, client.NewMock()))
+
+	formula := string(testlib.CatFileFromBareRepository(t, url, "Formula/foo.rb"))
+	require.Contains(t, formula, `require_relative "../lib/strategy"`)
+	require.Contains(t, formula, `using: CustomDownloadStrategy`)
+	require.Equal(t, "class CustomDownloadStrategy; end\n", string(testlib.CatFileFromBareRepository(t, url, "lib/strategy.rb")))
+}
+
+func TestRunPipeExtraFiles(t *testing.T) {
+	newCtx := func(t *testing.T, url string, files []config.ExtraFile) *context.Context {
+		t.Helper()
+		folder := t.TempDir()
+		ctx := testctx.NewWithCfg(
+			config.Project{
+				Dist:        folder,
+				ProjectName: "foo",
+				Brews: []config.Homebrew{
+					{
+						Name:        "foo",
+						Homepage:    "https://goreleaser.com",
+						Description: "Fake desc",
+						Folder:      "Formula",
+						ExtraFiles:  files,
+						Repository: config.RepoRef{
+							Name: "bar",
+							Git: config.GitRepoRef{
+								URL:        url,
+								PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+							},
+						},
+					},
+				},
+			},
+			testctx.WithVersion("1.2.1"),
+			testctx.WithCurrentTag("v1.2.1"),
+		)
+		path := filepath.Join(folder, "dist/foo_darwin_all/foo")
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:   "foo_macos",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "all",
+			Type:   artifact.UploadableBinary,
+			Extra: map[string]interface{}{
+				artifact.ExtraID:     "foo",
+				artifact.ExtraFormat: "binary",
+				artifact.ExtraBinary: "foo",
+			},
+		})
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		require.NoError(t, Pipe{}.Default(ctx))
+		return ctx
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		testlib.Mktmp(t)
+		require.NoError(t, os.WriteFile("multiple.intoto.jsonl", []byte(`{"payload":"fake"}`), 0o644))
+		url := testlib.GitMakeBareRepository(t)
+		ctx := newCtx(t, url, []config.ExtraFile{{
+			Glob:         "*.intoto.jsonl",
+			NameTemplate: "{{ .ProjectName }}.intoto.jsonl",
+		}})
+
+		require.NoError(t, runAll(ctx, client.NewMock()))
+		require.NoError(t, publishAll(ctx, client.NewMock()))
+
+		require.Contains(t, string(testlib.CatFileFromBareRepository(t, url, "Formula/foo.rb")), "class Foo < Formula")
+		require.Equal(t, `{"payload":"fake"}`, string(testlib.CatFileFromBareRepository(t, url, "Formula/foo.intoto.jsonl")))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		testlib.Mktmp(t)
+		ctx := newCtx(t, testlib.GitMakeBareRepository(t), []config.ExtraFile{{
+			Glob: "*.intoto.jsonl",
+		}})
+		require.ErrorContains(t, runAll(ctx, client.NewMock()), "brew: no extra files found matching")
+	})
+}
+
+func TestSharedStrategyRequire(t *testing.T) {
+	for folder, expected := range map[string]string{
+		"":               "lib/strategy",
+		"Formula":        "../lib/strategy",
+		"Formula/tools/": "../../lib/strategy",
+	} {
+		t.Run(folder, func(t *testing.T) {
+			require.Equal(t, expected, sharedStrategyRequire(folder, "./brew/strategy.rb"))
+		})
+	}
+}
+
+func TestRunPipeLowercaseFileName(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:              "MyFoo",
+					LowercaseFileName: true,
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	client := client.NewMock()
+	require.NoError(t, runAll(ctx, client))
+	require.NoError(t, publishAll(ctx, client))
+	require.Equal(t, "myfoo.rb", client.Path)
+	require.FileExists(t, filepath.Join(folder, "homebrew", "myfoo.rb"))
+	require.Contains(t, client.Content, "class MyFoo < Formula")
+	require.NoFileExists(t, filepath.Join(folder, "homebrew-preview", "myfoo.rb"))
+}
+
+func TestRunPipeChecksumAlgorithm(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:              "foo",
+					ChecksumAlgorithm: "sha512",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("foo"), 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	client := client.NewMock()
+	require.NoError(t, runAll(ctx, client))
+	require.NoError(t, publishAll(ctx, client))
+	require.Regexp(t, `\n +sha512 "[a-f0-9]{128}"\n`, client.Content)
+	require.NotContains(t, client.Content, "sha256")
+}
+
+func TestRunPipeRepositoryExtra(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:   "foo",
+					Folder: "Formula",
+					Repository: config.RepoRef{
+						Owner:  "foo",
+						Name:   "{{ .ProjectName }}-tap",
+						Branch: "update-{{ .Version }}",
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	formulas := ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List()
+	require.Len(t, formulas, 1)
+	repo, err := artifact.Extra[Repository](*formulas[0], ExtraRepository)
+	require.NoError(t, err)
+	require.Equal(t, Repository{
+		Owner:  "foo",
+		Name:   "foo-tap",
+		Branch: "update-1.2.1",
+		Path:   "Formula/foo.rb",
+	}, repo)
+}
+
+func TestRunPipeFormulaExtras(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:        "foo-bar",
+					ClassSuffix: "CLI",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
 				},
+			},
+		},
+		testctx.WithVersion("1.2.1+build.5"),
+		testctx.WithCurrentTag("v1.2.1+build.5"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	formulas := ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List()
+	require.Len(t, formulas, 1)
+	formula := *formulas[0]
+	require.Equal(t, "foo-bar", artifact.ExtraOr(formula, ExtraFormulaName, ""))
+	require.Equal(t, "FooBarCLI", artifact.ExtraOr(formula, ExtraFormulaClassName, ""))
+	require.Equal(t, "1.2.1", artifact.ExtraOr(formula, ExtraVersion, ""))
+
+	brew, err := artifact.Extra[config.Homebrew](formula, brewConfigExtra)
+	require.NoError(t, err)
+	require.Equal(t, "foo-bar", brew.Name)
+}
+
+func TestRunPipeHead(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
 				{
-					name:    "amd64v3",
-					goos:    "linux",
-					goarch:  "amd64",
-					goamd64: "v4",
+					Name:        "foo",
+					Description: "Foo",
+					Homepage:    "https://goreleaser.com",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "homebrew-tap",
+					},
+					Head: config.HomebrewHead{
+						URL: "https://github.com/foo/{{ .ProjectName }}.git",
+						Dependencies: []config.HomebrewDependency{
+							{Name: "go", Type: "build"},
+							{Name: "make", Type: "build"},
+						},
+					},
 				},
-			} {
-				path := filepath.Join(folder, fmt.Sprintf("%s.tar.gz", a.name))
-				ctx.Artifacts.Add(&artifact.Artifact{
-					Name:    fmt.Sprintf("%s.tar.gz", a.name),
-					Path:    path,
-					Goos:    a.goos,
-					Goarch:  a.goarch,
-					Goamd64: a.goamd64,
-					Type:    artifact.UploadableArchive,
-					Extra: map[string]interface{}{
-						artifact.ExtraID:     a.name,
-						artifact.ExtraFormat: "tar.gz",
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	formula, err := os.ReadFile(filepath.Join(folder, "homebrew", "foo.rb"))
+	require.NoError(t, err)
+	require.Contains(t, string(formula), `
+  head do
+    url "https://github.com/foo/foo.git"
+    depends_on "go" => :build
+    depends_on "make" => :build
+  end
+`)
+	require.Contains(t, string(formula), `url "https://dummyhost/download/v1.2.1/bin.tar.gz"`)
+
+	golden.RequireEqualRb(t, formula)
+}
+
+func TestRunPipeHeadInstall(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:        "foo",
+					Description: "Foo",
+					Homepage:    "https://goreleaser.com",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "homebrew-tap",
 					},
-				})
-				f, err := os.Create(path)
-				require.NoError(t, err)
-				require.NoError(t, f.Close())
-			}
+					Head: config.HomebrewHead{
+						URL:          "https://github.com/foo/{{ .ProjectName }}.git",
+						Dependencies: []config.HomebrewDependency{{Name: "go", Type: "build"}},
+						Install: `system "go", "build", *std_go_args(ldflags: "-s -w -X main.version={{ .Version }}-HEAD")
+man1.install "man/foo.1"`,
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	for _, goos := range []string{"darwin", "linux"} {
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:   "bin_" + goos + ".tar.gz",
+			Path:   path,
+			Goos:   goos,
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		})
+	}
 
-			client := client.NewMock()
-			distFile := filepath.Join(folder, "homebrew", name+".rb")
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	formula, err := os.ReadFile(filepath.Join(folder, "homebrew", "foo.rb"))
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(formula), "build.head?"))
+	require.Contains(t, string(formula), `
+  alias stable_install install
+
+  def install
+    if build.head?
+      system "go", "build", *std_go_args(ldflags: "-s -w -X main.version=1.2.1-HEAD")
+      man1.install "man/foo.1"
+      return
+    end
+    stable_install
+  end
+`)
+
+	golden.RequireEqualRb(t, formula)
+}
+
+func TestRunValidateOnly(t *testing.T) {
+	setup := func(t *testing.T, brews ...config.Homebrew) *context.Context {
+		t.Helper()
+		folder := t.TempDir()
+		ctx := testctx.NewWithCfg(
+			config.Project{
+				Dist:        folder,
+				ProjectName: "foo",
+				Brews:       brews,
+			},
+			testctx.WithVersion("1.2.1"),
+			testctx.WithCurrentTag("v1.2.1"),
+			testctx.GitHubTokenType,
+		)
+		// no token is set, and the API can't be reached either.
+		ctx.BrewValidateOnly = true
+		ctx.Config.GitHubURLs.API = "http://localhost:1/"
+		path := filepath.Join(folder, "bin.tar.gz")
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:   "bin.tar.gz",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		})
+		require.NoError(t, Pipe{}.Default(ctx))
+		return ctx
+	}
+	repo := config.RepoRef{Owner: "foo", Name: "homebrew-tap"}
+
+	t.Run("valid", func(t *testing.T) {
+		ctx := setup(t,
+			config.Homebrew{Name: "foo", Repository: repo},
+			config.Homebrew{Name: "skipped"},
+		)
+		require.NoError(t, Pipe{}.Run(ctx))
+		require.NoDirExists(t, filepath.Join(ctx.Config.Dist, "homebrew"))
+		require.Empty(t, ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		ctx := setup(t,
+			config.Homebrew{Name: "foo", Repository: repo},
+			config.Homebrew{Name: "bar", Repository: config.RepoRef{Owner: "{{ .Env.NOPE }}", Name: "tap"}},
+			config.Homebrew{Name: "baz", Repository: repo, IDs: []string{"nope"}},
+			config.Homebrew{Name: "qux", Repository: repo, ExtraFiles: []config.ExtraFile{{Glob: "./testdata/nope/*"}}},
+		)
+		ctx.Config.Brews[0].Dependencies = []config.HomebrewDependency{{Name: "go", Type: "runtime"}}
+		err := Pipe{}.Run(ctx)
+		require.Error(t, err)
+		require.ErrorContains(t, err, `brews[0]: dependencies[0].type: invalid value "runtime"`)
+		require.ErrorContains(t, err, "brews[1]: template: tmpl:1:7")
+		require.ErrorContains(t, err, "brews[2]: no linux/macos archives found")
+		require.ErrorContains(t, err, `brews[3]: brew: no extra files found matching "./testdata/nope/*"`)
+		require.NoDirExists(t, filepath.Join(ctx.Config.Dist, "homebrew"))
+	})
+}
+
+func TestRunPipeFormulaSHA(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:                  "foo",
+					Folder:                "Formula/{{ slice .FormulaSHA 0 2 }}/{{ .FormulaSHA }}",
+					CommitMessageTemplate: "foo {{ .Tag }} ({{ .FormulaSHA }})",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "homebrew-tap",
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	cli := client.NewMock()
+	require.NoError(t, runAll(ctx, cli))
+	require.NoError(t, publishAll(ctx, cli))
+
+	sum := sha256.Sum256([]byte(cli.Content))
+	sha := hex.EncodeToString(sum[:])
+	require.Equal(t, "Formula/"+sha[:2]+"/"+sha+"/foo.rb", cli.Path)
+	require.Equal(t, []string{"foo v1.2.1 (" + sha + ")"}, cli.Messages)
+	require.FileExists(t, filepath.Join(folder, "homebrew", "Formula", sha[:2], sha, "foo.rb"))
+}
+
+func TestRunPipeExcludeNameRegex(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:             "foo",
+					ExcludeNameRegex: `_debug\.`,
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "homebrew-tap",
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	for _, name := range []string{"foo_debug.tar.gz", "foo.tar.gz"} {
+		path := filepath.Join(folder, name)
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:   name,
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		})
+	}
+
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	formula, err := os.ReadFile(filepath.Join(folder, "homebrew", "foo.rb"))
+	require.NoError(t, err)
+	require.Contains(t, string(formula), `url "https://dummyhost/download/v1.2.1/foo.tar.gz"`)
+	require.NotContains(t, string(formula), "foo_debug")
+
+	t.Run("everything excluded", func(t *testing.T) {
+		ctx.Config.Brews[0].ExcludeNameRegex = `^foo`
+		require.ErrorAs(t, runAll(ctx, client.NewMock()), &ErrNoArchivesFound{})
+	})
+}
+
+func TestRunPipeSkipIfUnchanged(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:            "foo",
+					SkipIfUnchanged: true,
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "homebrew-tap",
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	cli := client.NewMock()
+	require.NoError(t, runAll(ctx, cli))
+	require.NoError(t, publishAll(ctx, cli))
+	require.Len(t, cli.Messages, 1)
+
+	err := publishAll(ctx, cli)
+	testlib.AssertSkipped(t, err)
+	require.EqualError(t, err, "formula unchanged")
+	require.Len(t, cli.Messages, 1)
+
+	t.Run("changed", func(t *testing.T) {
+		cli.Files["foo.rb"] = "old formula"
+		require.NoError(t, publishAll(ctx, cli))
+		require.Len(t, cli.Messages, 2)
+	})
+}
+
+func TestRunPipeGitCommitBranch(t *testing.T) {
+	folder := t.TempDir()
+	url := testlib.GitMakeBareRepository(t)
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name: "foo",
+					Repository: config.RepoRef{
+						Name:         "foo",
+						Branch:       "main",
+						CommitBranch: "formulas-{{ .Version }}",
+						Git: config.GitRepoRef{
+							URL:        url,
+							PrivateKey: testlib.MakeNewSSHKey(t, keygen.Ed25519, ""),
+						},
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
 
-			require.NoError(t, runAll(ctx, client))
-			require.NoError(t, publishAll(ctx, client))
-			require.True(t, client.CreatedFile)
-			golden.RequireEqualRb(t, []byte(client.Content))
+	cli := client.NewMock()
+	require.NoError(t, Pipe{}.Default(ctx))
+	require.NoError(t, runAll(ctx, cli))
+	require.NoError(t, publishAll(ctx, cli))
+	content := testlib.CatFileFromBareRepositoryBranch(t, url, "formulas-1.2.1", "foo.rb")
+	require.Contains(t, string(content), "class Foo < Formula")
+}
 
-			distBts, err := os.ReadFile(distFile)
-			require.NoError(t, err)
-			require.Equal(t, client.Content, string(distBts))
+func TestRunPipePostPublish(t *testing.T) {
+	repo := config.RepoRef{Owner: "foo", Name: "homebrew-tap"}
+	setup := func(t *testing.T, brew config.Homebrew) *context.Context {
+		t.Helper()
+		folder := t.TempDir()
+		ctx := testctx.NewWithCfg(
+			config.Project{
+				Dist:        folder,
+				ProjectName: "foo",
+				Brews:       []config.Homebrew{brew},
+			},
+			testctx.WithVersion("1.2.1"),
+			testctx.WithCurrentTag("v1.2.1"),
+			testctx.WithEnv(map[string]string{"CHANNEL": "releases"}),
+		)
+		path := filepath.Join(folder, "bin.tar.gz")
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:   "bin.tar.gz",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
 		})
+		require.NoError(t, runAll(ctx, client.NewMock()))
+		return ctx
 	}
+
+	t.Run("success", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "env")
+		ctx := setup(t, config.Homebrew{
+			Name:        "foo",
+			PostPublish: "sh -c 'echo $FORMULA_NAME $FORMULA_VERSION $FORMULA_REPOSITORY {{ .Env.CHANNEL }} > " + out + "'",
+			Repository:  repo,
+		})
+		require.NoError(t, publishAll(ctx, client.NewMock()))
+		bts, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.Equal(t, "foo 1.2.1 foo/homebrew-tap releases\n", string(bts))
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "env")
+		ctx := setup(t, config.Homebrew{
+			Name:        "foo",
+			PostPublish: "touch " + out,
+			SkipUpload:  "true",
+			Repository:  repo,
+		})
+		testlib.AssertSkipped(t, publishAll(ctx, client.NewMock()))
+		require.NoFileExists(t, out)
+	})
+
+	t.Run("failing hook", func(t *testing.T) {
+		ctx := setup(t, config.Homebrew{Name: "foo", PostPublish: "false", Repository: repo})
+		require.ErrorContains(t, publishAll(ctx, client.NewMock()), "brew: post publish hook failed")
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		ctx := setup(t, config.Homebrew{Name: "foo", PostPublish: "echo {{ .Nope }", Repository: repo})
+		testlib.RequireTemplateError(t, publishAll(ctx, client.NewMock()))
+	})
 }
 
-func TestRunPipeForMultipleArmVersions(t *testing.T) {
-	for name, fn := range map[string]func(ctx *context.Context){
-		"multiple_armv5": func(ctx *context.Context) {
-			ctx.Config.Brews[0].Goarm = "5"
-		},
-		"multiple_armv6": func(ctx *context.Context) {
-			ctx.Config.Brews[0].Goarm = "6"
-		},
-		"multiple_armv7": func(ctx *context.Context) {
-			ctx.Config.Brews[0].Goarm = "7"
-		},
-	} {
-		t.Run(name, func(t *testing.T) {
-			folder := t.TempDir()
-			ctx := testctx.NewWithCfg(
-				config.Project{
-					Dist:        folder,
-					ProjectName: name,
-					Brews: []config.Homebrew{
-						{
-							Name:         name,
-							Description:  "Run pipe test formula and FOO={{ .Env.FOO }}",
-							Caveats:      "don't do this {{ .ProjectName }}",
-							Test:         "system \"true\"\nsystem \"#{bin}/foo\", \"-h\"",
-							Plist:        `<xml>whatever</xml>`,
-							Dependencies: []config.HomebrewDependency{{Name: "zsh"}, {Name: "bash", Type: "recommended"}},
-							Conflicts:    []string{"gtk+", "qt"},
-							Install:      `bin.install "{{ .ProjectName }}"`,
-							Repository: config.RepoRef{
-								Owner: "test",
-								Name:  "test",
-							},
-							Homepage: "https://github.com/goreleaser",
+func TestRunPipeOnlyNewerVersion(t *testing.T) {
+	setup := func(t *testing.T) *context.Context {
+		t.Helper()
+		folder := t.TempDir()
+		ctx := testctx.NewWithCfg(
+			config.Project{
+				Dist:        folder,
+				ProjectName: "foo",
+				Brews: []config.Homebrew{
+					{
+						Name:             "foo",
+						OnlyNewerVersion: true,
+						Repository: config.RepoRef{
+							Owner: "foo",
+							Name:  "homebrew-tap",
 						},
 					},
-					GitHubURLs: config.GitHubURLs{
-						Download: "https://github.com",
+				},
+			},
+			testctx.WithVersion("1.2.1"),
+			testctx.WithCurrentTag("v1.2.1"),
+			testctx.WithSemver(1, 2, 1, ""),
+		)
+		path := filepath.Join(folder, "bin.tar.gz")
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:   "bin.tar.gz",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		})
+		require.NoError(t, runAll(ctx, client.NewMock()))
+		return ctx
+	}
+
+	for name, tt := range map[string]struct {
+		published string
+		skip      bool
+	}{
+		"newer published":     {published: "1.3.0", skip: true},
+		"same published":      {published: "1.2.1", skip: true},
+		"older published":     {published: "1.2.0"},
+		"older prerelease":    {published: "1.2.1-rc1"},
+		"unparseable version": {published: "latest"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := setup(t)
+			cli := client.NewMock()
+			cli.Files = map[string]string{
+				"foo.rb": "class Foo < Formula\n  version \"" + tt.published + "\"\nend\n",
+			}
+			err := publishAll(ctx, cli)
+			if tt.skip {
+				testlib.AssertSkipped(t, err)
+				require.EqualError(t, err, "formula version 1.2.1 is not newer than the published "+tt.published)
+				require.Empty(t, cli.Messages)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, cli.Messages, 1)
+		})
+	}
+
+	t.Run("not published", func(t *testing.T) {
+		ctx := setup(t)
+		cli := client.NewMock()
+		require.NoError(t, publishAll(ctx, cli))
+		require.Len(t, cli.Messages, 1)
+	})
+}
+
+// repoRecorder is a mock client that records the files created in each
+// repository.
+type repoRecorder struct {
+	*client.Mock
+	files map[string]string
+}
+
+func (r *repoRecorder) CreateFile(ctx *context.Context, author config.CommitAuthor, repo client.Repo, content []byte, path, msg string) error {
+	r.Lock.Lock()
+	r.files[repo.Owner+"/"+repo.Name+"/"+path] = string(content)
+	r.Lock.Unlock()
+	return r.Mock.CreateFile(ctx, author, repo, content, path, msg)
+}
+
+func TestRunPipeMultipleRepositories(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name: "foo",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "homebrew-tap",
 					},
-					Release: config.Release{
-						GitHub: config.Repo{
-							Owner: "test",
-							Name:  "test",
+					Repositories: []config.RepoRef{
+						{
+							Owner: "{{ .ProjectName }}-mirror",
+							Name:  "homebrew-tap",
 						},
 					},
-					Env: []string{"FOO=foo_is_bar"},
-				},
-				testctx.GitHubTokenType,
-				testctx.WithVersion("1.0.1"),
-				testctx.WithCurrentTag("v1.0.1"),
-			)
-			fn(ctx)
-			for _, a := range []struct {
-				name   string
-				goos   string
-				goarch string
-				goarm  string
-			}{
-				{
-					name:   "bin",
-					goos:   "darwin",
-					goarch: "amd64",
-				},
-				{
-					name:   "arm64",
-					goos:   "linux",
-					goarch: "arm64",
-				},
-				{
-					name:   "armv5",
-					goos:   "linux",
-					goarch: "arm",
-					goarm:  "5",
 				},
-				{
-					name:   "armv6",
-					goos:   "linux",
-					goarch: "arm",
-					goarm:  "6",
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	cli := &repoRecorder{Mock: client.NewMock(), files: map[string]string{}}
+	require.NoError(t, runAll(ctx, cli))
+	require.NoError(t, publishAll(ctx, cli))
+	require.Len(t, cli.Messages, 2)
+	require.Len(t, cli.files, 2)
+	require.NotEmpty(t, cli.files["foo/homebrew-tap/foo.rb"])
+	require.Equal(t, cli.files["foo/homebrew-tap/foo.rb"], cli.files["foo-mirror/homebrew-tap/foo.rb"])
+
+	t.Run("only repositories", func(t *testing.T) {
+		brew := ctx.Config.Brews[0]
+		brew.Repository = config.RepoRef{}
+		_, _, err := generateFormula(ctx, brew, cli)
+		require.NoError(t, err)
+	})
+}
+
+func TestRunAllConcurrently(t *testing.T) {
+	setup := func(t *testing.T, names ...string) *context.Context {
+		t.Helper()
+		folder := t.TempDir()
+		brews := make([]config.Homebrew, 0, len(names))
+		for _, name := range names {
+			brews = append(brews, config.Homebrew{
+				Name: name,
+				Repository: config.RepoRef{
+					Owner: "foo",
+					Name:  "homebrew-tap",
 				},
+			})
+		}
+		ctx := testctx.NewWithCfg(
+			config.Project{
+				Dist:        folder,
+				ProjectName: "foo",
+				Brews:       brews,
+			},
+			testctx.WithVersion("1.2.1"),
+			testctx.WithCurrentTag("v1.2.1"),
+		)
+		ctx.Parallelism = 2
+		path := filepath.Join(folder, "bin.tar.gz")
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:   "bin.tar.gz",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		})
+		return ctx
+	}
+	names := []string{"foo", "bar", "baz", "qux", "quux"}
+
+	t.Run("all formulas", func(t *testing.T) {
+		ctx := setup(t, names...)
+		require.NoError(t, runAll(ctx, client.NewMock()))
+		formulas := ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List()
+		got := make([]string, 0, len(formulas))
+		for _, formula := range formulas {
+			got = append(got, formula.Name)
+			require.FileExists(t, formula.Path)
+		}
+		require.ElementsMatch(t, []string{"foo.rb", "bar.rb", "baz.rb", "qux.rb", "quux.rb"}, got)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ctx := setup(t, names...)
+		ctx.Config.Brews[2].Name = "{{ .Nope }}"
+		testlib.RequireTemplateError(t, runAll(ctx, client.NewMock()))
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		ctx := setup(t, names...)
+		ctx.Config.Brews[0].Repository.Name = ""
+		testlib.AssertSkipped(t, runAll(ctx, client.NewMock()))
+		require.Len(t, ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List(), len(names)-1)
+	})
+}
+
+func TestRunPipePreview(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
 				{
-					name:   "armv7",
-					goos:   "linux",
-					goarch: "arm",
-					goarm:  "7",
-				},
-			} {
-				path := filepath.Join(folder, fmt.Sprintf("%s.tar.gz", a.name))
-				ctx.Artifacts.Add(&artifact.Artifact{
-					Name:   fmt.Sprintf("%s.tar.gz", a.name),
-					Path:   path,
-					Goos:   a.goos,
-					Goarch: a.goarch,
-					Goarm:  a.goarm,
-					Type:   artifact.UploadableArchive,
-					Extra: map[string]interface{}{
-						artifact.ExtraID:     a.name,
-						artifact.ExtraFormat: "tar.gz",
+					Name:    "foo",
+					Folder:  "Formula",
+					Preview: true,
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
 					},
-				})
-				f, err := os.Create(path)
-				require.NoError(t, err)
-				require.NoError(t, f.Close())
-			}
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	formula, err := os.ReadFile(filepath.Join(folder, "homebrew", "Formula", "foo.rb"))
+	require.NoError(t, err)
+	preview, err := os.ReadFile(filepath.Join(folder, "homebrew-preview", "Formula", "foo.rb"))
+	require.NoError(t, err)
+	require.Equal(t, string(formula), string(preview))
+	require.Len(t, ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List(), 1)
+}
+
+func TestRunPipePublishDelay(t *testing.T) {
+	folder := t.TempDir()
+	brew := config.Homebrew{
+		Folder:       "Formula",
+		PublishDelay: "100ms",
+		Repository: config.RepoRef{
+			Owner: "foo",
+			Name:  "bar",
+		},
+	}
+	foo, bar := brew, brew
+	foo.Name = "foo"
+	bar.Name = "bar"
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews:       []config.Homebrew{foo, bar},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	cli := client.NewMock()
+	require.NoError(t, runAll(ctx, cli))
+	start := time.Now()
+	require.NoError(t, publishAll(ctx, cli))
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	require.Len(t, cli.Messages, 2)
+}
+
+func TestRunPipeSkipUploadPerOS(t *testing.T) {
+	setup := func(t *testing.T, brew config.Homebrew) *context.Context {
+		t.Helper()
+		folder := t.TempDir()
+		brew.Name = "foo"
+		brew.Goamd64 = "v1"
+		brew.Repository = config.RepoRef{
+			Owner: "foo",
+			Name:  "bar",
+		}
+		ctx := testctx.NewWithCfg(
+			config.Project{
+				Dist:        folder,
+				ProjectName: "foo",
+				Brews:       []config.Homebrew{brew},
+			},
+			testctx.WithVersion("1.2.1"),
+			testctx.WithCurrentTag("v1.2.1"),
+		)
+		for _, goos := range []string{"darwin", "linux"} {
+			path := filepath.Join(folder, "foo_"+goos+".tar.gz")
+			require.NoError(t, os.WriteFile(path, nil, 0o644))
+			ctx.Artifacts.Add(&artifact.Artifact{
+				Name:    "foo_" + goos + ".tar.gz",
+				Path:    path,
+				Goos:    goos,
+				Goarch:  "amd64",
+				Goamd64: "v1",
+				Type:    artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraFormat:   "tar.gz",
+					artifact.ExtraBinaries: []string{"foo"},
+				},
+			})
+		}
+		return ctx
+	}
 
-			client := client.NewMock()
-			distFile := filepath.Join(folder, "homebrew", name+".rb")
+	t.Run("macos skipped", func(t *testing.T) {
+		ctx := setup(t, config.Homebrew{SkipUploadMacOS: "{{ .Env.SKIP }}"})
+		ctx.Env["SKIP"] = "true"
+		cli := client.NewMock()
+		require.NoError(t, runAll(ctx, cli))
+		require.NoError(t, publishAll(ctx, cli))
+		require.True(t, cli.CreatedFile)
+		require.Contains(t, cli.Content, "foo_linux.tar.gz")
+		require.NotContains(t, cli.Content, "foo_darwin.tar.gz")
+		require.NotContains(t, cli.Content, "on_macos")
+	})
 
-			require.NoError(t, runAll(ctx, client))
-			require.NoError(t, publishAll(ctx, client))
-			require.True(t, client.CreatedFile)
-			golden.RequireEqualRb(t, []byte(client.Content))
+	t.Run("linux skipped on prerelease", func(t *testing.T) {
+		ctx := setup(t, config.Homebrew{SkipUploadLinux: "auto"})
+		ctx.Semver.Prerelease = "rc1"
+		cli := client.NewMock()
+		require.NoError(t, runAll(ctx, cli))
+		require.NoError(t, publishAll(ctx, cli))
+		require.Contains(t, cli.Content, "foo_darwin.tar.gz")
+		require.NotContains(t, cli.Content, "foo_linux.tar.gz")
+	})
 
-			distBts, err := os.ReadFile(distFile)
-			require.NoError(t, err)
-			require.Equal(t, client.Content, string(distBts))
-		})
-	}
+	t.Run("global skip upload", func(t *testing.T) {
+		ctx := setup(t, config.Homebrew{SkipUpload: "true", SkipUploadMacOS: "true"})
+		cli := client.NewMock()
+		require.NoError(t, runAll(ctx, cli))
+		require.EqualError(t, publishAll(ctx, cli), "brew.skip_upload is set")
+		require.False(t, cli.CreatedFile)
+		bts, err := os.ReadFile(filepath.Join(ctx.Config.Dist, "homebrew", "foo.rb"))
+		require.NoError(t, err)
+		require.Contains(t, string(bts), "foo_linux.tar.gz")
+	})
 }
 
-func TestRunPipeNoBuilds(t *testing.T) {
-	ctx := testctx.NewWithCfg(config.Project{
-		Brews: []config.Homebrew{
-			{
-				Repository: config.RepoRef{
-					Owner: "test",
-					Name:  "test",
-				},
-				IDs: []string{"foo"},
+func TestRunPipeChecksumCache(t *testing.T) {
+	var calls atomic.Int32
+	previous := archiveChecksums
+	archiveChecksums = newChecksumCache(func(art *artifact.Artifact, algorithm string) (string, error) {
+		calls.Add(1)
+		return art.Checksum(algorithm)
+	})
+	t.Cleanup(func() { archiveChecksums = previous })
+
+	folder := t.TempDir()
+	repo := config.RepoRef{Owner: "foo", Name: "bar"}
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{Name: "foo", Goamd64: "v1", Repository: repo},
+				{Name: "foo-cli", Goamd64: "v1", Repository: repo},
 			},
 		},
-	}, testctx.GitHubTokenType)
-	client := client.NewMock()
-	require.NoError(t, Pipe{}.Default(ctx))
-	require.EqualError(t, runAll(ctx, client), ErrNoArchivesFound{
-		ids:     []string{"foo"},
-		goarm:   "6",
-		goamd64: "v1",
-	}.Error())
-	require.False(t, client.CreatedFile)
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	path := filepath.Join(folder, "foo.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("foo"), 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:    "foo.tar.gz",
+		Path:    path,
+		Goos:    "darwin",
+		Goarch:  "amd64",
+		Goamd64: "v1",
+		Type:    artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	require.Len(t, ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List(), 2)
+	require.Equal(t, int32(1), calls.Load())
+
+	t.Run("changed file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("foo bar"), 0o644))
+		sum, err := archiveChecksums.get(ctx.Artifacts.List()[0], "sha256")
+		require.NoError(t, err)
+		require.Equal(t, int32(2), calls.Load())
+		require.Equal(t, "fbc1a9f858ea9e177916964bd88c3d37b91a1e84412765e29950777f265c4b75", sum)
+	})
 }
 
-func TestRunPipeMultipleArchivesSameOsBuild(t *testing.T) {
-	ctx := testctx.NewWithCfg(config.Project{
-		Brews: []config.Homebrew{
-			{
-				Repository: config.RepoRef{
-					Owner: "test",
-					Name:  "test",
+func TestRunPipeFormatCommand(t *testing.T) {
+	setup := func(t *testing.T, command string) *context.Context {
+		t.Helper()
+		folder := t.TempDir()
+		ctx := testctx.NewWithCfg(
+			config.Project{
+				Dist:        folder,
+				ProjectName: "foo",
+				Brews: []config.Homebrew{
+					{
+						Name:          "foo",
+						Goamd64:       "v1",
+						FormatCommand: command,
+						Repository: config.RepoRef{
+							Owner: "foo",
+							Name:  "bar",
+						},
+					},
 				},
 			},
-		},
-	}, testctx.GitHubTokenType)
+			testctx.WithVersion("1.2.1"),
+			testctx.WithCurrentTag("v1.2.1"),
+		)
+		path := filepath.Join(folder, "foo.tar.gz")
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:    "foo.tar.gz",
+			Path:    path,
+			Goos:    "darwin",
+			Goarch:  "amd64",
+			Goamd64: "v1",
+			Type:    artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		})
+		return ctx
+	}
 
-	f, err := os.CreateTemp(t.TempDir(), "")
-	require.NoError(t, err)
-	t.Cleanup(func() {
-		require.NoError(t, f.Close())
+	t.Run("formatted", func(t *testing.T) {
+		ctx := setup(t, `sh -c 'echo "# formatted" >> "$FORMULA_PATH"'`)
+		cli := client.NewMock()
+		require.NoError(t, runAll(ctx, cli))
+		require.NoError(t, publishAll(ctx, cli))
+		require.True(t, strings.HasSuffix(cli.Content, "end\n# formatted\n"))
 	})
 
-	tests := []struct {
-		expectedError error
-		osarchs       []struct {
-			goos   string
-			goarch string
-			goarm  string
-		}
-	}{
-		{
-			expectedError: ErrMultipleArchivesSameOS,
-			osarchs: []struct {
-				goos   string
-				goarch string
-				goarm  string
-			}{
-				{
-					goos:   "darwin",
-					goarch: "amd64",
-				},
-				{
-					goos:   "darwin",
-					goarch: "amd64",
-				},
+	t.Run("failing", func(t *testing.T) {
+		ctx := setup(t, "false")
+		require.ErrorContains(t, runAll(ctx, client.NewMock()), "brew: format command failed")
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		ctx := setup(t, "{{ .Nope }")
+		testlib.RequireTemplateError(t, runAll(ctx, client.NewMock()))
+	})
+}
+
+func TestRunPipeRepositoryTokenEnv(t *testing.T) {
+	var auth atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.CompareAndSwap(nil, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			GitHubURLs: config.GitHubURLs{
+				API:    srv.URL + "/",
+				Upload: srv.URL + "/",
 			},
-		},
-		{
-			expectedError: ErrMultipleArchivesSameOS,
-			osarchs: []struct {
-				goos   string
-				goarch string
-				goarm  string
-			}{
-				{
-					goos:   "linux",
-					goarch: "amd64",
-				},
+			Brews: []config.Homebrew{
 				{
-					goos:   "linux",
-					goarch: "amd64",
+					Name:    "foo",
+					Goamd64: "v1",
+					Repository: config.RepoRef{
+						Owner:    "{{ .ProjectName }}",
+						Name:     "bar",
+						TokenEnv: "HOMEBREW_TAP_TOKEN",
+					},
 				},
 			},
 		},
-		{
-			expectedError: ErrMultipleArchivesSameOS,
-			osarchs: []struct {
-				goos   string
-				goarch string
-				goarm  string
-			}{
-				{
-					goos:   "linux",
-					goarch: "arm64",
-				},
+		testctx.GitHubTokenType,
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+		testctx.WithEnv(map[string]string{"HOMEBREW_TAP_TOKEN": "tap-token"}),
+	)
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:    "bin.tar.gz",
+		Path:    path,
+		Goos:    "darwin",
+		Goarch:  "amd64",
+		Goamd64: "v1",
+		Type:    artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	cli := client.NewMock()
+	require.NoError(t, runAll(ctx, cli))
+	// the repository token is used instead of the mock client, so the
+	// formula is published through the test server.
+	require.Error(t, publishAll(ctx, cli))
+	require.False(t, cli.CreatedFile)
+	require.Equal(t, "Bearer tap-token", auth.Load())
+}
+
+func TestRunPipeVersionedName(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
 				{
-					goos:   "linux",
-					goarch: "arm64",
+					Name:          "foo",
+					VersionedName: true,
+					Goamd64:       "v1",
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
 				},
 			},
 		},
-		{
-			expectedError: ErrMultipleArchivesSameOS,
-			osarchs: []struct {
-				goos   string
-				goarch string
-				goarm  string
-			}{
-				{
-					goos:   "linux",
-					goarch: "arm",
-					goarm:  "6",
-				},
+		testctx.WithVersion("2.1.0"),
+		testctx.WithCurrentTag("v2.1.0"),
+		testctx.WithSemver(2, 1, 0, ""),
+	)
+	path := filepath.Join(folder, "foo.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:    "foo.tar.gz",
+		Path:    path,
+		Goos:    "darwin",
+		Goarch:  "amd64",
+		Goamd64: "v1",
+		Type:    artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	formulae := ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List()
+	require.Len(t, formulae, 1)
+	require.Equal(t, "foo@2.rb", formulae[0].Name)
+	require.Equal(t, "FooAT2", artifact.ExtraOr(*formulae[0], ExtraFormulaClassName, ""))
+	bts, err := os.ReadFile(filepath.Join(folder, "homebrew", "foo@2.rb"))
+	require.NoError(t, err)
+	require.Contains(t, string(bts), "class FooAT2 < Formula")
+}
+
+func TestRunPipeURLOptions(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
 				{
-					goos:   "linux",
-					goarch: "arm",
-					goarm:  "6",
+					Name:     "foo",
+					Goamd64:  "v1",
+					URLUsing: "{{ .Env.USING }}",
+					URLHeaders: []string{
+						"Authorization: bearer #{ENV.fetch(\"HOMEBREW_GITHUB_API_TOKEN\")}",
+						"Accept: {{ .Env.ACCEPT }}",
+					},
+					Repository: config.RepoRef{
+						Owner: "foo",
+						Name:  "bar",
+					},
 				},
 			},
 		},
-		{
-			expectedError: ErrMultipleArchivesSameOS,
-			osarchs: []struct {
-				goos   string
-				goarch string
-				goarm  string
-			}{
-				{
-					goos:   "linux",
-					goarch: "arm",
-					goarm:  "5",
-				},
-				{
-					goos:   "linux",
-					goarch: "arm",
-					goarm:  "6",
-				},
-				{
-					goos:   "linux",
-					goarch: "arm",
-					goarm:  "7",
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+		testctx.WithEnv(map[string]string{
+			"USING":  "homebrew_curl",
+			"ACCEPT": "application/octet-stream",
+		}),
+	)
+	for _, target := range [][2]string{{"darwin", "amd64"}, {"darwin", "arm64"}, {"linux", "amd64"}} {
+		name := "foo_" + target[0] + "_" + target[1] + ".tar.gz"
+		path := filepath.Join(folder, name)
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:    name,
+			Path:    path,
+			Goos:    target[0],
+			Goarch:  target[1],
+			Goamd64: "v1",
+			Type:    artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		})
+	}
+
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	bts, err := os.ReadFile(filepath.Join(folder, "homebrew", "foo.rb"))
+	require.NoError(t, err)
+	options := `, using: :homebrew_curl, headers: ["Authorization: bearer #{ENV.fetch("HOMEBREW_GITHUB_API_TOKEN")}", "Accept: application/octet-stream"]`
+	for _, name := range []string{"foo_darwin_amd64.tar.gz", "foo_darwin_arm64.tar.gz", "foo_linux_amd64.tar.gz"} {
+		require.Contains(t, string(bts), `url "https://dummyhost/download/v1.2.1/`+name+`"`+options+"\n")
+	}
+
+	t.Run("unset", func(t *testing.T) {
+		formulae, err := doBuildFormula(ctx, defaultTemplateData)
+		require.NoError(t, err)
+		require.NotContains(t, formulae, "using:")
+		require.NotContains(t, formulae, "headers:")
+	})
+}
+
+func TestRunPipePublishRetries(t *testing.T) {
+	setup := func(t *testing.T, retries int) *context.Context {
+		t.Helper()
+		folder := t.TempDir()
+		ctx := testctx.NewWithCfg(
+			config.Project{
+				Dist:        folder,
+				ProjectName: "foo",
+				Brews: []config.Homebrew{
+					{
+						Name:              "foo",
+						PublishRetries:    retries,
+						PublishRetryDelay: "1ms",
+						Repository: config.RepoRef{
+							Owner: "foo",
+							Name:  "bar",
+						},
+					},
 				},
 			},
-		},
+			testctx.WithVersion("1.2.1"),
+			testctx.WithCurrentTag("v1.2.1"),
+		)
+		path := filepath.Join(folder, "bin.tar.gz")
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		ctx.Artifacts.Add(&artifact.Artifact{
+			Name:   "bin.tar.gz",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		})
+		return ctx
 	}
+	transient := client.RetriableError{Err: errors.New("502 bad gateway")}
 
-	for _, test := range tests {
-		for idx, ttt := range test.osarchs {
-			ctx.Artifacts.Add(&artifact.Artifact{
-				Name:   fmt.Sprintf("bin%d", idx),
-				Path:   f.Name(),
-				Goos:   ttt.goos,
-				Goarch: ttt.goarch,
-				Type:   artifact.UploadableArchive,
-				Extra: map[string]interface{}{
-					artifact.ExtraID:     fmt.Sprintf("foo%d", idx),
-					artifact.ExtraFormat: "tar.gz",
-				},
-			})
+	t.Run("succeeds after transient errors", func(t *testing.T) {
+		ctx := setup(t, 2)
+		cli := client.NewMock()
+		cli.CreateFileErrs = []error{transient, transient}
+		require.NoError(t, runAll(ctx, cli))
+		require.NoError(t, publishAll(ctx, cli))
+		require.True(t, cli.CreatedFile)
+		require.Empty(t, cli.CreateFileErrs)
+	})
+
+	t.Run("runs out of retries", func(t *testing.T) {
+		ctx := setup(t, 1)
+		cli := client.NewMock()
+		cli.CreateFileErrs = []error{transient, transient}
+		require.NoError(t, runAll(ctx, cli))
+		require.ErrorIs(t, publishAll(ctx, cli), transient)
+		require.False(t, cli.CreatedFile)
+	})
+
+	t.Run("permanent error", func(t *testing.T) {
+		ctx := setup(t, 2)
+		cli := client.NewMock()
+		cli.CreateFileErrs = []error{errors.New("401 bad credentials"), transient}
+		require.NoError(t, runAll(ctx, cli))
+		require.EqualError(t, publishAll(ctx, cli), "401 bad credentials")
+		require.Len(t, cli.CreateFileErrs, 1)
+	})
+}
+
+func TestWithRetries(t *testing.T) {
+	t.Run("skip", func(t *testing.T) {
+		var tries int
+		err := withRetries(config.Homebrew{
+			PublishRetries:    3,
+			PublishRetryDelay: "1ms",
+		}, func() error {
+			tries++
+			return pipe.Skip("nope")
+		})
+		require.True(t, pipe.IsSkip(err))
+		require.Equal(t, 1, tries)
+	})
+
+	t.Run("no retries", func(t *testing.T) {
+		var tries int
+		err := withRetries(config.Homebrew{}, func() error {
+			tries++
+			return client.RetriableError{Err: errors.New("fail")}
+		})
+		require.EqualError(t, err, "fail")
+		require.Equal(t, 1, tries)
+	})
+}
+
+func TestWaitPublishDelay(t *testing.T) {
+	formula := func(brew config.Homebrew) *artifact.Artifact {
+		return &artifact.Artifact{
+			Extra: map[string]interface{}{
+				brewConfigExtra: brew,
+			},
 		}
-		client := client.NewMock()
-		require.Equal(t, test.expectedError, runAll(ctx, client))
-		require.False(t, client.CreatedFile)
-		// clean the artifacts for the next run
-		ctx.Artifacts = artifact.New()
 	}
+
+	t.Run("not set", func(t *testing.T) {
+		start := time.Now()
+		require.NoError(t, waitPublishDelay(formula(config.Homebrew{})))
+		require.Less(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("git", func(t *testing.T) {
+		start := time.Now()
+		require.NoError(t, waitPublishDelay(formula(config.Homebrew{
+			PublishDelay: "1h",
+			Repository: config.RepoRef{
+				Git: config.GitRepoRef{
+					URL: "git@example.com:foo/bar.git",
+				},
+			},
+		})))
+		require.Less(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		start := time.Now()
+		require.NoError(t, waitPublishDelay(formula(config.Homebrew{
+			PublishDelay: "50ms",
+		})))
+		require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
 }
 
-func TestRunPipeBinaryRelease(t *testing.T) {
+func TestPublishConcurrency(t *testing.T) {
+	require.Equal(t, 1, publishConcurrency(nil))
+	require.Equal(t, 1, publishConcurrency([]config.Homebrew{{}, {}}))
+	require.Equal(t, 3, publishConcurrency([]config.Homebrew{{}, {PublishConcurrency: 3}}))
+	require.Equal(t, 2, publishConcurrency([]config.Homebrew{
+		{PublishConcurrency: 3},
+		{PublishConcurrency: 2},
+		{},
+	}))
+}
+
+func TestRunPipeDryRun(t *testing.T) {
 	folder := t.TempDir()
 	ctx := testctx.NewWithCfg(
 		config.Project{
@@ -977,43 +4814,222 @@ func TestRunPipeBinaryRelease(t *testing.T) {
 			Brews: []config.Homebrew{
 				{
 					Name:        "foo",
-					Homepage:    "https://goreleaser.com",
-					Description: "Fake desc",
+					Folder:      "Formula",
+					Description: "A new description",
 					Repository: config.RepoRef{
 						Owner: "foo",
 						Name:  "bar",
 					},
-					ExtraInstall: `man1.install "./man/foo.1.gz"`,
 				},
 			},
 		},
 		testctx.WithVersion("1.2.1"),
 		testctx.WithCurrentTag("v1.2.1"),
 	)
-	path := filepath.Join(folder, "dist/foo_darwin_all/foo")
+	ctx.BrewDryRun = true
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
 	ctx.Artifacts.Add(&artifact.Artifact{
-		Name:   "foo_macos",
+		Name:   "bin.tar.gz",
 		Path:   path,
 		Goos:   "darwin",
-		Goarch: "all",
-		Type:   artifact.UploadableBinary,
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
 		Extra: map[string]interface{}{
-			artifact.ExtraID:     "foo",
-			artifact.ExtraFormat: "binary",
-			artifact.ExtraBinary: "foo",
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
 		},
 	})
 
-	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
-	f, err := os.Create(path)
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
+	var out bytes.Buffer
+	diffOutput = &out
+	t.Cleanup(func() { diffOutput = os.Stdout })
 
-	client := client.NewMock()
-	require.NoError(t, runAll(ctx, client))
-	require.NoError(t, publishAll(ctx, client))
-	require.True(t, client.CreatedFile)
-	golden.RequireEqualRb(t, []byte(client.Content))
+	cli := client.NewMock()
+	cli.Files = map[string]string{
+		"Formula/foo.rb": "# typed: false\nclass Foo < Formula\n  desc \"An old description\"\nend\n",
+	}
+	require.NoError(t, Pipe{}.Default(ctx))
+	require.NoError(t, runAll(ctx, cli))
+
+	diff := out.String()
+	require.Contains(t, diff, "--- a/Formula/foo.rb\n+++ b/Formula/foo.rb\n")
+	require.Contains(t, diff, "-  desc \"An old description\"\n")
+	require.Contains(t, diff, "+  desc \"A new description\"\n")
+
+	require.NoFileExists(t, filepath.Join(folder, "homebrew", "Formula", "foo.rb"))
+	require.Empty(t, ctx.Artifacts.Filter(artifact.ByType(artifact.BrewTap)).List())
+	require.False(t, cli.CreatedFile)
+}
+
+// overlapWriter records whether two writes to it ever overlapped.
+type overlapWriter struct {
+	writing    atomic.Int32
+	overlapped atomic.Bool
+	out        bytes.Buffer
+}
+
+func (w *overlapWriter) Write(p []byte) (int, error) {
+	if w.writing.Add(1) > 1 {
+		w.overlapped.Store(true)
+	}
+	defer w.writing.Add(-1)
+	time.Sleep(10 * time.Millisecond)
+	if w.overlapped.Load() {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+func TestRunPipeDiffConcurrent(t *testing.T) {
+	folder := t.TempDir()
+	repo := config.RepoRef{Owner: "foo", Name: "bar"}
+	var brews []config.Homebrew
+	for i := 0; i < 4; i++ {
+		brews = append(brews, config.Homebrew{
+			Name:       fmt.Sprintf("foo%d", i),
+			DryRun:     true,
+			Repository: repo,
+		})
+	}
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews:       brews,
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	ctx.Parallelism = 4
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+	require.NoError(t, Pipe{}.Default(ctx))
+
+	out := &overlapWriter{}
+	diffOutput = out
+	t.Cleanup(func() { diffOutput = os.Stdout })
+
+	require.NoError(t, runAll(ctx, client.NewMock()))
+	require.False(t, out.overlapped.Load(), "diffs were written concurrently")
+	for i := 0; i < 4; i++ {
+		require.Contains(t, out.out.String(), fmt.Sprintf("+++ b/foo%d.rb\n", i))
+	}
+}
+
+func TestRunPipeDryRunErrors(t *testing.T) {
+	folder := t.TempDir()
+	repo := config.RepoRef{Owner: "foo", Name: "bar"}
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{Name: "foo", Repository: repo, IDs: []string{"nope"}},
+				{Name: "bar", Repository: config.RepoRef{Owner: "{{ .Env.NOPE }}", Name: "bar"}},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+	)
+	ctx.BrewDryRun = true
+	path := filepath.Join(folder, "bin.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "bin.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+	require.NoError(t, Pipe{}.Default(ctx))
+
+	err := runAll(ctx, client.NewMock())
+	require.ErrorContains(t, err, "brews[0]: no linux/macos archives found")
+	require.ErrorContains(t, err, "brews[1]: template: tmpl:1:7")
+	require.NoDirExists(t, filepath.Join(folder, "homebrew"))
+}
+
+func TestRunPipeDiff(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"new formula": nil,
+		"changed formula": {
+			"Formula/foo.rb": "# typed: false\nclass Foo < Formula\n  version \"1.2.0\"\nend\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			folder := t.TempDir()
+			ctx := testctx.NewWithCfg(
+				config.Project{
+					Dist:        folder,
+					ProjectName: "foo",
+					Brews: []config.Homebrew{
+						{
+							Name:   "foo",
+							Folder: "Formula",
+							DryRun: true,
+							Repository: config.RepoRef{
+								Owner: "foo",
+								Name:  "bar",
+							},
+						},
+					},
+				},
+				testctx.WithVersion("1.2.1"),
+				testctx.WithCurrentTag("v1.2.1"),
+			)
+			path := filepath.Join(folder, "bin.tar.gz")
+			require.NoError(t, os.WriteFile(path, nil, 0o644))
+			ctx.Artifacts.Add(&artifact.Artifact{
+				Name:   "bin.tar.gz",
+				Path:   path,
+				Goos:   "darwin",
+				Goarch: "arm64",
+				Type:   artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraFormat:   "tar.gz",
+					artifact.ExtraBinaries: []string{"foo"},
+				},
+			})
+
+			var out bytes.Buffer
+			diffOutput = &out
+			t.Cleanup(func() { diffOutput = os.Stdout })
+
+			cli := client.NewMock()
+			cli.Files = files
+			require.NoError(t, runAll(ctx, cli))
+
+			diff := out.String()
+			require.Contains(t, diff, "--- a/Formula/foo.rb\n+++ b/Formula/foo.rb\n")
+			require.Contains(t, diff, "+  version \"1.2.1\"\n")
+			if files == nil {
+				require.Contains(t, diff, "@@ -0,0 +1,")
+				require.NotContains(t, diff, "\n-")
+			} else {
+				require.Contains(t, diff, "-  version \"1.2.0\"\n")
+				require.Contains(t, diff, " class Foo < Formula\n")
+			}
+
+			testlib.AssertSkipped(t, publishAll(ctx, cli))
+			require.False(t, cli.CreatedFile)
+		})
+	}
 }
 
 func TestRunPipePullRequest(t *testing.T) {
@@ -1033,7 +5049,10 @@ func TestRunPipePullRequest(t *testing.T) {
 						Name:   "bar",
 						Branch: "update-{{.Version}}",
 						PullRequest: config.PullRequest{
-							Enabled: true,
+							Enabled:   true,
+							Milestone: "v{{ .Version }}",
+							Reviewers: []string{"caarlos0"},
+							Labels:    []string{"homebrew"},
 						},
 					},
 				},
@@ -1041,6 +5060,7 @@ func TestRunPipePullRequest(t *testing.T) {
 		},
 		testctx.WithVersion("1.2.1"),
 		testctx.WithCurrentTag("v1.2.1"),
+		testctx.WithToken("token"),
 	)
 	path := filepath.Join(folder, "dist/foo_darwin_all/foo")
 	ctx.Artifacts.Add(&artifact.Artifact{
@@ -1066,9 +5086,148 @@ func TestRunPipePullRequest(t *testing.T) {
 	require.NoError(t, publishAll(ctx, client))
 	require.True(t, client.CreatedFile)
 	require.True(t, client.OpenedPullRequest)
+	require.Equal(t, "v1.2.1", client.PullRequestOptions.Milestone)
+	require.Equal(t, []string{"caarlos0"}, client.PullRequestOptions.Reviewers)
+	require.Equal(t, []string{"homebrew"}, client.PullRequestOptions.Labels)
 	golden.RequireEqualRb(t, []byte(client.Content))
 }
 
+func TestRunPipeCommitBody(t *testing.T) {
+	folder := t.TempDir()
+	ctx := testctx.NewWithCfg(
+		config.Project{
+			Dist:        folder,
+			ProjectName: "foo",
+			Brews: []config.Homebrew{
+				{
+					Name:                  "foo",
+					Goamd64:               "v1",
+					CommitMessageTemplate: "chore(brew): update {{ .ProjectName }} to {{ .Tag }}",
+					CommitBodyTemplate:    "{{ .ReleaseNotes }}\n",
+					Repository: config.RepoRef{
+						Owner:  "foo",
+						Name:   "bar",
+						Branch: "update-{{ .Version }}",
+						PullRequest: config.PullRequest{
+							Enabled: true,
+						},
+					},
+				},
+			},
+		},
+		testctx.WithVersion("1.2.1"),
+		testctx.WithCurrentTag("v1.2.1"),
+		testctx.WithToken("token"),
+	)
+	ctx.ReleaseNotes = "## Changelog\n* fixed things"
+	path := filepath.Join(folder, "foo.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:    "foo.tar.gz",
+		Path:    path,
+		Goos:    "darwin",
+		Goarch:  "amd64",
+		Goamd64: "v1",
+		Type:    artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	cli := client.NewMock()
+	require.NoError(t, runAll(ctx, cli))
+	require.NoError(t, publishAll(ctx, cli))
+	require.Equal(t, []string{"chore(brew): update foo to v1.2.1\n\n## Changelog\n* fixed things"}, cli.Messages)
+	require.Equal(t, "chore(brew): update foo to v1.2.1", cli.PullRequestTitle)
+
+	t.Run("no body", func(t *testing.T) {
+		msg, err := commitMessageFor(ctx, config.Homebrew{CommitMessageTemplate: "update {{ .Tag }}"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, "update v1.2.1", msg)
+	})
+
+	t.Run("invalid body template", func(t *testing.T) {
+		_, err := commitMessageFor(ctx, config.Homebrew{CommitBodyTemplate: "{{ .Nope }"}, nil)
+		testlib.RequireTemplateError(t, err)
+	})
+}
+
+func TestRunPipePullRequestOnlyStable(t *testing.T) {
+	for name, tt := range map[string]struct {
+		prerelease string
+		expectPR   bool
+		expectRepo client.Repo
+	}{
+		"stable": {
+			expectPR:   true,
+			expectRepo: client.Repo{Owner: "foo", Name: "bar", Branch: "formulas"},
+		},
+		"prerelease": {
+			prerelease: "rc1",
+			expectPR:   false,
+			expectRepo: client.Repo{Owner: "upstream", Name: "bar", Branch: "main"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			folder := t.TempDir()
+			ctx := testctx.NewWithCfg(
+				config.Project{
+					Dist:        folder,
+					ProjectName: "foo",
+					Brews: []config.Homebrew{
+						{
+							Name:                  "foo",
+							PullRequestOnlyStable: true,
+							Repository: config.RepoRef{
+								Owner:  "foo",
+								Name:   "bar",
+								Branch: "formulas",
+								PullRequest: config.PullRequest{
+									Enabled: true,
+									Base: config.PullRequestBase{
+										Owner:  "upstream",
+										Branch: "main",
+									},
+								},
+							},
+						},
+					},
+				},
+				testctx.WithVersion("1.2.1"),
+				testctx.WithCurrentTag("v1.2.1"),
+				testctx.WithSemver(1, 2, 1, tt.prerelease),
+				testctx.WithToken("token"),
+			)
+			path := filepath.Join(folder, "dist/foo_darwin_all/foo")
+			ctx.Artifacts.Add(&artifact.Artifact{
+				Name:   "foo_macos",
+				Path:   path,
+				Goos:   "darwin",
+				Goarch: "all",
+				Type:   artifact.UploadableBinary,
+				Extra: map[string]interface{}{
+					artifact.ExtraID:     "foo",
+					artifact.ExtraFormat: "binary",
+					artifact.ExtraBinary: "foo",
+				},
+			})
+
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			f, err := os.Create(path)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			client := client.NewMock()
+			require.NoError(t, runAll(ctx, client))
+			require.NoError(t, publishAll(ctx, client))
+			require.True(t, client.CreatedFile)
+			require.Equal(t, tt.expectRepo, client.CreatedFileRepo)
+			require.Equal(t, tt.expectPR, client.OpenedPullRequest)
+		})
+	}
+}
+
 func TestRunPipeNoUpload(t *testing.T) {
 	folder := t.TempDir()
 	ctx := testctx.NewWithCfg(config.Project{
@@ -1199,10 +5358,233 @@ func TestDefault(t *testing.T) {
 	require.NotEmpty(t, ctx.Config.Brews[0].CommitAuthor.Name)
 	require.NotEmpty(t, ctx.Config.Brews[0].CommitAuthor.Email)
 	require.NotEmpty(t, ctx.Config.Brews[0].CommitMessageTemplate)
+	require.Equal(t, "double", ctx.Config.Brews[0].QuoteStyle)
+	require.Equal(t, "v8.0", ctx.Config.Brews[0].Goarm64)
+	require.Equal(t, "sha256", ctx.Config.Brews[0].ChecksumAlgorithm)
 	require.Equal(t, repo, ctx.Config.Brews[0].Repository)
 	require.True(t, ctx.Deprecated)
 }
 
+func TestDefaultServiceString(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		ProjectName: "myproject",
+		Brews: []config.Homebrew{
+			{Service: config.HomebrewService{Raw: "run [opt_bin/\"foo\"]"}},
+		},
+	})
+	require.NoError(t, Pipe{}.Default(ctx))
+	require.True(t, ctx.Deprecated)
+}
+
+func TestDefaultPlistAndService(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		ProjectName: "myproject",
+		Brews: []config.Homebrew{
+			{
+				Plist:   "<xml>... whatever</xml>",
+				Service: config.HomebrewService{Run: config.StringArray{"foo"}},
+			},
+		},
+	})
+	require.EqualError(t, Pipe{}.Default(ctx), "brews[0]: plist: can't be used together with service, plist is deprecated and should be migrated to service")
+}
+
+func TestServiceFor(t *testing.T) {
+	for name, tt := range map[string]struct {
+		cfg      config.Homebrew
+		expected []string
+	}{
+		"empty": {
+			expected: []string{},
+		},
+		"string": {
+			cfg: config.Homebrew{
+				Service: config.HomebrewService{Raw: "run [opt_bin/\"foo\"]\nkeep_alive true\n"},
+			},
+			expected: []string{`run [opt_bin/"foo"]`, "keep_alive true"},
+		},
+		"structured": {
+			cfg: config.Homebrew{
+				Service: config.HomebrewService{
+					Run:        []string{"foo", "serve", "--port=8080"},
+					RunType:    "immediate",
+					KeepAlive:  true,
+					WorkingDir: "/var/lib/foo",
+					EnvironmentVariables: map[string]string{
+						"PATH":     "/usr/bin:/bin",
+						"FOO_HOME": "/var/lib/foo",
+					},
+				},
+			},
+			expected: []string{
+				`run [opt_bin/"foo", "serve", "--port=8080"]`,
+				"run_type :immediate",
+				"keep_alive true",
+				`working_dir "/var/lib/foo"`,
+				`environment_variables FOO_HOME: "/var/lib/foo", PATH: "/usr/bin:/bin"`,
+			},
+		},
+		"absolute path and single quotes": {
+			cfg: config.Homebrew{
+				QuoteStyle: "single",
+				Service: config.HomebrewService{
+					Run: []string{"/usr/local/bin/foo", "serve"},
+				},
+			},
+			expected: []string{`run ['/usr/local/bin/foo', 'serve']`},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tt.expected, serviceFor(tt.cfg))
+		})
+	}
+}
+
+func TestDefaultInvalidQuoteStyle(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		Brews: []config.Homebrew{{QuoteStyle: "backtick"}},
+	})
+	require.EqualError(t, Pipe{}.Default(ctx), `brews[0]: quote_style: invalid value "backtick", valid options are [double single]`)
+}
+
+func TestDefaultInvalidExcludeNameRegex(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		Brews: []config.Homebrew{{ExcludeNameRegex: "*debug"}},
+	})
+	require.ErrorContains(t, Pipe{}.Default(ctx), `brews[0]: exclude_name_regex: invalid regular expression "*debug"`)
+}
+
+func TestDefaultLicense(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		ctx := testctx.NewWithCfg(config.Project{
+			Brews: []config.Homebrew{{License: "mit or (apache-2.0 with llvm-exception)", StrictLicense: true}},
+		})
+		require.NoError(t, Pipe{}.Default(ctx))
+		require.Equal(t, "MIT OR (Apache-2.0 WITH LLVM-exception)", ctx.Config.Brews[0].License)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		ctx := testctx.NewWithCfg(config.Project{
+			Brews: []config.Homebrew{{License: "MTI"}},
+		})
+		require.NoError(t, Pipe{}.Default(ctx))
+		require.Equal(t, "MTI", ctx.Config.Brews[0].License)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		ctx := testctx.NewWithCfg(config.Project{
+			Brews: []config.Homebrew{{License: "MTI", StrictLicense: true}},
+		})
+		require.EqualError(t, Pipe{}.Default(ctx), `brews[0]: license: invalid license expression "MTI": unknown license identifier "MTI"`)
+	})
+}
+
+func TestNormalizeLicense(t *testing.T) {
+	for license, expected := range map[string]string{
+		"MIT":                                    "MIT",
+		"apache-2.0":                             "Apache-2.0",
+		"GPL-2.0-or-later+":                      "GPL-2.0-or-later+",
+		"MIT OR Apache-2.0":                      "MIT OR Apache-2.0",
+		"(MIT and BSD-3-Clause) or GPL-3.0-only": "(MIT AND BSD-3-Clause) OR GPL-3.0-only",
+		"GPL-2.0-only WITH Classpath-exception-2.0": "GPL-2.0-only WITH Classpath-exception-2.0",
+		"LicenseRef-Proprietary":                    "LicenseRef-Proprietary",
+	} {
+		t.Run(license, func(t *testing.T) {
+			got, err := normalizeLicense(license)
+			require.NoError(t, err)
+			require.Equal(t, expected, got)
+		})
+	}
+
+	for license, expected := range map[string]string{
+		"":                        `invalid license expression "": empty`,
+		"MTI":                     `invalid license expression "MTI": unknown license identifier "MTI"`,
+		"MIT OR":                  `invalid license expression "MIT OR": incomplete`,
+		"MIT Apache-2.0":          `invalid license expression "MIT Apache-2.0": unexpected "Apache-2.0"`,
+		"(MIT OR Apache-2.0":      `invalid license expression "(MIT OR Apache-2.0": incomplete`,
+		"MIT)":                    `invalid license expression "MIT)": unexpected ")"`,
+		"MIT WITH":                `invalid license expression "MIT WITH": unexpected "WITH"`,
+		"MIT WITH Nope-exception": `invalid license expression "MIT WITH Nope-exception": unknown license exception "Nope-exception"`,
+	} {
+		t.Run(license, func(t *testing.T) {
+			_, err := normalizeLicense(license)
+			require.EqualError(t, err, expected)
+		})
+	}
+}
+
+func TestLicenseDSL(t *testing.T) {
+	for license, expected := range map[string]string{
+		"MIT":                                     `"MIT"`,
+		"MIT OR Apache-2.0":                       `any_of: ["MIT", "Apache-2.0"]`,
+		"MIT AND Zlib AND 0BSD":                   `all_of: ["MIT", "Zlib", "0BSD"]`,
+		"MIT OR 0BSD AND Zlib":                    `any_of: ["MIT", { all_of: ["0BSD", "Zlib"] }]`,
+		"(MIT OR 0BSD) AND Zlib":                  `all_of: [{ any_of: ["MIT", "0BSD"] }, "Zlib"]`,
+		"Apache-2.0 WITH LLVM-exception":          `"Apache-2.0" => { with: "LLVM-exception" }`,
+		"MIT OR (Apache-2.0 WITH LLVM-exception)": `any_of: ["MIT", { "Apache-2.0" => { with: "LLVM-exception" } }]`,
+		"MIT OR": `"MIT OR"`,
+		"(MIT":   `"(MIT"`,
+	} {
+		t.Run(license, func(t *testing.T) {
+			require.Equal(t, expected, licenseDSL(quoteDouble, license))
+		})
+	}
+
+	t.Run("single quotes", func(t *testing.T) {
+		require.Equal(t, `any_of: ['MIT', 'Apache-2.0']`, licenseDSL(quoteSingle, "MIT OR Apache-2.0"))
+	})
+
+	t.Run("formula", func(t *testing.T) {
+		data := defaultTemplateData
+		data.License = "MIT OR Apache-2.0"
+		formulae, err := doBuildFormula(testctx.New(), data)
+		require.NoError(t, err)
+		require.Contains(t, formulae, `  license any_of: ["MIT", "Apache-2.0"]`+"\n")
+	})
+}
+
+func TestFormulaVersion(t *testing.T) {
+	disabled := false
+	enabled := true
+	for _, tt := range []struct {
+		version string
+		strip   *bool
+		expect  string
+	}{
+		{"1.2.3", nil, "1.2.3"},
+		{"1.2.3+abc", nil, "1.2.3"},
+		{"1.2.3-rc.1+abc", nil, "1.2.3-rc.1"},
+		{"1.2.3-rc.1+abc", &enabled, "1.2.3-rc.1"},
+		{"1.2.3-rc.1+abc", &disabled, "1.2.3-rc.1+abc"},
+		{"1.2.3+abc.def", &disabled, "1.2.3+abc.def"},
+	} {
+		t.Run(tt.version, func(t *testing.T) {
+			require.Equal(t, tt.expect, formulaVersion(tt.version, tt.strip))
+		})
+	}
+
+	t.Run("formula", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bin.tar.gz")
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		formula, err := buildFormula(testctx.New(testctx.WithVersion("1.2.3-rc.1+abc")), config.Homebrew{
+			Name:        "foo",
+			URLTemplate: "https://example.com/{{ .ArtifactName }}",
+		}, client.NewMock(), []*artifact.Artifact{{
+			Name:   "bin.tar.gz",
+			Path:   path,
+			Goos:   "darwin",
+			Goarch: "arm64",
+			Type:   artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraFormat:   "tar.gz",
+				artifact.ExtraBinaries: []string{"foo"},
+			},
+		}})
+		require.NoError(t, err)
+		require.Contains(t, formula, `version "1.2.3-rc.1"`+"\n")
+	})
+}
+
 func TestGHFolder(t *testing.T) {
 	require.Equal(t, "bar.rb", buildFormulaPath("", "bar.rb"))
 	require.Equal(t, "fooo/bar.rb", buildFormulaPath("fooo", "bar.rb"))
@@ -1264,6 +5646,97 @@ func TestInstalls(t *testing.T) {
 		}, install)
 	})
 
+	t.Run("with share", func(t *testing.T) {
+		install, err := installs(
+			testctx.New(),
+			config.Homebrew{
+				ExtraInstall: `man1.install "foo.1"`,
+				ShareInstall: []config.HomebrewShareInstall{
+					{Src: "data"},
+					{Src: "icons", Dst: "foo"},
+				},
+			},
+			&artifact.Artifact{
+				Type: artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraBinaries: []string{"foo"},
+				},
+			},
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`bin.install "foo"`,
+			`pkgshare.install "data"`,
+			`(share/"foo").install "icons"`,
+			`man1.install "foo.1"`,
+		}, install)
+	})
+
+	t.Run("install and extra install", func(t *testing.T) {
+		install, err := installs(
+			testctx.New(),
+			config.Homebrew{
+				Install:      "bin.install \"foo\"\nbin.install \"bar\"",
+				ExtraInstall: `man1.install "foo.1"`,
+				ShareInstall: []config.HomebrewShareInstall{{Src: "data"}},
+				Chmod:        []config.HomebrewChmod{{Path: "foo"}},
+			},
+			&artifact.Artifact{
+				Type: artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraBinaries: []string{"baz"},
+				},
+			},
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`bin.install "foo"`,
+			`bin.install "bar"`,
+			`pkgshare.install "data"`,
+			`man1.install "foo.1"`,
+			`chmod 0755, bin/"foo"`,
+		}, install)
+	})
+
+	t.Run("with chmod", func(t *testing.T) {
+		install, err := installs(
+			testctx.New(),
+			config.Homebrew{
+				Install: `bin.install "foo"`,
+				Chmod: []config.HomebrewChmod{
+					{Path: "foo"},
+					{Path: "bar", Mode: "755"},
+					{Path: "baz", Mode: "0700"},
+				},
+			},
+			&artifact.Artifact{},
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`bin.install "foo"`,
+			`chmod 0755, bin/"foo"`,
+			`chmod 0755, bin/"bar"`,
+			`chmod 0700, bin/"baz"`,
+		}, install)
+	})
+
+	t.Run("from archives with excludes", func(t *testing.T) {
+		install, err := installs(
+			testctx.New(),
+			config.Homebrew{InstallExclude: []string{"*.txt", "LICENSE*"}},
+			&artifact.Artifact{
+				Type: artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraBinaries: []string{"foo", "bar"},
+				},
+			},
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`bin.install Dir["*"] - Dir["*.txt", "LICENSE*"]`,
+		}, install)
+	})
+
 	t.Run("from binary", func(t *testing.T) {
 		install, err := installs(
 			testctx.New(),
@@ -1282,6 +5755,134 @@ func TestInstalls(t *testing.T) {
 		}, install)
 	})
 
+	t.Run("from archives single quotes", func(t *testing.T) {
+		install, err := installs(
+			testctx.New(),
+			config.Homebrew{QuoteStyle: quoteSingle},
+			&artifact.Artifact{
+				Type: artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraBinaries: []string{"foo"},
+				},
+			},
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`bin.install 'foo'`,
+		}, install)
+	})
+
+	t.Run("nothing guessed", func(t *testing.T) {
+		install, err := installs(
+			testctx.New(),
+			config.Homebrew{ExtraInstall: `man1.install "foo.1"`},
+			&artifact.Artifact{
+				Name: "foo.tar.gz",
+				Type: artifact.UploadableArchive,
+			},
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{`man1.install "foo.1"`}, install)
+	})
+
+	t.Run("completions from executable", func(t *testing.T) {
+		install, err := installs(
+			testctx.New(),
+			config.Homebrew{
+				ExtraInstall: `man1.install "foo.1"`,
+				Chmod:        []config.HomebrewChmod{{Path: "foo"}},
+				CompletionsFromExecutable: config.HomebrewCompletions{
+					Binary: "foo",
+					Args:   "completion",
+					Shells: []string{"bash", "zsh"},
+				},
+			},
+			&artifact.Artifact{
+				Type: artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraBinaries: []string{"foo"},
+				},
+			},
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`bin.install "foo"`,
+			`man1.install "foo.1"`,
+			`chmod 0755, bin/"foo"`,
+			`generate_completions_from_executable(bin/"foo", "completion", shells: [:bash, :zsh])`,
+		}, install)
+	})
+
+	t.Run("completions from executable with install", func(t *testing.T) {
+		install, err := installs(
+			testctx.New(),
+			config.Homebrew{
+				Install:    `bin.install "foo"`,
+				QuoteStyle: quoteSingle,
+				CompletionsFromExecutable: config.HomebrewCompletions{
+					Binary: "foo",
+					Args:   "gen completions",
+				},
+			},
+			&artifact.Artifact{Type: artifact.UploadableArchive},
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`bin.install "foo"`,
+			`generate_completions_from_executable(bin/'foo', 'gen', 'completions')`,
+		}, install)
+	})
+
+	t.Run("from nested archive", func(t *testing.T) {
+		art := &artifact.Artifact{
+			Type: artifact.UploadableArchive,
+			Extra: map[string]interface{}{
+				artifact.ExtraWrappedIn: "tool-1.2.3",
+				artifact.ExtraBinaries:  []string{"tool-1.2.3/bin/tool", "tool-1.2.3/bin/toolctl"},
+			},
+		}
+		for n, expect := range map[int][]string{
+			0: {`bin.install "tool-1.2.3/bin/tool" => "tool"`, `bin.install "tool-1.2.3/bin/toolctl" => "toolctl"`},
+			1: {`bin.install "bin/tool" => "tool"`, `bin.install "bin/toolctl" => "toolctl"`},
+			2: {`bin.install "tool"`, `bin.install "toolctl"`},
+			5: {`bin.install "tool"`, `bin.install "toolctl"`},
+		} {
+			install, err := installs(testctx.New(), config.Homebrew{StripComponents: n}, art)
+			require.NoError(t, err)
+			require.Equal(t, expect, install, "strip_components: %d", n)
+		}
+	})
+
+	t.Run("from archive with nested binary", func(t *testing.T) {
+		install, err := installs(
+			testctx.New(),
+			config.Homebrew{QuoteStyle: quoteSingle},
+			&artifact.Artifact{
+				Type: artifact.UploadableArchive,
+				Extra: map[string]interface{}{
+					artifact.ExtraBinaries: []string{"dist/foo", "bar"},
+				},
+			},
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`bin.install 'bar'`,
+			`bin.install 'dist/foo' => 'foo'`,
+		}, install)
+	})
+
+	t.Run("nothing guessed strict", func(t *testing.T) {
+		_, err := installs(
+			testctx.New(),
+			config.Homebrew{StrictInstall: true},
+			&artifact.Artifact{
+				Name: "foo.tar.gz",
+				Type: artifact.UploadableArchive,
+			},
+		)
+		require.EqualError(t, err, "brew: could not guess install lines for foo.tar.gz: set brews.install or make sure the archive has binaries")
+	})
+
 	t.Run("from template", func(t *testing.T) {
 		install, err := installs(
 			testctx.New(),
@@ -1438,3 +6039,76 @@ func TestRunPipeUniversalBinaryNotReplacing(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, client.Content, string(distBts))
 }
+
+func TestReadChecksums(t *testing.T) {
+	const (
+		fooSum = "a8f3a7f2e1f3c0c1f1c6d0f1f5a4e6c7b8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3"
+		barSum = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	)
+
+	for name, content := range map[string]string{
+		"gnu":   fooSum + "  foo.tar.gz\n" + barSum + " *bar.zip\n",
+		"bsd":   "SHA256 (foo.tar.gz) = " + fooSum + "\nSHA256 (bar.zip) = " + strings.ToUpper(barSum) + "\n",
+		"mixed": "\n" + fooSum + "  foo.tar.gz\n\nSHA256 (bar.zip) = " + barSum + "\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checksums.txt")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			sums, err := readChecksums(path)
+			require.NoError(t, err)
+			require.Equal(t, map[string]string{
+				"foo.tar.gz": fooSum,
+				"bar.zip":    barSum,
+			}, sums)
+		})
+	}
+
+	t.Run("sha512", func(t *testing.T) {
+		sum := strings.Repeat(fooSum, 2)
+		path := filepath.Join(t.TempDir(), "checksums.txt")
+		require.NoError(t, os.WriteFile(path, []byte("SHA512 (foo.tar.gz) = "+sum+"\n"), 0o644))
+		sums, err := readChecksums(path)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"foo.tar.gz": sum}, sums)
+	})
+
+	t.Run("unrecognized", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checksums.txt")
+		require.NoError(t, os.WriteFile(path, []byte(fooSum+"  foo.tar.gz\nMD5 (bar.zip) = 0123\n"), 0o644))
+		_, err := readChecksums(path)
+		require.ErrorContains(t, err, "checksums.txt:2: unrecognized checksum format")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := readChecksums(filepath.Join(t.TempDir(), "checksums.txt"))
+		require.ErrorContains(t, err, "could not read checksums file")
+	})
+}
+
+func TestChecksumForChecksumsFile(t *testing.T) {
+	const sum = "a8f3a7f2e1f3c0c1f1c6d0f1f5a4e6c7b8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3"
+	folder := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(folder, "foo_1.2.1_checksums.txt"),
+		[]byte("SHA256 (foo.tar.gz) = "+sum+"\n"),
+		0o644,
+	))
+	ctx := testctx.NewWithCfg(
+		config.Project{ProjectName: "foo"},
+		testctx.WithVersion("1.2.1"),
+	)
+	cfg := config.Homebrew{
+		ChecksumsFile: filepath.Join(folder, "{{ .ProjectName }}_{{ .Version }}_checksums.txt"),
+	}
+
+	got, err := checksumFor(ctx, cfg, &artifact.Artifact{Name: "foo.tar.gz"}, "")
+	require.NoError(t, err)
+	require.Equal(t, sum, got)
+
+	_, err = checksumFor(ctx, cfg, &artifact.Artifact{Name: "bar.tar.gz"}, "")
+	require.ErrorContains(t, err, "no checksum found for bar.tar.gz")
+
+	cfg.ChecksumAlgorithm = "sha512"
+	_, err = checksumFor(ctx, cfg, &artifact.Artifact{Name: "foo.tar.gz"}, "")
+	require.ErrorContains(t, err, "checksum for foo.tar.gz is not a sha512")
+}
@@ -0,0 +1,140 @@
+package brew
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/goreleaser/goreleaser/internal/artifact"
+	"github.com/goreleaser/goreleaser/internal/tmpl"
+	"github.com/goreleaser/goreleaser/pkg/context"
+)
+
+var (
+	// gnuChecksumRe matches lines like `<sum>  <name>`, as generated by
+	// sha256sum and GoReleaser itself. The name might be prefixed with a `*`
+	// when in binary mode.
+	gnuChecksumRe = regexp.MustCompile(`^([a-fA-F0-9]{128}|[a-fA-F0-9]{64}) [ *]?(.+)$`)
+
+	// bsdChecksumRe matches lines like `SHA256 (<name>) = <sum>`, as generated
+	// by `shasum --tag` and BSD's sha256 and sha512.
+	bsdChecksumRe = regexp.MustCompile(`^SHA(?:256|512) ?\((.+)\) ?= ?([a-fA-F0-9]{128}|[a-fA-F0-9]{64})$`)
+)
+
+// checksumSizes are the hex lengths of the checksums of each algorithm.
+var checksumSizes = map[string]int{
+	"sha256": 64,
+	"sha512": 128,
+}
+
+// archiveChecksums caches the checksums of the archives, so archives used by
+// multiple formulas are only hashed once.
+// nolint: gochecknoglobals
+var archiveChecksums = newChecksumCache(func(art *artifact.Artifact, algorithm string) (string, error) {
+	return art.Checksum(algorithm)
+})
+
+// checksumCache is a concurrency-safe cache of artifact checksums.
+// Entries are keyed by the path, size and modification time of the file, so
+// files changed in between are hashed again.
+type checksumCache struct {
+	calculate func(art *artifact.Artifact, algorithm string) (string, error)
+	l         sync.Mutex
+	sums      map[string]*cachedChecksum
+}
+
+type cachedChecksum struct {
+	once sync.Once
+	sum  string
+	err  error
+}
+
+func newChecksumCache(calculate func(art *artifact.Artifact, algorithm string) (string, error)) *checksumCache {
+	return &checksumCache{
+		calculate: calculate,
+		sums:      map[string]*cachedChecksum{},
+	}
+}
+
+// get returns the checksum of the given artifact, calculating it only if it
+// is not cached yet.
+// Concurrent calls for the same artifact wait for a single calculation.
+func (c *checksumCache) get(art *artifact.Artifact, algorithm string) (string, error) {
+	info, err := os.Stat(art.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to checksum: %w", err)
+	}
+	key := fmt.Sprintf("%s:%s:%d:%d", algorithm, art.Path, info.Size(), info.ModTime().UnixNano())
+
+	c.l.Lock()
+	cached, ok := c.sums[key]
+	if !ok {
+		cached = &cachedChecksum{}
+		c.sums[key] = cached
+	}
+	c.l.Unlock()
+
+	cached.once.Do(func() {
+		cached.sum, cached.err = c.calculate(art, algorithm)
+	})
+	return cached.sum, cached.err
+}
+
+// checksumFromFile returns the checksum of the given artifact name from the
+// given checksums file, which must use the given algorithm.
+func checksumFromFile(ctx *context.Context, path, name, algorithm string) (string, error) {
+	path, err := tmpl.New(ctx).Apply(path)
+	if err != nil {
+		return "", err
+	}
+	sums, err := readChecksums(path)
+	if err != nil {
+		return "", err
+	}
+	sum, ok := sums[name]
+	if !ok {
+		return "", fmt.Errorf("%s: no checksum found for %s", path, name)
+	}
+	if size := checksumSizes[algorithm]; len(sum) != size {
+		return "", fmt.Errorf("%s: checksum for %s is not a %s", path, name, algorithm)
+	}
+	return sum, nil
+}
+
+// readChecksums reads the given checksums file, returning the checksums by
+// file name.
+// Both the GNU and BSD formats are supported, even mixed in the same file.
+func readChecksums(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read checksums file: %w", err)
+	}
+	defer f.Close()
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	var line int
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if m := gnuChecksumRe.FindStringSubmatch(text); m != nil {
+			sums[m[2]] = strings.ToLower(m[1])
+			continue
+		}
+		if m := bsdChecksumRe.FindStringSubmatch(text); m != nil {
+			sums[m[1]] = strings.ToLower(m[2])
+			continue
+		}
+		return nil, fmt.Errorf("%s:%d: unrecognized checksum format, expected either '<sum>  <name>' or 'SHA256 (<name>) = <sum>'", path, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read checksums file: %w", err)
+	}
+	return sums, nil
+}
@@ -0,0 +1,39 @@
+package brew
+
+import (
+	"sync"
+
+	"github.com/goreleaser/goreleaser/pkg/context"
+)
+
+// FormulaData is the data used to render the formula template.
+type FormulaData = templateData
+
+// Hook can modify the formula data right before the formula is rendered.
+type Hook func(ctx *context.Context, data *FormulaData) error
+
+// nolint: gochecknoglobals
+var (
+	hooks    []Hook
+	hookLock sync.Mutex
+)
+
+// RegisterHook registers a hook to run on every formula, in registration
+// order, before it is rendered.
+func RegisterHook(hook Hook) {
+	hookLock.Lock()
+	defer hookLock.Unlock()
+	hooks = append(hooks, hook)
+}
+
+func runHooks(ctx *context.Context, data *templateData) error {
+	hookLock.Lock()
+	registered := append([]Hook(nil), hooks...)
+	hookLock.Unlock()
+	for _, hook := range registered {
+		if err := hook(ctx, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
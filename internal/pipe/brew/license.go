@@ -0,0 +1,310 @@
+package brew
+
+import (
+	"fmt"
+	"strings"
+)
+
+// spdxLicenses are the SPDX license identifiers known when checking
+// brew.license, the most used ones from https://spdx.org/licenses.
+// nolint: gochecknoglobals
+var spdxLicenses = []string{
+	"0BSD",
+	"AFL-3.0",
+	"AGPL-3.0-only",
+	"AGPL-3.0-or-later",
+	"Apache-1.1",
+	"Apache-2.0",
+	"APSL-2.0",
+	"Artistic-1.0",
+	"Artistic-2.0",
+	"BlueOak-1.0.0",
+	"BSD-1-Clause",
+	"BSD-2-Clause",
+	"BSD-2-Clause-Patent",
+	"BSD-3-Clause",
+	"BSD-3-Clause-Clear",
+	"BSD-4-Clause",
+	"BSL-1.0",
+	"BUSL-1.1",
+	"CC-BY-3.0",
+	"CC-BY-4.0",
+	"CC-BY-SA-3.0",
+	"CC-BY-SA-4.0",
+	"CC0-1.0",
+	"CDDL-1.0",
+	"CDDL-1.1",
+	"CECILL-2.1",
+	"CPL-1.0",
+	"ECL-2.0",
+	"EPL-1.0",
+	"EPL-2.0",
+	"EUPL-1.1",
+	"EUPL-1.2",
+	"GPL-1.0-only",
+	"GPL-1.0-or-later",
+	"GPL-2.0-only",
+	"GPL-2.0-or-later",
+	"GPL-3.0-only",
+	"GPL-3.0-or-later",
+	"HPND",
+	"ICU",
+	"IPL-1.0",
+	"ISC",
+	"LGPL-2.0-only",
+	"LGPL-2.0-or-later",
+	"LGPL-2.1-only",
+	"LGPL-2.1-or-later",
+	"LGPL-3.0-only",
+	"LGPL-3.0-or-later",
+	"LPPL-1.3c",
+	"MIT",
+	"MIT-0",
+	"MPL-1.1",
+	"MPL-2.0",
+	"MPL-2.0-no-copyleft-exception",
+	"MS-PL",
+	"MS-RL",
+	"MulanPSL-2.0",
+	"NCSA",
+	"ODbL-1.0",
+	"OFL-1.1",
+	"OpenSSL",
+	"OSL-3.0",
+	"PHP-3.01",
+	"PostgreSQL",
+	"PSF-2.0",
+	"Python-2.0",
+	"Ruby",
+	"SSPL-1.0",
+	"Unicode-DFS-2016",
+	"Unlicense",
+	"UPL-1.0",
+	"Vim",
+	"W3C",
+	"WTFPL",
+	"X11",
+	"Zlib",
+	"ZPL-2.1",
+}
+
+// spdxExceptions are the SPDX license exceptions known when checking
+// brew.license, used after WITH, e.g. `GPL-2.0-only WITH Classpath-exception-2.0`.
+// nolint: gochecknoglobals
+var spdxExceptions = []string{
+	"Autoconf-exception-3.0",
+	"Bison-exception-2.2",
+	"Classpath-exception-2.0",
+	"GCC-exception-3.1",
+	"LLVM-exception",
+	"OpenSSL-exception",
+	"Qt-LGPL-exception-1.1",
+	"Universal-FOSS-exception-1.0",
+}
+
+// normalizeLicense checks that the given license is a valid SPDX expression,
+// e.g. `MIT OR (Apache-2.0 WITH LLVM-exception)`, returning it with its
+// identifiers and operators in their canonical case.
+func normalizeLicense(license string) (string, error) {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(license))
+	if len(tokens) == 0 {
+		return "", fmt.Errorf("invalid license expression %q: empty", license)
+	}
+
+	result := make([]string, 0, len(tokens))
+	expectOperand := true
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch op := strings.ToUpper(token); {
+		case token == "(":
+			if !expectOperand {
+				return "", fmt.Errorf("invalid license expression %q: unexpected %q", license, token)
+			}
+			depth++
+		case token == ")":
+			if expectOperand || depth == 0 {
+				return "", fmt.Errorf("invalid license expression %q: unexpected %q", license, token)
+			}
+			depth--
+		case op == "AND" || op == "OR":
+			if expectOperand {
+				return "", fmt.Errorf("invalid license expression %q: unexpected %q", license, token)
+			}
+			token = op
+			expectOperand = true
+		case op == "WITH":
+			if expectOperand || result[len(result)-1] == ")" || i+1 == len(tokens) {
+				return "", fmt.Errorf("invalid license expression %q: unexpected %q", license, token)
+			}
+			i++
+			exception, ok := lookupFold(spdxExceptions, tokens[i])
+			if !ok {
+				return "", fmt.Errorf("invalid license expression %q: unknown license exception %q", license, tokens[i])
+			}
+			result = append(result, "WITH")
+			token = exception
+		default:
+			if !expectOperand {
+				return "", fmt.Errorf("invalid license expression %q: unexpected %q", license, token)
+			}
+			id, err := normalizeLicenseID(token)
+			if err != nil {
+				return "", fmt.Errorf("invalid license expression %q: %w", license, err)
+			}
+			token = id
+			expectOperand = false
+		}
+		result = append(result, token)
+	}
+	if expectOperand || depth != 0 {
+		return "", fmt.Errorf("invalid license expression %q: incomplete", license)
+	}
+
+	return strings.NewReplacer("( ", "(", " )", ")").Replace(strings.Join(result, " ")), nil
+}
+
+// normalizeLicenseID returns the given license identifier in its canonical
+// case, keeping the `+` suffix, if any.
+// Custom `LicenseRef-` identifiers are kept as is.
+func normalizeLicenseID(id string) (string, error) {
+	if strings.HasPrefix(id, "LicenseRef-") {
+		return id, nil
+	}
+	name, plus := strings.CutSuffix(id, "+")
+	known, ok := lookupFold(spdxLicenses, name)
+	if !ok {
+		return "", fmt.Errorf("unknown license identifier %q", id)
+	}
+	if plus {
+		return known + "+", nil
+	}
+	return known, nil
+}
+
+// lookupFold returns the item of the list equal to s, ignoring case.
+func lookupFold(list []string, s string) (string, bool) {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return item, true
+		}
+	}
+	return "", false
+}
+
+// licenseNode is a parsed SPDX expression: either a license identifier, with
+// its exception if any, or the AND/OR of its children.
+type licenseNode struct {
+	id        string
+	exception string
+	op        string
+	children  []licenseNode
+}
+
+// licenseParser parses SPDX expressions, with WITH binding tighter than AND,
+// and AND tighter than OR.
+type licenseParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *licenseParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return strings.ToUpper(p.tokens[p.pos])
+	}
+	return ""
+}
+
+func (p *licenseParser) next() string {
+	token := p.tokens[p.pos]
+	p.pos++
+	return token
+}
+
+func (p *licenseParser) or() (licenseNode, bool) {
+	return p.list("OR", "any_of", p.and)
+}
+
+func (p *licenseParser) and() (licenseNode, bool) {
+	return p.list("AND", "all_of", p.primary)
+}
+
+func (p *licenseParser) list(op, dsl string, operand func() (licenseNode, bool)) (licenseNode, bool) {
+	first, ok := operand()
+	if !ok {
+		return first, false
+	}
+	result := licenseNode{op: dsl, children: []licenseNode{first}}
+	for p.peek() == op {
+		p.next()
+		node, ok := operand()
+		if !ok {
+			return node, false
+		}
+		result.children = append(result.children, node)
+	}
+	if len(result.children) == 1 {
+		return first, true
+	}
+	return result, true
+}
+
+func (p *licenseParser) primary() (licenseNode, bool) {
+	switch p.peek() {
+	case "", ")", "AND", "OR", "WITH":
+		return licenseNode{}, false
+	case "(":
+		p.next()
+		node, ok := p.or()
+		if !ok || p.peek() != ")" {
+			return node, false
+		}
+		p.next()
+		return node, true
+	}
+	node := licenseNode{id: p.next()}
+	if p.peek() == "WITH" {
+		p.next()
+		if p.peek() == "" {
+			return node, false
+		}
+		node.exception = p.next()
+	}
+	return node, true
+}
+
+// render returns the node in the Homebrew license DSL.
+// Nested nodes are rendered as array items, so hashes are wrapped in braces.
+func (n licenseNode) render(style string, nested bool) string {
+	var result string
+	switch {
+	case n.op != "":
+		items := make([]string, 0, len(n.children))
+		for _, child := range n.children {
+			items = append(items, child.render(style, true))
+		}
+		result = n.op + ": [" + strings.Join(items, ", ") + "]"
+	case n.exception != "":
+		result = quote(style, n.id) + " => { with: " + quote(style, n.exception) + " }"
+	default:
+		return quote(style, n.id)
+	}
+	if nested {
+		return "{ " + result + " }"
+	}
+	return result
+}
+
+// licenseDSL renders the given SPDX expression in the Homebrew license DSL,
+// e.g. `MIT OR Apache-2.0` as `any_of: ["MIT", "Apache-2.0"]`.
+// Expressions it can't parse are rendered as a plain string.
+func licenseDSL(style, license string) string {
+	p := &licenseParser{
+		tokens: strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(license)),
+	}
+	node, ok := p.or()
+	if !ok || p.pos != len(p.tokens) {
+		return quote(style, license)
+	}
+	return node.render(style, false)
+}
@@ -0,0 +1,53 @@
+package brew
+
+import (
+	"strings"
+
+	"github.com/goreleaser/goreleaser/internal/tmpl"
+	"github.com/goreleaser/goreleaser/pkg/config"
+	"github.com/goreleaser/goreleaser/pkg/context"
+)
+
+// defaultLivecheckStrategy is used when brew.livecheck.url is the only
+// option set.
+const defaultLivecheckStrategy = "github_latest"
+
+// livecheckFor builds the livecheck block of the formula from
+// brew.livecheck, returning nil if nothing is configured.
+func livecheckFor(ctx *context.Context, cfg config.HomebrewLivecheck) (*livecheck, error) {
+	var result livecheck
+	if err := tmpl.New(ctx).ApplyAll(
+		&cfg.URL,
+		&cfg.Strategy,
+		&cfg.Regex,
+	); err != nil {
+		return nil, err
+	}
+	if cfg.URL == "" && cfg.Strategy == "" && cfg.Regex == "" {
+		return nil, nil
+	}
+
+	result.URL = cfg.URL
+	result.Strategy = strings.TrimPrefix(cfg.Strategy, ":")
+	if result.Strategy == "" && cfg.Regex == "" {
+		result.Strategy = defaultLivecheckStrategy
+	}
+	result.Regex = escapeRegexSlashes(cfg.Regex)
+	return &result, nil
+}
+
+// escapeRegexSlashes escapes the slashes of the given regular expression, so
+// it can be rendered as a /.../ Ruby literal.
+// Already escaped slashes are kept as is.
+func escapeRegexSlashes(regex string) string {
+	var sb strings.Builder
+	escaped := false
+	for _, r := range regex {
+		if r == '/' && !escaped {
+			sb.WriteRune('\\')
+		}
+		escaped = r == '\\' && !escaped
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,85 @@
+package brew
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/caarlos0/log"
+	"github.com/goreleaser/goreleaser/internal/tmpl"
+	"github.com/goreleaser/goreleaser/pkg/config"
+	"github.com/goreleaser/goreleaser/pkg/context"
+)
+
+// sourcePackageFor returns the package of a formula built from source, which
+// downloads the source tarball GitHub generates for the current tag.
+func sourcePackageFor(ctx *context.Context, cfg config.Homebrew) (*releasePackage, error) {
+	url, err := sourceURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := sourceChecksum(ctx, cfg, url)
+	if err != nil {
+		return nil, err
+	}
+	return &releasePackage{
+		DownloadURL:       url,
+		SHA256:            sum,
+		DownloadStrategy:  cfg.DownloadStrategy,
+		URLUsing:          cfg.URLUsing,
+		URLHeaders:        cfg.URLHeaders,
+		Install:           append(split(cfg.Install), split(cfg.ExtraInstall)...),
+		ChecksumAlgorithm: checksumAlgorithm(cfg.ChecksumAlgorithm),
+	}, nil
+}
+
+// sourceURL returns the URL of the source tarball of the current tag.
+func sourceURL(ctx *context.Context) (string, error) {
+	download, err := tmpl.New(ctx).Apply(ctx.Config.GitHubURLs.Download)
+	if err != nil {
+		return "", fmt.Errorf("templating GitHub download URL: %w", err)
+	}
+	return fmt.Sprintf(
+		"%s/%s/%s/archive/refs/tags/%s.tar.gz",
+		strings.TrimSuffix(download, "/"),
+		ctx.Config.Release.GitHub.Owner,
+		ctx.Config.Release.GitHub.Name,
+		ctx.Git.CurrentTag,
+	), nil
+}
+
+// sourceChecksum downloads the given source tarball and returns its checksum.
+func sourceChecksum(ctx *context.Context, cfg config.Homebrew, url string) (string, error) {
+	if ctx.Snapshot && cfg.AllowPlaceholderChecksum {
+		log.WithField("url", url).Debug("snapshot: using placeholder checksum")
+		return placeholderChecksumFor(cfg), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	log.WithField("url", url).Info("downloading source tarball")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not download source tarball: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not download source tarball %s: %s", url, resp.Status)
+	}
+
+	var h hash.Hash = sha256.New()
+	if checksumAlgorithm(cfg.ChecksumAlgorithm) == "sha512" {
+		h = sha512.New()
+	}
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", fmt.Errorf("could not download source tarball: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
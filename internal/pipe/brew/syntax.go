@@ -0,0 +1,41 @@
+package brew
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/caarlos0/log"
+	"github.com/goreleaser/goreleaser/pkg/context"
+)
+
+// rubySyntaxErrorRe matches the location of the first error in the output
+// of `ruby -c -`.
+var rubySyntaxErrorRe = regexp.MustCompile(`-:(\d+): (.+)`)
+
+// checkRubySyntax runs `ruby -c` against the generated formula.
+// As ruby is not required to run GoReleaser, it only warns if it is missing.
+func checkRubySyntax(ctx *context.Context, content string) error {
+	ruby, err := exec.LookPath("ruby")
+	if err != nil {
+		log.Warn("ruby not found in PATH, skipping brew.validate_formula")
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, ruby, "-c", "-")
+	cmd.Stdin = strings.NewReader(content)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("brew: could not check formula syntax: %w", err)
+	}
+	if match := rubySyntaxErrorRe.FindStringSubmatch(string(out)); match != nil {
+		return fmt.Errorf("brew: invalid formula: line %s: %s", match[1], strings.TrimSpace(match[2]))
+	}
+	return fmt.Errorf("brew: invalid formula: %s", strings.TrimSpace(string(out)))
+}
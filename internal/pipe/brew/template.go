@@ -1,6 +1,10 @@
 package brew
 
-import "github.com/goreleaser/goreleaser/pkg/config"
+import (
+	"strings"
+
+	"github.com/goreleaser/goreleaser/pkg/config"
+)
 
 type templateData struct {
 	Name                 string
@@ -9,10 +13,18 @@ type templateData struct {
 	Version              string
 	License              string
 	Caveats              []string
+	MacOSCaveats         []string
+	LinuxCaveats         []string
 	Plist                string
+	PreInstall           []string
 	PostInstall          []string
+	MacOSPostInstall     []string
+	LinuxPostInstall     []string
 	Dependencies         []config.HomebrewDependency
+	MacOSDependencies    []config.HomebrewDependency
+	LinuxDependencies    []config.HomebrewDependency
 	Conflicts            []string
+	OldNames             []string
 	Tests                []string
 	CustomRequire        string
 	CustomBlock          []string
@@ -20,6 +32,39 @@ type templateData struct {
 	MacOSPackages        []releasePackage
 	Service              []string
 	HasOnlyAmd64MacOsPkg bool
+	MacOSArchBlocks      bool
+	MacOSInstall         []string
+	LinuxInstall         []string
+	HeadInstall          []string
+	QuoteStyle           string
+	FrozenStringLiteral  bool
+	RenamedBinaries      []config.HomebrewRenamedBinary
+	TapName              string
+	RequireArch          string
+	TestConfig           config.HomebrewTestConfig
+	Deprecate            config.HomebrewDeprecate
+	DeprecateDirective   *directive
+	DisableDirective     *directive
+	Sorbet               string
+	SorbetSigs           bool
+	RequireOS            string
+	Bottle               *bottle
+	Source               *releasePackage
+	LegacyOS             bool
+	Livecheck            *livecheck
+	NoAutobump           string
+	HelpTest             string
+	Assertions           []string
+	IndentWidth          int
+	LineEnding           string
+	SkipSanitize         bool
+	InlinePatch          string
+	Head                 config.HomebrewHead
+	Resources            []releaseResource
+	Commit               string
+	// Template is the path of a custom formula template, used instead of the
+	// built-in one when set.
+	Template string
 }
 
 type releasePackage struct {
@@ -28,63 +73,277 @@ type releasePackage struct {
 	OS               string
 	Arch             string
 	DownloadStrategy string
+	URLUsing         string
+	URLHeaders       []string
 	Install          []string
+	Resources        []releaseResource
+	// Goarm, Goamd64 and Goarm64 are the feature levels of the archive, if
+	// any, e.g. for custom templates targeting specific CPUs.
+	Goarm   string
+	Goamd64 string
+	Goarm64 string
+	// ChecksumAlgorithm is the algorithm used in all checksums of the package,
+	// SHA256 holds a sha512 despite its name when that's the algorithm used.
+	ChecksumAlgorithm string
 }
 
-const formulaTemplate = `# typed: false
-# frozen_string_literal: true
+// ChecksumKeyword returns the formula keyword for the checksums of the
+// package, sha256 unless sha512 is used.
+func (p releasePackage) ChecksumKeyword() string {
+	return checksumAlgorithm(p.ChecksumAlgorithm)
+}
+
+// bottle is the bottle block of the formula.
+type bottle struct {
+	RootURL   string
+	Rebuild   int
+	Checksums []bottleChecksum
+}
+
+// bottleChecksum is the checksum of the bottle for a given platform tag.
+type bottleChecksum struct {
+	Cellar string
+	Tag    string
+	SHA256 string
+}
+
+// CellarIsPath tells whether the cellar is a path instead of a symbol like
+// :any_skip_relocation.
+func (c bottleChecksum) CellarIsPath() bool {
+	return strings.HasPrefix(c.Cellar, "/")
+}
+
+// directive is a deprecate! or disable! directive of the formula.
+type directive struct {
+	Date string
+	// Because is the reason, already rendered as either a symbol or a string.
+	Because string
+}
 
+// livecheck is the livecheck block of the formula.
+type livecheck struct {
+	URL      string
+	Strategy string
+	Regex    string
+}
+
+// URLIsSymbol tells whether the URL is a symbol like :stable or :homepage
+// instead of an actual URL.
+func (l livecheck) URLIsSymbol() bool {
+	return strings.HasPrefix(l.URL, ":")
+}
+
+// releaseResource is an additional archive installed alongside the main one,
+// or, at the formula level, an additional download like a bundled dependency.
+type releaseResource struct {
+	Name        string
+	DownloadURL string
+	SHA256      string
+	Install     []string
+}
+
+const formulaTemplate = `# typed: {{ or .Sorbet "false" }}
+{{ if .FrozenStringLiteral -}}
+# frozen_string_literal: true
+{{ end }}
 # This file was generated by GoReleaser. DO NOT EDIT.
+{{ with .Commit -}}
+# built from {{ . }}
+{{ end -}}
 {{ if .CustomRequire -}}
-require_relative "{{ .CustomRequire }}"
+require_relative {{ quote .CustomRequire }}
 {{ end -}}
 class {{ .Name }} < Formula
-  desc "{{ .Desc }}"
-  homepage "{{ .Homepage }}"
-  version "{{ .Version }}"
+  desc {{ quote .Desc }}
+  homepage {{ quote .Homepage }}
+  version {{ quote .Version }}
+  {{- with .Source }}
+  url {{ quote .DownloadURL }}
+  {{- template "url_options" . }}
+  {{ .ChecksumKeyword }} {{ quote .SHA256 }}
+  {{- end }}
   {{- if .License }}
-  license "{{ .License }}"
+  license {{ license .License }}
+  {{- end }}
+  {{- range .OldNames }}
+  oldname {{ quote . }}
+  {{- end }}
+  {{- with .Head }}{{ if .URL }}
+
+  head do
+    url {{ quote .URL }}
+    {{- range .Dependencies }}
+    {{ template "dependency" . }}
+    {{- end }}
+  end
+  {{- end }}{{ end }}
+  {{- with .Livecheck }}
+
+  livecheck do
+    {{- if .URL }}
+    url {{ if .URLIsSymbol }}{{ .URL }}{{ else }}{{ quote .URL }}{{ end }}
+    {{- end }}
+    {{- if .Strategy }}
+    strategy :{{ .Strategy }}
+    {{- end }}
+    {{- if .Regex }}
+    regex(/{{ .Regex }}/)
+    {{- end }}
+  end
+  {{- end }}
+  {{- with .NoAutobump }}
+
+  no_autobump! because: {{ . }}
+  {{- end }}
+  {{- with .Bottle }}
+
+  bottle do
+    {{- if .RootURL }}
+    root_url {{ quote .RootURL }}
+    {{- end }}
+    {{- if .Rebuild }}
+    rebuild {{ .Rebuild }}
+    {{- end }}
+    {{- range .Checksums }}
+    sha256 {{ if .Cellar }}cellar: {{ if .CellarIsPath }}{{ quote .Cellar }}{{ else }}{{ .Cellar }}{{ end }}, {{ end }}{{ .Tag }}: {{ quote .SHA256 }}
+    {{- end }}
+  end
+  {{- end }}
+  {{- with .DeprecateDirective }}
+  deprecate! {{ with .Date }}date: {{ quote . }}, {{ end }}because: {{ .Because }}
+  {{- end }}
+  {{- with .DisableDirective }}
+  disable! {{ with .Date }}date: {{ quote . }}, {{ end }}because: {{ .Because }}
   {{- end }}
   {{- with .Dependencies }}
   {{ range $index, $element := . }}
-  depends_on "{{ .Name }}"
-  {{- if .Type }} => :{{ .Type }}{{- else if .Version }} => "{{ .Version }}"{{- end }}
+  {{ template "dependency" . }}
   {{- end }}
   {{- end -}}
 
-  {{- if and (not .LinuxPackages) .MacOSPackages }}
-  depends_on :macos
+  {{- with .MacOSDependencies }}
+
+  {{ if $.LegacyOS }}if OS.mac?{{ else }}on_macos do{{ end }}
+    {{- range . }}
+    {{ template "dependency" . }}
+    {{- end }}
+  end
+  {{- end -}}
+
+  {{- with .LinuxDependencies }}
+
+  {{ if $.LegacyOS }}if OS.linux?{{ else }}on_linux do{{ end }}
+    {{- range . }}
+    {{ template "dependency" . }}
+    {{- end }}
+  end
+  {{- end -}}
+
+  {{- with .RequireArch }}
+  depends_on arch: :{{ . }}
   {{- end }}
-  {{- if and (not .MacOSPackages) .LinuxPackages }}
+  {{- if .RequireOS }}
+  depends_on :{{ .RequireOS }}
+  {{- else if and (not .LinuxPackages) .MacOSPackages }}
+  depends_on :macos
+  {{- else if and (not .MacOSPackages) .LinuxPackages }}
   depends_on :linux
   {{- end }}
+  {{- range .Resources }}
+
+  resource {{ quote .Name }} do
+    url {{ quote .DownloadURL }}
+    sha256 {{ quote .SHA256 }}
+  end
+  {{- end }}
+  {{- if .InlinePatch }}
+
+  patch :DATA
+  {{- end }}
   {{- printf "\n" }}
 
+  {{- with .Source }}
+  {{ if $.SorbetSigs -}}
+  sig { void }
+  {{ end -}}
+  def install
+    {{- range $.PreInstall }}
+    {{ . }}
+    {{- end }}
+    {{- range .Install }}
+    {{ . }}
+    {{- end }}
+  end
+  {{- end }}
+
   {{- if .MacOSPackages }}
-  on_macos do
+  {{ if $.LegacyOS }}if OS.mac?{{ else }}on_macos do{{ end }}
   {{- range $element := .MacOSPackages }}
     {{- if eq $element.Arch "all" }}
-    url "{{ $element.DownloadURL }}"
-	{{- if .DownloadStrategy }}, using: {{ .DownloadStrategy }}{{- end }}
-    sha256 "{{ $element.SHA256 }}"
+    url {{ quote $element.DownloadURL }}
+	{{- template "url_options" . }}
+    {{ $element.ChecksumKeyword }} {{ quote $element.SHA256 }}
+    {{- range $element.Resources }}
 
+    resource {{ quote .Name }} do
+      url {{ quote .DownloadURL }}
+      {{ $element.ChecksumKeyword }} {{ quote .SHA256 }}
+    end
+    {{- end }}
+
+    {{ if $.SorbetSigs -}}
+    sig { void }
+    {{ end -}}
     def install
+      {{- range $.PreInstall }}
+      {{ . }}
+      {{- end }}
       {{- range $index, $element := .Install }}
       {{ . -}}
       {{- end }}
+      {{- range $element.Resources }}
+      resource({{ quote .Name }}).stage do
+        {{- range .Install }}
+        {{ . }}
+        {{- end }}
+      end
+      {{- end }}
     end
     {{- else if $.HasOnlyAmd64MacOsPkg }}
-    url "{{ $element.DownloadURL }}"
-	{{- if .DownloadStrategy }}, using: {{ .DownloadStrategy }}{{- end }}
-    sha256 "{{ $element.SHA256 }}"
+    url {{ quote $element.DownloadURL }}
+	{{- template "url_options" . }}
+    {{ $element.ChecksumKeyword }} {{ quote $element.SHA256 }}
+    {{- range $element.Resources }}
+
+    resource {{ quote .Name }} do
+      url {{ quote .DownloadURL }}
+      {{ $element.ChecksumKeyword }} {{ quote .SHA256 }}
+    end
+    {{- end }}
 
+    {{ if $.SorbetSigs -}}
+    sig { void }
+    {{ end -}}
     def install
+      {{- range $.PreInstall }}
+      {{ . }}
+      {{- end }}
       {{- range $index, $element := .Install }}
       {{ . -}}
       {{- end }}
+      {{- range $element.Resources }}
+      resource({{ quote .Name }}).stage do
+        {{- range .Install }}
+        {{ . }}
+        {{- end }}
+      end
+      {{- end }}
     end
 
     if Hardware::CPU.arm?
+      {{ if $.SorbetSigs -}}
+      sig { returns(String) }
+      {{ end -}}
       def caveats
         <<~EOS
           The darwin_arm64 architecture is not supported for the {{ $.Name }}
@@ -95,30 +354,66 @@ class {{ .Name }} < Formula
     end
     {{- else }}
     {{- if eq $element.Arch "amd64" }}
-    if Hardware::CPU.intel?
+    {{ if $.MacOSArchBlocks }}on_intel do{{ else }}if Hardware::CPU.intel?{{ end }}
     {{- end }}
     {{- if eq $element.Arch "arm64" }}
-    if Hardware::CPU.arm?
+    {{ if $.MacOSArchBlocks }}on_arm do{{ else }}if Hardware::CPU.arm?{{ end }}
     {{- end}}
-      url "{{ $element.DownloadURL }}"
-      {{- if .DownloadStrategy }}, using: {{ .DownloadStrategy }}{{- end }}
-      sha256 "{{ $element.SHA256 }}"
+      url {{ quote $element.DownloadURL }}
+      {{- template "url_options" . }}
+      {{ $element.ChecksumKeyword }} {{ quote $element.SHA256 }}
+      {{- range $element.Resources }}
+
+      resource {{ quote .Name }} do
+        url {{ quote .DownloadURL }}
+        {{ $element.ChecksumKeyword }} {{ quote .SHA256 }}
+      end
+      {{- end }}
+      {{- if not $.MacOSInstall }}
 
+      {{ if $.SorbetSigs -}}
+      sig { void }
+      {{ end -}}
       def install
+        {{- range $.PreInstall }}
+        {{ . }}
+        {{- end }}
         {{- range $index, $element := .Install }}
         {{ . -}}
         {{- end }}
+        {{- range $element.Resources }}
+        resource({{ quote .Name }}).stage do
+          {{- range .Install }}
+          {{ . }}
+          {{- end }}
+        end
+        {{- end }}
       end
+      {{- end }}
     end
     {{- end }}
   {{- end }}
+  {{- with .MacOSInstall }}
+
+    {{ if $.SorbetSigs -}}
+    sig { void }
+    {{ end -}}
+    def install
+      {{- range $.PreInstall }}
+      {{ . }}
+      {{- end }}
+      {{- range . }}
+      {{ . }}
+      {{- end }}
+    end
+  {{- end }}
   end
   {{- end }}
 
   {{- if and .MacOSPackages .LinuxPackages }}{{ printf "\n" }}{{ end }}
 
   {{- if .LinuxPackages }}
-  on_linux do
+  {{ if $.LegacyOS }}if OS.linux?{{ else }}on_linux do{{ end }}
   {{- range $element := .LinuxPackages }}
     {{- if eq $element.Arch "amd64" }}
     if Hardware::CPU.intel?
@@ -129,23 +424,80 @@ class {{ .Name }} < Formula
     {{- if eq $element.Arch "arm64" }}
     if Hardware::CPU.arm? && Hardware::CPU.is_64_bit?
     {{- end }}
-      url "{{ $element.DownloadURL }}"
-	  {{- if .DownloadStrategy }}, using: {{ .DownloadStrategy }}{{- end }}
-      sha256 "{{ $element.SHA256 }}"
+      url {{ quote $element.DownloadURL }}
+	  {{- template "url_options" . }}
+      {{ $element.ChecksumKeyword }} {{ quote $element.SHA256 }}
+      {{- range $element.Resources }}
 
+      resource {{ quote .Name }} do
+        url {{ quote .DownloadURL }}
+        {{ $element.ChecksumKeyword }} {{ quote .SHA256 }}
+      end
+      {{- end }}
+      {{- if not $.LinuxInstall }}
+
+      {{ if $.SorbetSigs -}}
+      sig { void }
+      {{ end -}}
       def install
+        {{- range $.PreInstall }}
+        {{ . }}
+        {{- end }}
         {{- range $index, $element := .Install }}
         {{ . -}}
         {{- end }}
+        {{- range $element.Resources }}
+        resource({{ quote .Name }}).stage do
+          {{- range .Install }}
+          {{ . }}
+          {{- end }}
+        end
+        {{- end }}
       end
+      {{- end }}
+    end
+  {{- end }}
+  {{- with .LinuxInstall }}
+
+    {{ if $.SorbetSigs -}}
+    sig { void }
+    {{ end -}}
+    def install
+      {{- range $.PreInstall }}
+      {{ . }}
+      {{- end }}
+      {{- range . }}
+      {{ . }}
+      {{- end }}
     end
   {{- end }}
   end
   {{- end }}
 
+  {{- with .HeadInstall }}
+
+  alias stable_install install
+
+  {{ if $.SorbetSigs -}}
+  sig { void }
+  {{ end -}}
+  def install
+    if build.head?
+      {{- range $.PreInstall }}
+      {{ . }}
+      {{- end }}
+      {{- range . }}
+      {{ . }}
+      {{- end }}
+      return
+    end
+    stable_install
+  end
+  {{- end }}
+
   {{- with .Conflicts }}
   {{ range $index, $element := . }}
-  conflicts_with "{{ . }}"
+  conflicts_with {{ quote . }}
   {{- end }}
   {{- end }}
 
@@ -155,17 +507,84 @@ class {{ .Name }} < Formula
   {{- end }}
   {{- end }}
 
-  {{- with .PostInstall }}
 
+  {{- if or .PostInstall .MacOSPostInstall .LinuxPostInstall }}
+
+  {{ if $.SorbetSigs -}}
+  sig { void }
+  {{ end -}}
   def post_install
-    {{- range . }}
+    {{- range .PostInstall }}
     {{ . }}
     {{- end }}
+    {{- with .MacOSPostInstall }}
+    {{ if $.LegacyOS }}if OS.mac?{{ else }}on_macos do{{ end }}
+      {{- range . }}
+      {{ . }}
+      {{- end }}
+    end
+    {{- end }}
+    {{- with .LinuxPostInstall }}
+    {{ if $.LegacyOS }}if OS.linux?{{ else }}on_linux do{{ end }}
+      {{- range . }}
+      {{ . }}
+      {{- end }}
+    end
+    {{- end }}
   end
   {{- end -}}
 
+  {{- if or .RenamedBinaries .MacOSCaveats .LinuxCaveats }}
+
+  {{ if $.SorbetSigs -}}
+  sig { returns(String) }
+  {{ end -}}
+  def caveats
+    messages = []
+    {{- with .Caveats }}
+    messages << <<~EOS
+    {{- range $index, $element := . }}
+      {{ . -}}
+    {{- end }}
+    EOS
+    {{- end }}
+    {{- with .MacOSCaveats }}
+    {{ if $.LegacyOS }}if OS.mac?{{ else }}on_macos do{{ end }}
+      messages << <<~EOS
+      {{- range . }}
+        {{ . -}}
+      {{- end }}
+      EOS
+    end
+    {{- end }}
+    {{- with .LinuxCaveats }}
+    {{ if $.LegacyOS }}if OS.linux?{{ else }}on_linux do{{ end }}
+      messages << <<~EOS
+      {{- range . }}
+        {{ . -}}
+      {{- end }}
+      EOS
+    end
+    {{- end }}
+    {{- range .RenamedBinaries }}
+    if (HOMEBREW_PREFIX/{{ quote (printf "bin/%s" .Old) }}).exist?
+      messages << <<~EOS
+        {{- if .New }}
+        The {{ .Old }} command has been renamed to {{ .New }}, please use it instead.
+        {{- else }}
+        The {{ .Old }} command has been removed.
+        {{- end }}
+      EOS
+    end
+    {{- end }}
+    messages.join
+  end
+  {{- else }}
   {{- with .Caveats }}
 
+  {{ if $.SorbetSigs -}}
+  sig { returns(String) }
+  {{ end -}}
   def caveats
     <<~EOS
     {{- range $index, $element := . }}
@@ -174,11 +593,15 @@ class {{ .Name }} < Formula
     EOS
   end
   {{- end -}}
+  {{- end -}}
 
   {{- with .Plist }}
 
   plist_options startup: false
 
+  {{ if $.SorbetSigs -}}
+  sig { returns(String) }
+  {{ end -}}
   def plist
     <<~EOS
       {{ . }}
@@ -195,13 +618,47 @@ class {{ .Name }} < Formula
   end
   {{- end -}}
 
-  {{- if .Tests }}
+  {{- if or .Tests .TestConfig.Command .TestConfig.Binaries .HelpTest .Assertions }}
 
   test do
+    {{- range .TestConfig.Fixtures }}
+    (testpath/{{ quote .Path }}).write {{ quote .Content }}
+    {{- end }}
+    {{- with .TestConfig.Command }}
+    {{- if $.TestConfig.Output }}
+    assert_match {{ quote $.TestConfig.Output }}, shell_output({{ quote . }})
+    {{- else }}
+    system {{ quote . }}
+    {{- end }}
+    {{- end }}
+    {{- range .TestConfig.Binaries }}
+    {{- $cmd := printf "#{bin}/%s" .Binary }}
+    {{- with .Args }}{{ $cmd = printf "%s %s" $cmd . }}{{ end }}
+    {{- if .Output }}
+    assert_match {{ quote .Output }}, shell_output({{ dquote $cmd }})
+    {{- else }}
+    system {{ dquote $cmd }}
+    {{- end }}
+    {{- end }}
+    {{- with .HelpTest }}
+    system {{ dquote (printf "#{bin}/%s" .) }}, {{ quote "--help" }}
+    {{- end }}
+    {{- range .Assertions }}
+    {{ . }}
+    {{- end }}
     {{- range $index, $element := .Tests }}
     {{ . -}}
     {{- end }}
   end
   {{- end }}
 end
-`
+{{ define "url_options" -}}
+{{- if .DownloadStrategy }}, using: {{ .DownloadStrategy }}{{- else if .URLUsing }}, using: :{{ .URLUsing }}{{- end }}
+{{- with .URLHeaders }}, headers: [{{ range $i, $header := . }}{{ if $i }}, {{ end }}{{ quote $header }}{{ end }}]{{- end }}
+{{- end }}
+{{- define "dependency" -}}
+{{ if .UsesFromMacOS }}uses_from_macos{{ else }}depends_on{{ end }} {{ quote .Name }}
+{{- if .Type }} => :{{ .Type }}{{- else if .Version }} => { version: {{ quote .Version }} }{{- end }}
+{{- with .Since }}, since: :{{ . }}{{- end }}
+{{- with .Comment }} # {{ . }}{{- end }}
+{{- end }}`
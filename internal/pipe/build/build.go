@@ -172,7 +172,10 @@ func buildOptionsForTarget(ctx *context.Context, build config.Build, target stri
 	var gomips string
 	var goarm string
 	var goamd64 string
-	if strings.HasPrefix(goarch, "arm") && len(parts) > 2 {
+	var goarm64 string
+	if goarch == "arm64" && len(parts) > 2 {
+		goarm64 = parts[2]
+	} else if strings.HasPrefix(goarch, "arm") && len(parts) > 2 {
 		goarm = parts[2]
 	}
 	if strings.HasPrefix(goarch, "mips") && len(parts) > 2 {
@@ -190,6 +193,7 @@ func buildOptionsForTarget(ctx *context.Context, build config.Build, target stri
 		Goarm:   goarm,
 		Gomips:  gomips,
 		Goamd64: goamd64,
+		Goarm64: goarm64,
 	}
 
 	binary, err := tmpl.New(ctx).WithBuildOptions(buildOpts).Apply(build.Binary)
@@ -683,6 +683,24 @@ func TestBuildOptionsForTarget(t *testing.T) {
 				Goamd64: "v3",
 			},
 		},
+		{
+			name: "with goarm64",
+			build: config.Build{
+				ID:     "testid",
+				Binary: "testbinary",
+				Targets: []string{
+					"linux_arm64_v9.0",
+				},
+			},
+			expectedOpts: &api.Options{
+				Name:    "testbinary",
+				Path:    filepath.Join(tmpDir, "testid_linux_arm64_v9.0", "testbinary"),
+				Target:  "linux_arm64_v9.0",
+				Goos:    "linux",
+				Goarch:  "arm64",
+				Goarm64: "v9.0",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -60,6 +60,9 @@ func (Pipe) Default(ctx *context.Context) error {
 			krew.Repository = krew.Index
 			deprecate.Notice(ctx, "krews.index")
 		}
+		if krew.Repository.CommitBranch != "" {
+			return fmt.Errorf("krews[%d]: repository.commit_branch is only supported by brews", i)
+		}
 	}
 
 	return nil
@@ -330,7 +333,7 @@ func doPublish(ctx *context.Context, manifest *artifact.Artifact, cl client.Clie
 			CreateFile(ctx, author, repo, content, gpath, msg)
 	}
 
-	cl, err = client.NewIfToken(ctx, cl, cfg.Repository.Token)
+	cl, err = client.NewForRepository(ctx, cl, cfg.Repository, false)
 	if err != nil {
 		return err
 	}
@@ -353,7 +356,12 @@ func doPublish(ctx *context.Context, manifest *artifact.Artifact, cl client.Clie
 		Name:   cfg.Repository.PullRequest.Base.Name,
 		Owner:  cfg.Repository.PullRequest.Base.Owner,
 		Branch: cfg.Repository.PullRequest.Base.Branch,
-	}, repo, msg, cfg.Repository.PullRequest.Draft)
+	}, repo, msg, client.PullRequestOptions{
+		Draft:     cfg.Repository.PullRequest.Draft,
+		Milestone: cfg.Repository.PullRequest.Milestone,
+		Reviewers: cfg.Repository.PullRequest.Reviewers,
+		Labels:    cfg.Repository.PullRequest.Labels,
+	})
 }
 
 func buildManifestPath(folder, filename string) string {
@@ -965,6 +965,15 @@ func TestDefault(t *testing.T) {
 	require.True(t, ctx.Deprecated)
 }
 
+func TestDefaultCommitBranch(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		Krews: []config.Krew{{
+			Repository: config.RepoRef{Name: "foo", CommitBranch: "release"},
+		}},
+	})
+	require.EqualError(t, Pipe{}.Default(ctx), "krews[0]: repository.commit_branch is only supported by brews")
+}
+
 func TestGHFolder(t *testing.T) {
 	require.Equal(t, "bar.yaml", buildManifestPath("", "bar.yaml"))
 	require.Equal(t, "fooo/bar.yaml", buildManifestPath("fooo", "bar.yaml"))
@@ -82,6 +82,9 @@ func (Pipe) Default(ctx *context.Context) error {
 		if nix.Goamd64 == "" {
 			nix.Goamd64 = "v1"
 		}
+		if nix.Repository.CommitBranch != "" {
+			return fmt.Errorf("nix[%d]: repository.commit_branch is only supported by brews", i)
+		}
 	}
 
 	return nil
@@ -370,7 +373,7 @@ func doPublish(ctx *context.Context, prefetcher shaPrefetcher, cl client.Client,
 			CreateFile(ctx, author, repo, []byte(content), gpath, msg)
 	}
 
-	cl, err = client.NewIfToken(ctx, cl, nix.Repository.Token)
+	cl, err = client.NewForRepository(ctx, cl, nix.Repository, false)
 	if err != nil {
 		return err
 	}
@@ -393,7 +396,12 @@ func doPublish(ctx *context.Context, prefetcher shaPrefetcher, cl client.Client,
 		Name:   nix.Repository.PullRequest.Base.Name,
 		Owner:  nix.Repository.PullRequest.Base.Owner,
 		Branch: nix.Repository.PullRequest.Base.Branch,
-	}, repo, msg, nix.Repository.PullRequest.Draft)
+	}, repo, msg, client.PullRequestOptions{
+		Draft:     nix.Repository.PullRequest.Draft,
+		Milestone: nix.Repository.PullRequest.Milestone,
+		Reviewers: nix.Repository.PullRequest.Reviewers,
+		Labels:    nix.Repository.PullRequest.Labels,
+	})
 }
 
 func doBuildPkg(ctx *context.Context, data templateData) (string, error) {
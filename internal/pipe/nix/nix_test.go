@@ -543,6 +543,15 @@ func TestRunPipe(t *testing.T) {
 	}
 }
 
+func TestDefaultCommitBranch(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		Nix: []config.Nix{{
+			Repository: config.RepoRef{Name: "foo", CommitBranch: "release"},
+		}},
+	})
+	require.EqualError(t, Pipe{}.Default(ctx), "nix[0]: repository.commit_branch is only supported by brews")
+}
+
 func TestErrNoArchivesFound(t *testing.T) {
 	require.EqualError(t, errNoArchivesFound{
 		goamd64: "v1",
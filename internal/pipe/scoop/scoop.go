@@ -106,6 +106,9 @@ func (Pipe) Default(ctx *context.Context) error {
 			scoop.Repository = scoop.Bucket
 			deprecate.Notice(ctx, "scoops.bucket")
 		}
+		if scoop.Repository.CommitBranch != "" {
+			return fmt.Errorf("scoops[%d]: repository.commit_branch is only supported by brews", i)
+		}
 	}
 	return nil
 }
@@ -252,7 +255,7 @@ func doPublish(ctx *context.Context, manifest *artifact.Artifact, cl client.Clie
 			CreateFile(ctx, author, repo, content, gpath, commitMessage)
 	}
 
-	cl, err = client.NewIfToken(ctx, cl, scoop.Repository.Token)
+	cl, err = client.NewForRepository(ctx, cl, scoop.Repository, false)
 	if err != nil {
 		return err
 	}
@@ -275,7 +278,12 @@ func doPublish(ctx *context.Context, manifest *artifact.Artifact, cl client.Clie
 		Name:   scoop.Repository.PullRequest.Base.Name,
 		Owner:  scoop.Repository.PullRequest.Base.Owner,
 		Branch: scoop.Repository.PullRequest.Base.Branch,
-	}, repo, commitMessage, scoop.Repository.PullRequest.Draft)
+	}, repo, commitMessage, client.PullRequestOptions{
+		Draft:     scoop.Repository.PullRequest.Draft,
+		Milestone: scoop.Repository.PullRequest.Milestone,
+		Reviewers: scoop.Repository.PullRequest.Reviewers,
+		Labels:    scoop.Repository.PullRequest.Labels,
+	})
 }
 
 // Manifest represents a scoop.sh App Manifest.
@@ -48,6 +48,15 @@ func TestDefault(t *testing.T) {
 	require.NotEmpty(t, ctx.Config.Scoops[0].CommitMessageTemplate)
 }
 
+func TestDefaultCommitBranch(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		Scoops: []config.Scoop{{
+			Repository: config.RepoRef{Name: "foo", CommitBranch: "release"},
+		}},
+	})
+	require.EqualError(t, Pipe{}.Default(ctx), "scoops[0]: repository.commit_branch is only supported by brews")
+}
+
 func TestDefaultDeprecated(t *testing.T) {
 	testlib.Mktmp(t)
 
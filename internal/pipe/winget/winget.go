@@ -64,6 +64,9 @@ func (Pipe) Default(ctx *context.Context) error {
 		if winget.Goamd64 == "" {
 			winget.Goamd64 = "v1"
 		}
+		if winget.Repository.CommitBranch != "" {
+			return fmt.Errorf("winget[%d]: repository.commit_branch is only supported by brews", i)
+		}
 	}
 
 	return nil
@@ -346,7 +349,7 @@ func doPublish(ctx *context.Context, cl client.Client, wingets []*artifact.Artif
 			CreateFiles(ctx, author, repo, msg, files)
 	}
 
-	cl, err = client.NewIfToken(ctx, cl, winget.Repository.Token)
+	cl, err = client.NewForRepository(ctx, cl, winget.Repository, false)
 	if err != nil {
 		return err
 	}
@@ -379,7 +382,12 @@ func doPublish(ctx *context.Context, cl client.Client, wingets []*artifact.Artif
 		Name:   winget.Repository.PullRequest.Base.Name,
 		Owner:  winget.Repository.PullRequest.Base.Owner,
 		Branch: winget.Repository.PullRequest.Base.Branch,
-	}, repo, msg, winget.Repository.PullRequest.Draft)
+	}, repo, msg, client.PullRequestOptions{
+		Draft:     winget.Repository.PullRequest.Draft,
+		Milestone: winget.Repository.PullRequest.Milestone,
+		Reviewers: winget.Repository.PullRequest.Reviewers,
+		Labels:    winget.Repository.PullRequest.Labels,
+	})
 }
 
 func langserverLineFor(tp artifact.Type) string {
@@ -702,3 +702,12 @@ func TestDefault(t *testing.T) {
 	require.NotEmpty(t, winget.CommitMessageTemplate)
 	require.Equal(t, "foo", winget.Name)
 }
+
+func TestDefaultCommitBranch(t *testing.T) {
+	ctx := testctx.NewWithCfg(config.Project{
+		Winget: []config.Winget{{
+			Repository: config.RepoRef{Name: "foo", CommitBranch: "release"},
+		}},
+	})
+	require.EqualError(t, Pipe{}.Default(ctx), "winget[0]: repository.commit_branch is only supported by brews")
+}
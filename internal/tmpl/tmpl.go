@@ -64,6 +64,7 @@ const (
 	// artifact-only keys.
 	osKey        = "Os"
 	amd64        = "Amd64"
+	arm64        = "Arm64"
 	arch         = "Arch"
 	arm          = "Arm"
 	mips         = "Mips"
@@ -163,6 +164,7 @@ func (t *Template) WithArtifact(a *artifact.Artifact) *Template {
 	t.fields[arm] = a.Goarm
 	t.fields[mips] = a.Gomips
 	t.fields[amd64] = a.Goamd64
+	t.fields[arm64] = a.Goarm64
 	t.fields[binary] = artifact.ExtraOr(*a, binary, t.fields[projectName].(string))
 	t.fields[artifactName] = a.Name
 	t.fields[artifactExt] = artifact.ExtraOr(*a, artifact.ExtraExt, "")
@@ -0,0 +1,47 @@
+// Package brew renders Homebrew formulas, so projects extending GoReleaser
+// are able to preview and customize them.
+package brew
+
+import (
+	"github.com/goreleaser/goreleaser/internal/artifact"
+	"github.com/goreleaser/goreleaser/internal/pipe/brew"
+	"github.com/goreleaser/goreleaser/pkg/config"
+	"github.com/goreleaser/goreleaser/pkg/context"
+)
+
+// FormulaData is the data used to render a formula, which hooks may modify.
+type FormulaData = brew.FormulaData
+
+// Hook can modify the formula data right before the formula is rendered.
+type Hook = brew.Hook
+
+// RegisterHook registers a hook to run on every formula, in registration
+// order, after its data is built and before it is rendered, both when
+// publishing and in BuildFormula.
+//
+// Hooks run once for every brews entry and may run more than once in the same
+// release, so they must be deterministic: the same context and data must
+// always produce the same changes.
+// They must only modify the given data: they must not modify the context nor
+// keep references to the data after returning.
+// Hooks may run concurrently, so any state they share must be synchronized.
+// An error returned by a hook fails the formula generation.
+func RegisterHook(hook Hook) {
+	brew.RegisterHook(hook)
+}
+
+// URLTemplater provides the release URL template used to fill the formula
+// download URLs when the configuration does not set a url_template.
+type URLTemplater interface {
+	ReleaseURLTemplate(ctx *context.Context) (string, error)
+}
+
+// BuildFormula renders the formula of the given configuration and artifacts,
+// without writing it nor publishing it, e.g. with ctx.Artifacts.List().
+// The configuration is used as is, so it should have its defaults set already,
+// and its name and repository templates are not applied.
+// The format command, if any, runs on a temporary copy of the formula, so the
+// result matches the published formula.
+func BuildFormula(ctx *context.Context, cfg config.Homebrew, cl URLTemplater, artifacts []*artifact.Artifact) (string, error) {
+	return brew.BuildFormula(ctx, cfg, cl, artifacts)
+}
@@ -0,0 +1,83 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/goreleaser/internal/artifact"
+	"github.com/goreleaser/goreleaser/internal/testctx"
+	"github.com/goreleaser/goreleaser/pkg/config"
+	"github.com/goreleaser/goreleaser/pkg/context"
+	"github.com/stretchr/testify/require"
+)
+
+type urlTemplater struct{}
+
+func (urlTemplater) ReleaseURLTemplate(*context.Context) (string, error) {
+	return "https://example.com/{{ .Tag }}/{{ .ArtifactName }}", nil
+}
+
+func TestBuildFormula(t *testing.T) {
+	ctx := testctx.New(testctx.WithVersion("1.2.1"), testctx.WithCurrentTag("v1.2.1"))
+	path := filepath.Join(t.TempDir(), "foo.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("foo"), 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "foo.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"foo"},
+		},
+	})
+
+	formula, err := BuildFormula(ctx, config.Homebrew{
+		Name:          "foo",
+		Description:   "A foo formula",
+		FormatCommand: `sh -c 'echo "# formatted" >> "$FORMULA_PATH"'`,
+	}, urlTemplater{}, ctx.Artifacts.List())
+	require.NoError(t, err)
+	require.Contains(t, formula, "class Foo < Formula\n")
+	require.Contains(t, formula, `url "https://example.com/v1.2.1/foo.tar.gz"`)
+	require.Contains(t, formula, `bin.install "foo"`)
+	require.True(t, strings.HasSuffix(formula, "end\n# formatted\n"))
+}
+
+func TestRegisterHook(t *testing.T) {
+	ctx := testctx.New(testctx.WithVersion("1.2.1"), testctx.WithCurrentTag("v1.2.1"))
+	path := filepath.Join(t.TempDir(), "hooked.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("hooked"), 0o644))
+	ctx.Artifacts.Add(&artifact.Artifact{
+		Name:   "hooked.tar.gz",
+		Path:   path,
+		Goos:   "darwin",
+		Goarch: "arm64",
+		Type:   artifact.UploadableArchive,
+		Extra: map[string]interface{}{
+			artifact.ExtraFormat:   "tar.gz",
+			artifact.ExtraBinaries: []string{"hooked"},
+		},
+	})
+
+	// hooks are global, so only change the formula of this test
+	RegisterHook(func(_ *context.Context, data *FormulaData) error {
+		if data.Name != "Hooked" {
+			return nil
+		}
+		data.Desc = "changed by a hook"
+		data.MacOSPackages[0].Install = append(data.MacOSPackages[0].Install, `man1.install "hooked.1"`)
+		return nil
+	})
+
+	formula, err := BuildFormula(ctx, config.Homebrew{
+		Name:        "hooked",
+		Description: "A hooked formula",
+	}, urlTemplater{}, ctx.Artifacts.List())
+	require.NoError(t, err)
+	require.Contains(t, formula, `desc "changed by a hook"`)
+	require.Contains(t, formula, `man1.install "hooked.1"`)
+}
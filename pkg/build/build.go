@@ -37,6 +37,7 @@ type Options struct {
 	Goamd64 string
 	Goarm   string
 	Gomips  string
+	Goarm64 string
 }
 
 // Builder defines a builder.
@@ -4,11 +4,15 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"text/template/parse"
 	"time"
 
 	"github.com/caarlos0/log"
@@ -86,6 +90,14 @@ type RepoRef struct {
 	Token  string `yaml:"token,omitempty" json:"token,omitempty"`
 	Branch string `yaml:"branch,omitempty" json:"branch,omitempty"`
 
+	// TokenEnv is the name of the environment variable holding the token,
+	// used if Token is not set.
+	TokenEnv string `yaml:"token_env,omitempty" json:"token_env,omitempty"`
+
+	// CommitBranch is the branch to commit to, instead of Branch.
+	// Only supported by brews.
+	CommitBranch string `yaml:"commit_branch,omitempty" json:"commit_branch,omitempty"`
+
 	Git         GitRepoRef  `yaml:"git,omitempty" json:"git,omitempty"`
 	PullRequest PullRequest `yaml:"pull_request,omitempty" json:"pull_request,omitempty"`
 }
@@ -94,6 +106,7 @@ type GitRepoRef struct {
 	URL        string `yaml:"url,omitempty" json:"url,omitempty"`
 	SSHCommand string `yaml:"ssh_command,omitempty" json:"ssh_command,omitempty"`
 	PrivateKey string `yaml:"private_key,omitempty" json:"private_key,omitempty"`
+	Remote     string `yaml:"remote,omitempty" json:"remote,omitempty"`
 }
 
 type PullRequestBase struct {
@@ -140,10 +153,28 @@ func (a PullRequestBase) JSONSchema() *jsonschema.Schema {
 	}
 }
 
+// opensPullRequestToItself returns true if pull requests are enabled, and
+// would be opened from the repository branch into itself.
+func (r RepoRef) opensPullRequestToItself() bool {
+	pr := r.PullRequest
+	branch := r.Branch
+	if r.CommitBranch != "" {
+		branch = r.CommitBranch
+	}
+	if !pr.Enabled || branch == "" || pr.Base.Branch != branch {
+		return false
+	}
+	return (pr.Base.Owner == "" || pr.Base.Owner == r.Owner) &&
+		(pr.Base.Name == "" || pr.Base.Name == r.Name)
+}
+
 type PullRequest struct {
-	Enabled bool            `yaml:"enabled,omitempty" json:"enabled,omitempty"`
-	Base    PullRequestBase `yaml:"base,omitempty" json:"base,omitempty"`
-	Draft   bool            `yaml:"draft,omitempty" json:"draft,omitempty"`
+	Enabled   bool            `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Base      PullRequestBase `yaml:"base,omitempty" json:"base,omitempty"`
+	Draft     bool            `yaml:"draft,omitempty" json:"draft,omitempty"`
+	Milestone string          `yaml:"milestone,omitempty" json:"milestone,omitempty"`
+	Reviewers []string        `yaml:"reviewers,omitempty" json:"reviewers,omitempty"`
+	Labels    []string        `yaml:"labels,omitempty" json:"labels,omitempty"`
 }
 
 // HomebrewDependency represents Homebrew dependency.
@@ -151,6 +182,13 @@ type HomebrewDependency struct {
 	Name    string `yaml:"name,omitempty" json:"name,omitempty"`
 	Type    string `yaml:"type,omitempty" json:"type,omitempty"`
 	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+	Comment string `yaml:"comment,omitempty" json:"comment,omitempty"`
+	OS      string `yaml:"os,omitempty" json:"os,omitempty" jsonschema:"enum=macos,enum=linux"`
+
+	// UsesFromMacOS renders the dependency as uses_from_macos, optionally
+	// bound to the macOS versions since the given codename.
+	UsesFromMacOS bool   `yaml:"uses_from_macos,omitempty" json:"uses_from_macos,omitempty"`
+	Since         string `yaml:"since,omitempty" json:"since,omitempty" jsonschema:"enum=sonoma,enum=ventura,enum=monterey,enum=big_sur,enum=catalina,enum=mojave,enum=high_sierra,enum=sierra,enum=el_capitan"`
 }
 
 // type alias to prevent stack overflowing in the custom unmarshaler.
@@ -169,8 +207,7 @@ func (a *HomebrewDependency) UnmarshalYAML(unmarshal func(interface{}) error) er
 		return err
 	}
 
-	a.Name = dep.Name
-	a.Type = dep.Type
+	*a = HomebrewDependency(dep)
 
 	return nil
 }
@@ -190,6 +227,180 @@ func (a HomebrewDependency) JSONSchema() *jsonschema.Schema {
 	}
 }
 
+// HomebrewRenamedBinary represents a binary that was renamed or removed from
+// a Homebrew formula.
+type HomebrewRenamedBinary struct {
+	Old string `yaml:"old" json:"old"`
+	New string `yaml:"new,omitempty" json:"new,omitempty"`
+}
+
+// HomebrewShareInstall represents a path of the archive to install into the
+// formula share folder.
+type HomebrewShareInstall struct {
+	Src string `yaml:"src" json:"src"`
+	Dst string `yaml:"dst,omitempty" json:"dst,omitempty"`
+}
+
+// HomebrewCompletions configures the shell completions generated by running
+// one of the installed binaries.
+type HomebrewCompletions struct {
+	Binary string   `yaml:"binary,omitempty" json:"binary,omitempty"`
+	Args   string   `yaml:"args,omitempty" json:"args,omitempty"`
+	Shells []string `yaml:"shells,omitempty" json:"shells,omitempty" jsonschema:"enum=bash,enum=zsh,enum=fish,enum=pwsh"`
+}
+
+// HomebrewChmod represents a chmod of an installed binary.
+type HomebrewChmod struct {
+	Path string `yaml:"path" json:"path"`
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+}
+
+// HomebrewDeprecate configures the deprecation of a Homebrew formula.
+type HomebrewDeprecate struct {
+	RenamedTo string `yaml:"renamed_to,omitempty" json:"renamed_to,omitempty"`
+	Date      string `yaml:"date,omitempty" json:"date,omitempty"`
+	Reason    string `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// HomebrewDisable configures the disabling of a Homebrew formula.
+type HomebrewDisable struct {
+	Date   string `yaml:"date,omitempty" json:"date,omitempty"`
+	Reason string `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// HomebrewLivecheck configures the livecheck block of a Homebrew formula,
+// used by Homebrew to find new versions.
+type HomebrewLivecheck struct {
+	URL      string `yaml:"url,omitempty" json:"url,omitempty"`
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+	Regex    string `yaml:"regex,omitempty" json:"regex,omitempty"`
+}
+
+// HomebrewHead configures the head block of a Homebrew formula, used by
+// `brew install --HEAD`.
+type HomebrewHead struct {
+	URL          string               `yaml:"url,omitempty" json:"url,omitempty"`
+	Dependencies []HomebrewDependency `yaml:"dependencies,omitempty" json:"dependencies,omitempty"`
+	Install      string               `yaml:"install,omitempty" json:"install,omitempty"`
+}
+
+// HomebrewResource is an additional download of a Homebrew formula, e.g. a
+// bundled dependency, rendered as a resource block.
+type HomebrewResource struct {
+	Name   string `yaml:"name,omitempty" json:"name,omitempty"`
+	URL    string `yaml:"url,omitempty" json:"url,omitempty"`
+	SHA256 string `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+}
+
+// HomebrewBottle is the bottle block of a Homebrew formula, for taps shipping
+// prebuilt bottles.
+type HomebrewBottle struct {
+	RootURL   string                   `yaml:"root_url,omitempty" json:"root_url,omitempty"`
+	Rebuild   int                      `yaml:"rebuild,omitempty" json:"rebuild,omitempty"`
+	Checksums []HomebrewBottleChecksum `yaml:"checksums,omitempty" json:"checksums,omitempty"`
+}
+
+// HomebrewBottleChecksum is the checksum of a bottle for a given platform tag.
+type HomebrewBottleChecksum struct {
+	Tag    string `yaml:"tag,omitempty" json:"tag,omitempty"`
+	Cellar string `yaml:"cellar,omitempty" json:"cellar,omitempty"`
+	SHA256 string `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+}
+
+// HomebrewService configures the service block of a Homebrew formula.
+//
+// It can also be set as a string, which is then used as the contents of the
+// block, as is. That form is deprecated.
+type HomebrewService struct {
+	Run                  StringArray       `yaml:"run,omitempty" json:"run,omitempty"`
+	RunType              string            `yaml:"run_type,omitempty" json:"run_type,omitempty" jsonschema:"enum=immediate,enum=interval,enum=cron"`
+	KeepAlive            bool              `yaml:"keep_alive,omitempty" json:"keep_alive,omitempty"`
+	WorkingDir           string            `yaml:"working_dir,omitempty" json:"working_dir,omitempty"`
+	EnvironmentVariables map[string]string `yaml:"environment_variables,omitempty" json:"environment_variables,omitempty"`
+
+	// Deprecated: use the structured fields instead.
+	Raw string `yaml:"-" json:"-"`
+}
+
+// type alias to prevent stack overflowing in the custom unmarshaler.
+type homebrewService HomebrewService
+
+// UnmarshalYAML is a custom unmarshaler that accepts the service block both
+// as a string and as a struct.
+func (a *HomebrewService) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err == nil {
+		a.Raw = str
+		return nil
+	}
+
+	var service homebrewService
+	if err := unmarshal(&service); err != nil {
+		return err
+	}
+
+	*a = HomebrewService(service)
+
+	return nil
+}
+
+// MarshalYAML is a custom marshaler that keeps the deprecated string form.
+func (a HomebrewService) MarshalYAML() (interface{}, error) {
+	if a.Raw != "" {
+		return a.Raw, nil
+	}
+	return homebrewService(a), nil
+}
+
+func (a HomebrewService) JSONSchema() *jsonschema.Schema {
+	reflector := jsonschema.Reflector{
+		ExpandedStruct: true,
+	}
+	schema := reflector.Reflect(&homebrewService{})
+	return &jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{
+			{
+				Type: "string",
+			},
+			schema,
+		},
+	}
+}
+
+// HomebrewTestConfig is a structured Homebrew formula test: fixtures are
+// written to the test path before running the command.
+type HomebrewTestConfig struct {
+	Fixtures []HomebrewTestFixture `yaml:"fixtures,omitempty" json:"fixtures,omitempty"`
+	Command  string                `yaml:"command,omitempty" json:"command,omitempty"`
+	Output   string                `yaml:"output,omitempty" json:"output,omitempty"`
+	Binaries []HomebrewBinaryTest  `yaml:"binaries,omitempty" json:"binaries,omitempty"`
+}
+
+// HomebrewBinaryTest is a test of one of the binaries installed by a Homebrew
+// formula: it runs the binary with the given args, optionally asserting its
+// output.
+type HomebrewBinaryTest struct {
+	Binary string `yaml:"binary" json:"binary"`
+	Args   string `yaml:"args,omitempty" json:"args,omitempty"`
+	Output string `yaml:"output,omitempty" json:"output,omitempty"`
+}
+
+// HomebrewTest is a structured assertion of a Homebrew formula test: it runs
+// one of the installed binaries with the given arguments, checking its output
+// matches, if set.
+type HomebrewTest struct {
+	Binary  string `yaml:"binary,omitempty" json:"binary,omitempty"`
+	Command string `yaml:"command,omitempty" json:"command,omitempty"`
+	Match   string `yaml:"match,omitempty" json:"match,omitempty"`
+}
+
+// HomebrewTestFixture is a file written to the test path of a Homebrew
+// formula test.
+type HomebrewTestFixture struct {
+	Path    string `yaml:"path" json:"path"`
+	Content string `yaml:"content,omitempty" json:"content,omitempty"`
+}
+
 type AUR struct {
 	Name                  string       `yaml:"name,omitempty" json:"name,omitempty"`
 	IDs                   []string     `yaml:"ids,omitempty" json:"ids,omitempty"`
@@ -217,30 +428,104 @@ type AUR struct {
 
 // Homebrew contains the brew section.
 type Homebrew struct {
-	Name                  string               `yaml:"name,omitempty" json:"name,omitempty"`
-	Repository            RepoRef              `yaml:"repository,omitempty" json:"repository,omitempty"`
-	CommitAuthor          CommitAuthor         `yaml:"commit_author,omitempty" json:"commit_author,omitempty"`
-	CommitMessageTemplate string               `yaml:"commit_msg_template,omitempty" json:"commit_msg_template,omitempty"`
-	Folder                string               `yaml:"folder,omitempty" json:"folder,omitempty"`
-	Caveats               string               `yaml:"caveats,omitempty" json:"caveats,omitempty"`
-	Install               string               `yaml:"install,omitempty" json:"install,omitempty"`
-	ExtraInstall          string               `yaml:"extra_install,omitempty" json:"extra_install,omitempty"`
-	PostInstall           string               `yaml:"post_install,omitempty" json:"post_install,omitempty"`
-	Dependencies          []HomebrewDependency `yaml:"dependencies,omitempty" json:"dependencies,omitempty"`
-	Test                  string               `yaml:"test,omitempty" json:"test,omitempty"`
-	Conflicts             []string             `yaml:"conflicts,omitempty" json:"conflicts,omitempty"`
-	Description           string               `yaml:"description,omitempty" json:"description,omitempty"`
-	Homepage              string               `yaml:"homepage,omitempty" json:"homepage,omitempty"`
-	License               string               `yaml:"license,omitempty" json:"license,omitempty"`
-	SkipUpload            string               `yaml:"skip_upload,omitempty" json:"skip_upload,omitempty" jsonschema:"oneof_type=string;boolean"`
-	DownloadStrategy      string               `yaml:"download_strategy,omitempty" json:"download_strategy,omitempty"`
-	URLTemplate           string               `yaml:"url_template,omitempty" json:"url_template,omitempty"`
-	CustomRequire         string               `yaml:"custom_require,omitempty" json:"custom_require,omitempty"`
-	CustomBlock           string               `yaml:"custom_block,omitempty" json:"custom_block,omitempty"`
-	IDs                   []string             `yaml:"ids,omitempty" json:"ids,omitempty"`
-	Goarm                 string               `yaml:"goarm,omitempty" json:"goarm,omitempty" jsonschema:"oneof_type=string;integer"`
-	Goamd64               string               `yaml:"goamd64,omitempty" json:"goamd64,omitempty"`
-	Service               string               `yaml:"service,omitempty" json:"service,omitempty"`
+	Name                      string                  `yaml:"name,omitempty" json:"name,omitempty"`
+	Repository                RepoRef                 `yaml:"repository,omitempty" json:"repository,omitempty"`
+	Repositories              []RepoRef               `yaml:"repositories,omitempty" json:"repositories,omitempty"`
+	CommitAuthor              CommitAuthor            `yaml:"commit_author,omitempty" json:"commit_author,omitempty"`
+	CommitMessageTemplate     string                  `yaml:"commit_msg_template,omitempty" json:"commit_msg_template,omitempty"`
+	CommitBodyTemplate        string                  `yaml:"commit_body_template,omitempty" json:"commit_body_template,omitempty"`
+	Folder                    string                  `yaml:"folder,omitempty" json:"folder,omitempty"`
+	Caveats                   string                  `yaml:"caveats,omitempty" json:"caveats,omitempty"`
+	Install                   string                  `yaml:"install,omitempty" json:"install,omitempty"`
+	ExtraInstall              string                  `yaml:"extra_install,omitempty" json:"extra_install,omitempty"`
+	PreInstall                string                  `yaml:"pre_install,omitempty" json:"pre_install,omitempty"`
+	PostInstall               string                  `yaml:"post_install,omitempty" json:"post_install,omitempty"`
+	Dependencies              []HomebrewDependency    `yaml:"dependencies,omitempty" json:"dependencies,omitempty"`
+	Test                      string                  `yaml:"test,omitempty" json:"test,omitempty"`
+	Conflicts                 []string                `yaml:"conflicts,omitempty" json:"conflicts,omitempty"`
+	OldNames                  []string                `yaml:"old_names,omitempty" json:"old_names,omitempty"`
+	Description               string                  `yaml:"description,omitempty" json:"description,omitempty"`
+	Homepage                  string                  `yaml:"homepage,omitempty" json:"homepage,omitempty"`
+	License                   string                  `yaml:"license,omitempty" json:"license,omitempty"`
+	StrictLicense             bool                    `yaml:"strict_license,omitempty" json:"strict_license,omitempty"`
+	SkipUpload                string                  `yaml:"skip_upload,omitempty" json:"skip_upload,omitempty" jsonschema:"oneof_type=string;boolean"`
+	SkipUploadMacOS           string                  `yaml:"skip_upload_macos,omitempty" json:"skip_upload_macos,omitempty" jsonschema:"oneof_type=string;boolean"`
+	SkipUploadLinux           string                  `yaml:"skip_upload_linux,omitempty" json:"skip_upload_linux,omitempty" jsonschema:"oneof_type=string;boolean"`
+	DownloadStrategy          string                  `yaml:"download_strategy,omitempty" json:"download_strategy,omitempty"`
+	URLUsing                  string                  `yaml:"url_using,omitempty" json:"url_using,omitempty"`
+	URLHeaders                []string                `yaml:"url_headers,omitempty" json:"url_headers,omitempty"`
+	URLTemplate               string                  `yaml:"url_template,omitempty" json:"url_template,omitempty"`
+	CustomRequire             string                  `yaml:"custom_require,omitempty" json:"custom_require,omitempty"`
+	CustomBlock               string                  `yaml:"custom_block,omitempty" json:"custom_block,omitempty"`
+	IDs                       []string                `yaml:"ids,omitempty" json:"ids,omitempty"`
+	Goarm                     string                  `yaml:"goarm,omitempty" json:"goarm,omitempty" jsonschema:"oneof_type=string;integer"`
+	Goamd64                   string                  `yaml:"goamd64,omitempty" json:"goamd64,omitempty"`
+	Goamd64Fallback           bool                    `yaml:"goamd64_fallback,omitempty" json:"goamd64_fallback,omitempty"`
+	Goarm64                   string                  `yaml:"goarm64,omitempty" json:"goarm64,omitempty"`
+	Service                   HomebrewService         `yaml:"service,omitempty" json:"service,omitempty"`
+	QuoteStyle                string                  `yaml:"quote_style,omitempty" json:"quote_style,omitempty" jsonschema:"enum=double,enum=single,default=double"`
+	FrozenStringLiteral       *bool                   `yaml:"frozen_string_literal,omitempty" json:"frozen_string_literal,omitempty" jsonschema:"default=true"`
+	FormatPriority            []string                `yaml:"format_priority,omitempty" json:"format_priority,omitempty"`
+	PreferIDs                 []string                `yaml:"prefer_ids,omitempty" json:"prefer_ids,omitempty"`
+	RenamedBinaries           []HomebrewRenamedBinary `yaml:"renamed_binaries,omitempty" json:"renamed_binaries,omitempty"`
+	ClassSuffix               string                  `yaml:"class_suffix,omitempty" json:"class_suffix,omitempty"`
+	StrictInstall             bool                    `yaml:"strict_install,omitempty" json:"strict_install,omitempty"`
+	PullRequestOnlyStable     bool                    `yaml:"pull_request_only_stable,omitempty" json:"pull_request_only_stable,omitempty"`
+	AllowMultipleArchives     bool                    `yaml:"allow_multiple_archives,omitempty" json:"allow_multiple_archives,omitempty"`
+	AllowPlaceholderChecksum  bool                    `yaml:"allow_placeholder_checksum,omitempty" json:"allow_placeholder_checksum,omitempty"`
+	InstallExclude            []string                `yaml:"install_exclude,omitempty" json:"install_exclude,omitempty"`
+	RequireArch               string                  `yaml:"require_arch,omitempty" json:"require_arch,omitempty" jsonschema:"enum=x86_64,enum=arm64,enum=intel,enum=arm"`
+	SharedStrategyFile        string                  `yaml:"shared_strategy_file,omitempty" json:"shared_strategy_file,omitempty"`
+	TestConfig                HomebrewTestConfig      `yaml:"test_config,omitempty" json:"test_config,omitempty"`
+	PreserveArtifactOrder     bool                    `yaml:"preserve_artifact_order,omitempty" json:"preserve_artifact_order,omitempty"`
+	Deprecate                 HomebrewDeprecate       `yaml:"deprecate,omitempty" json:"deprecate,omitempty"`
+	ExtraFiles                []ExtraFile             `yaml:"extra_files,omitempty" json:"extra_files,omitempty"`
+	LowercaseFileName         bool                    `yaml:"lowercase_file_name,omitempty" json:"lowercase_file_name,omitempty"`
+	VersionedName             bool                    `yaml:"versioned_name,omitempty" json:"versioned_name,omitempty"`
+	Chmod                     []HomebrewChmod         `yaml:"chmod,omitempty" json:"chmod,omitempty"`
+	Sorbet                    string                  `yaml:"sorbet,omitempty" json:"sorbet,omitempty" jsonschema:"enum=ignore,enum=false,enum=true,enum=strict,enum=strong"`
+	Preview                   bool                    `yaml:"preview,omitempty" json:"preview,omitempty"`
+	ShareInstall              []HomebrewShareInstall  `yaml:"share_install,omitempty" json:"share_install,omitempty"`
+	RequireOS                 string                  `yaml:"require_os,omitempty" json:"require_os,omitempty" jsonschema:"enum=macos,enum=linux"`
+	DryRun                    bool                    `yaml:"dry_run,omitempty" json:"dry_run,omitempty"`
+	PublishDelay              string                  `yaml:"publish_delay,omitempty" json:"publish_delay,omitempty"`
+	PublishConcurrency        int                     `yaml:"publish_concurrency,omitempty" json:"publish_concurrency,omitempty"`
+	ChecksumsFile             string                  `yaml:"checksums_file,omitempty" json:"checksums_file,omitempty"`
+	BottleManifest            string                  `yaml:"bottle_manifest,omitempty" json:"bottle_manifest,omitempty"`
+	Bottle                    HomebrewBottle          `yaml:"bottle,omitempty" json:"bottle,omitempty"`
+	ChecksumAlgorithm         string                  `yaml:"checksum_algorithm,omitempty" json:"checksum_algorithm,omitempty" jsonschema:"enum=sha256,enum=sha512,default=sha256"`
+	SourceBuild               bool                    `yaml:"source_build,omitempty" json:"source_build,omitempty"`
+	Compat                    string                  `yaml:"compat,omitempty" json:"compat,omitempty" jsonschema:"enum=3.0,enum=4.0"`
+	Livecheck                 HomebrewLivecheck       `yaml:"livecheck,omitempty" json:"livecheck,omitempty"`
+	InlinePatch               string                  `yaml:"inline_patch,omitempty" json:"inline_patch,omitempty"`
+	Head                      HomebrewHead            `yaml:"head,omitempty" json:"head,omitempty"`
+	Resources                 []HomebrewResource      `yaml:"resources,omitempty" json:"resources,omitempty"`
+	CompletionsFromExecutable HomebrewCompletions     `yaml:"completions_from_executable,omitempty" json:"completions_from_executable,omitempty"`
+	MacOSCaveats              string                  `yaml:"macos_caveats,omitempty" json:"macos_caveats,omitempty"`
+	LinuxCaveats              string                  `yaml:"linux_caveats,omitempty" json:"linux_caveats,omitempty"`
+	MacOSPostInstall          string                  `yaml:"macos_post_install,omitempty" json:"macos_post_install,omitempty"`
+	LinuxPostInstall          string                  `yaml:"linux_post_install,omitempty" json:"linux_post_install,omitempty"`
+	ExcludeNameRegex          string                  `yaml:"exclude_name_regex,omitempty" json:"exclude_name_regex,omitempty"`
+	ValidateFormula           bool                    `yaml:"validate_formula,omitempty" json:"validate_formula,omitempty"`
+	SkipIfUnchanged           bool                    `yaml:"skip_if_unchanged,omitempty" json:"skip_if_unchanged,omitempty"`
+	OnlyNewerVersion          bool                    `yaml:"only_newer_version,omitempty" json:"only_newer_version,omitempty"`
+	PostPublish               string                  `yaml:"post_publish,omitempty" json:"post_publish,omitempty"`
+	StripBuildMetadata        *bool                   `yaml:"strip_build_metadata,omitempty" json:"strip_build_metadata,omitempty" jsonschema:"default=true"`
+	StripComponents           int                     `yaml:"strip_components,omitempty" json:"strip_components,omitempty"`
+	IncludeCommitComment      bool                    `yaml:"include_commit_comment,omitempty" json:"include_commit_comment,omitempty"`
+	Template                  string                  `yaml:"template,omitempty" json:"template,omitempty"`
+	FromArchive               map[string][]string     `yaml:"from_archive,omitempty" json:"from_archive,omitempty"`
+	NoAutobump                string                  `yaml:"no_autobump,omitempty" json:"no_autobump,omitempty"`
+	ClassName                 string                  `yaml:"class_name,omitempty" json:"class_name,omitempty"`
+	HelpTest                  bool                    `yaml:"help_test,omitempty" json:"help_test,omitempty"`
+	Tests                     []HomebrewTest          `yaml:"tests,omitempty" json:"tests,omitempty"`
+	IndentWidth               int                     `yaml:"indent_width,omitempty" json:"indent_width,omitempty" jsonschema:"default=2"`
+	LineEnding                string                  `yaml:"line_ending,omitempty" json:"line_ending,omitempty" jsonschema:"enum=lf,enum=crlf,default=lf"`
+	SkipSanitize              bool                    `yaml:"skip_sanitize,omitempty" json:"skip_sanitize,omitempty"`
+	FormatCommand             string                  `yaml:"format_command,omitempty" json:"format_command,omitempty"`
+	Disable                   HomebrewDisable         `yaml:"disable,omitempty" json:"disable,omitempty"`
+	PublishRetries            int                     `yaml:"publish_retries,omitempty" json:"publish_retries,omitempty"`
+	PublishRetryDelay         string                  `yaml:"publish_retry_delay,omitempty" json:"publish_retry_delay,omitempty"`
 
 	// Deprecated: use Repository instead.
 	Tap RepoRef `yaml:"tap,omitempty" json:"tap,omitempty" jsonschema:"deprecated=true,description=use repository instead"`
@@ -249,6 +534,309 @@ type Homebrew struct {
 	Plist string `yaml:"plist,omitempty" json:"plist,omitempty" jsonschema:"deprecated=true,description=use service instead"`
 }
 
+var homebrewClassSuffixRe = regexp.MustCompile(`^[A-Za-z0-9_]*$`)
+
+var homebrewClassNameRe = regexp.MustCompile(`^[A-Z][A-Za-z0-9_]*$`)
+
+// HomebrewNoAutobumpReasons are the reasons Homebrew knows for no_autobump!,
+// rendered as symbols instead of strings.
+var HomebrewNoAutobumpReasons = []string{
+	"bumped_by_upstream",
+	"extract_plist",
+	"incompatible_version_format",
+	"latest_version",
+	"requires_manual_review",
+}
+
+var homebrewChmodModeRe = regexp.MustCompile(`^[0-7]{3,4}$`)
+
+var homebrewFormulaNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9@._+-]*$`)
+
+// Validate checks the Homebrew configuration, returning all the problems
+// found at once.
+//
+// It should be called after the defaults are set.
+func (h Homebrew) Validate() error {
+	var errs []error
+
+	if err := validateTemplate(h.Name); err != nil {
+		errs = append(errs, fmt.Errorf("name: invalid template: %w", err))
+	}
+	if h.Repository.Name != "" && h.Repository.Owner == "" && h.Repository.Git.URL == "" {
+		errs = append(errs, fmt.Errorf("repository.owner: required when repository.name is set"))
+	}
+	if h.Repository.opensPullRequestToItself() {
+		errs = append(errs, fmt.Errorf("repository.branch: can't be the same as repository.pull_request.base.branch, %q", h.Repository.Branch))
+	}
+	for i, repo := range h.Repositories {
+		if repo.Name == "" {
+			errs = append(errs, fmt.Errorf("repositories[%d].name: required", i))
+		}
+		if repo.Owner == "" && repo.Git.URL == "" {
+			errs = append(errs, fmt.Errorf("repositories[%d].owner: required", i))
+		}
+		if repo.opensPullRequestToItself() {
+			errs = append(errs, fmt.Errorf("repositories[%d].branch: can't be the same as repositories[%d].pull_request.base.branch, %q", i, i, repo.Branch))
+		}
+	}
+	if h.QuoteStyle != "double" && h.QuoteStyle != "single" {
+		errs = append(errs, fmt.Errorf("quote_style: invalid value %q, valid options are [double single]", h.QuoteStyle))
+	}
+	switch h.RequireArch {
+	case "", "x86_64", "arm64", "intel", "arm":
+	default:
+		errs = append(errs, fmt.Errorf("require_arch: invalid value %q, valid options are [x86_64 arm64 intel arm]", h.RequireArch))
+	}
+	switch h.RequireOS {
+	case "", "macos", "linux":
+	default:
+		errs = append(errs, fmt.Errorf("require_os: invalid value %q, valid options are [macos linux]", h.RequireOS))
+	}
+	switch h.Compat {
+	case "", "3.0", "4.0":
+	default:
+		errs = append(errs, fmt.Errorf("compat: invalid value %q, valid options are [3.0 4.0]", h.Compat))
+	}
+	switch h.ChecksumAlgorithm {
+	case "", "sha256", "sha512":
+	default:
+		errs = append(errs, fmt.Errorf("checksum_algorithm: invalid value %q, valid options are [sha256 sha512]", h.ChecksumAlgorithm))
+	}
+	if h.SourceBuild && strings.TrimSpace(h.Install) == "" {
+		errs = append(errs, fmt.Errorf("source_build: install is required"))
+	}
+	// binaries are only guessed from the artifacts when install is not set,
+	// so options tweaking the guessed binaries would be silently ignored.
+	if strings.TrimSpace(h.Install) != "" && len(h.InstallExclude) > 0 {
+		errs = append(errs, errors.New("install_exclude: can't be used together with install, binaries are not guessed when install is set"))
+	}
+	if strings.TrimSpace(h.Install) != "" && h.StrictInstall {
+		errs = append(errs, errors.New("strict_install: can't be used together with install, binaries are not guessed when install is set"))
+	}
+	if h.PublishDelay != "" {
+		if _, err := time.ParseDuration(h.PublishDelay); err != nil {
+			errs = append(errs, fmt.Errorf("publish_delay: invalid value %q, must be a duration, e.g. 5s", h.PublishDelay))
+		}
+	}
+	if h.PublishConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("publish_concurrency: invalid value %d, must not be negative", h.PublishConcurrency))
+	}
+	if h.PublishRetries < 0 {
+		errs = append(errs, fmt.Errorf("publish_retries: invalid value %d, must not be negative", h.PublishRetries))
+	}
+	if h.PublishRetryDelay != "" {
+		if _, err := time.ParseDuration(h.PublishRetryDelay); err != nil {
+			errs = append(errs, fmt.Errorf("publish_retry_delay: invalid value %q, must be a duration, e.g. 5s", h.PublishRetryDelay))
+		}
+	}
+	if h.URLUsing != "" && h.DownloadStrategy != "" {
+		errs = append(errs, errors.New("url_using: can't be used together with download_strategy"))
+	}
+	if h.SharedStrategyFile != "" && h.CustomRequire != "" {
+		errs = append(errs, fmt.Errorf("shared_strategy_file: can't be used together with custom_require"))
+	}
+	if len(h.TestConfig.Fixtures) > 0 && h.TestConfig.Command == "" && len(h.TestConfig.Binaries) == 0 {
+		errs = append(errs, fmt.Errorf("test_config.command: required when test_config.fixtures is set"))
+	}
+	for i, fixture := range h.TestConfig.Fixtures {
+		if fixture.Path == "" {
+			errs = append(errs, fmt.Errorf("test_config.fixtures[%d].path: required", i))
+		}
+	}
+	for i, test := range h.TestConfig.Binaries {
+		if test.Binary == "" {
+			errs = append(errs, fmt.Errorf("test_config.binaries[%d].binary: required", i))
+		}
+	}
+	switch h.Sorbet {
+	case "", "ignore", "false", "true", "strict", "strong":
+	default:
+		errs = append(errs, fmt.Errorf("sorbet: invalid value %q, valid options are [ignore false true strict strong]", h.Sorbet))
+	}
+	for i, share := range h.ShareInstall {
+		if share.Src == "" {
+			errs = append(errs, fmt.Errorf("share_install[%d].src: required", i))
+		}
+	}
+	for i, chmod := range h.Chmod {
+		if chmod.Path == "" {
+			errs = append(errs, fmt.Errorf("chmod[%d].path: required", i))
+		}
+		if chmod.Mode != "" && !homebrewChmodModeRe.MatchString(chmod.Mode) {
+			errs = append(errs, fmt.Errorf("chmod[%d].mode: invalid value %q, must be an octal mode, e.g. 0755", i, chmod.Mode))
+		}
+	}
+	for i, dep := range h.Dependencies {
+		switch dep.Type {
+		case "", "build", "optional", "recommended", "test":
+		default:
+			errs = append(errs, fmt.Errorf("dependencies[%d].type: invalid value %q, valid options are [build optional recommended test]", i, dep.Type))
+		}
+		switch dep.OS {
+		case "", "macos", "linux":
+		default:
+			errs = append(errs, fmt.Errorf("dependencies[%d].os: invalid value %q, valid options are [macos linux]", i, dep.OS))
+		}
+		if dep.UsesFromMacOS && dep.OS != "" {
+			errs = append(errs, fmt.Errorf("dependencies[%d].os: can't be used together with uses_from_macos", i))
+		}
+		if dep.UsesFromMacOS && dep.Version != "" {
+			errs = append(errs, fmt.Errorf("dependencies[%d].version: can't be used together with uses_from_macos", i))
+		}
+		if dep.Since != "" && !dep.UsesFromMacOS {
+			errs = append(errs, fmt.Errorf("dependencies[%d].since: can't be used without uses_from_macos", i))
+		}
+		switch dep.Since {
+		case "", "sonoma", "ventura", "monterey", "big_sur", "catalina", "mojave", "high_sierra", "sierra", "el_capitan":
+		default:
+			errs = append(errs, fmt.Errorf("dependencies[%d].since: invalid value %q, valid options are [sonoma ventura monterey big_sur catalina mojave high_sierra sierra el_capitan]", i, dep.Since))
+		}
+	}
+	for i, name := range h.OldNames {
+		if !homebrewFormulaNameRe.MatchString(name) {
+			errs = append(errs, fmt.Errorf("old_names[%d]: invalid formula name %q", i, name))
+		}
+	}
+	if h.Deprecate.Reason != "" && h.Deprecate.RenamedTo != "" {
+		errs = append(errs, errors.New("deprecate.reason: can't be used together with deprecate.renamed_to"))
+	}
+	if h.Deprecate.Date != "" && h.Deprecate.Reason == "" && h.Deprecate.RenamedTo == "" {
+		errs = append(errs, errors.New("deprecate.reason: required when deprecate.date is set"))
+	}
+	if h.Disable.Date != "" && h.Disable.Reason == "" {
+		errs = append(errs, errors.New("disable.reason: required when disable.date is set"))
+	}
+	if h.Disable != (HomebrewDisable{}) && h.Deprecate != (HomebrewDeprecate{}) {
+		errs = append(errs, errors.New("disable: can't be used together with deprecate"))
+	}
+	if h.Plist != "" && (len(h.Service.Run) > 0 || strings.TrimSpace(h.Service.Raw) != "") {
+		errs = append(errs, errors.New("plist: can't be used together with service, plist is deprecated and should be migrated to service"))
+	}
+	if h.IndentWidth < 0 {
+		errs = append(errs, fmt.Errorf("indent_width: invalid value %d, must not be negative", h.IndentWidth))
+	}
+	switch h.LineEnding {
+	case "", "lf", "crlf":
+	default:
+		errs = append(errs, fmt.Errorf("line_ending: invalid value %q, valid options are [lf crlf]", h.LineEnding))
+	}
+	if h.StripComponents < 0 {
+		errs = append(errs, fmt.Errorf("strip_components: invalid value %d, must not be negative", h.StripComponents))
+	}
+	if h.ExcludeNameRegex != "" {
+		if _, err := regexp.Compile(h.ExcludeNameRegex); err != nil {
+			errs = append(errs, fmt.Errorf("exclude_name_regex: invalid regular expression %q: %w", h.ExcludeNameRegex, err))
+		}
+	}
+	if h.Head.URL == "" && len(h.Head.Dependencies) > 0 {
+		errs = append(errs, errors.New("head.dependencies: can't be used without head.url"))
+	}
+	if h.Head.URL == "" && h.Head.Install != "" {
+		errs = append(errs, errors.New("head.install: can't be used without head.url"))
+	}
+	for i, dep := range h.Head.Dependencies {
+		switch dep.Type {
+		case "", "build", "optional", "recommended", "test":
+		default:
+			errs = append(errs, fmt.Errorf("head.dependencies[%d].type: invalid value %q, valid options are [build optional recommended test]", i, dep.Type))
+		}
+	}
+	for i, res := range h.Resources {
+		if res.Name == "" {
+			errs = append(errs, fmt.Errorf("resources[%d].name: required", i))
+		}
+		if res.URL == "" {
+			errs = append(errs, fmt.Errorf("resources[%d].url: required", i))
+		}
+		if res.SHA256 == "" {
+			errs = append(errs, fmt.Errorf("resources[%d].sha256: required", i))
+		}
+	}
+	if h.BottleManifest != "" && len(h.Bottle.Checksums) > 0 {
+		errs = append(errs, errors.New("bottle: can't be used together with bottle_manifest"))
+	}
+	if len(h.Bottle.Checksums) == 0 && (h.Bottle.RootURL != "" || h.Bottle.Rebuild != 0) {
+		errs = append(errs, errors.New("bottle.checksums: required"))
+	}
+	for i, sum := range h.Bottle.Checksums {
+		if sum.Tag == "" {
+			errs = append(errs, fmt.Errorf("bottle.checksums[%d].tag: required", i))
+		}
+		if sum.SHA256 == "" {
+			errs = append(errs, fmt.Errorf("bottle.checksums[%d].sha256: required", i))
+		}
+	}
+	if c := h.CompletionsFromExecutable; c.Binary == "" && (c.Args != "" || len(c.Shells) > 0) {
+		errs = append(errs, errors.New("completions_from_executable.binary: required"))
+	}
+	for i, shell := range h.CompletionsFromExecutable.Shells {
+		switch shell {
+		case "bash", "zsh", "fish", "pwsh":
+		default:
+			errs = append(errs, fmt.Errorf("completions_from_executable.shells[%d]: invalid value %q, valid options are [bash zsh fish pwsh]", i, shell))
+		}
+	}
+	if len(h.FromArchive) > 0 && len(h.InstallExclude) > 0 {
+		errs = append(errs, errors.New("from_archive: can't be used together with install_exclude"))
+	}
+	names := make([]string, 0, len(h.FromArchive))
+	for name := range h.FromArchive {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" {
+			errs = append(errs, errors.New("from_archive: formula name can't be empty"))
+		}
+		if len(h.FromArchive[name]) == 0 {
+			errs = append(errs, fmt.Errorf("from_archive[%q]: at least one binary is required", name))
+		}
+	}
+	if reason, ok := strings.CutPrefix(h.NoAutobump, ":"); ok && !isHomebrewNoAutobumpReason(reason) {
+		errs = append(errs, fmt.Errorf("no_autobump: invalid value %q, valid symbols are [%s]", h.NoAutobump, strings.Join(HomebrewNoAutobumpReasons, " ")))
+	}
+	switch h.Service.RunType {
+	case "", "immediate", "interval", "cron":
+	default:
+		errs = append(errs, fmt.Errorf("service.run_type: invalid value %q, valid options are [immediate interval cron]", h.Service.RunType))
+	}
+	if s := h.Service; len(s.Run) == 0 && (s.RunType != "" || s.KeepAlive || s.WorkingDir != "" || len(s.EnvironmentVariables) > 0) {
+		errs = append(errs, errors.New("service.run: required"))
+	}
+	if !homebrewClassSuffixRe.MatchString(h.ClassSuffix) {
+		errs = append(errs, fmt.Errorf("class_suffix: invalid value %q, must contain only letters, digits and underscores", h.ClassSuffix))
+	}
+	if h.ClassName != "" && !homebrewClassNameRe.MatchString(h.ClassName) {
+		errs = append(errs, fmt.Errorf("class_name: invalid value %q, must be a valid Ruby constant, e.g. FooAT2", h.ClassName))
+	}
+	if h.ClassName != "" && h.ClassSuffix != "" {
+		errs = append(errs, errors.New("class_name: can't be used together with class_suffix"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// isHomebrewNoAutobumpReason tells whether the given reason is one of the
+// reasons known by Homebrew.
+func isHomebrewNoAutobumpReason(reason string) bool {
+	for _, known := range HomebrewNoAutobumpReasons {
+		if reason == known {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTemplate checks that the given string is a syntactically valid
+// template.
+// Functions are not checked, as they are only known by the template engine.
+func validateTemplate(s string) error {
+	tree := parse.New("tmpl")
+	tree.Mode = parse.SkipFuncCheck
+	_, err := tree.Parse(s, "", "", map[string]*parse.Tree{})
+	return err
+}
+
 type Nix struct {
 	Name                  string       `yaml:"name,omitempty" json:"name,omitempty"`
 	Path                  string       `yaml:"path,omitempty" json:"path,omitempty"`
@@ -404,8 +992,19 @@ type Scoop struct {
 
 // CommitAuthor is the author of a Git commit.
 type CommitAuthor struct {
-	Name  string `yaml:"name,omitempty" json:"name,omitempty"`
-	Email string `yaml:"email,omitempty" json:"email,omitempty"`
+	Name    string        `yaml:"name,omitempty" json:"name,omitempty"`
+	Email   string        `yaml:"email,omitempty" json:"email,omitempty"`
+	Date    string        `yaml:"date,omitempty" json:"date,omitempty"`
+	Signing CommitSigning `yaml:"signing,omitempty" json:"signing,omitempty"`
+}
+
+// CommitSigning configures the GPG signing of the commits.
+// Only used when pushing to a Git repository.
+type CommitSigning struct {
+	// Key is either the ID of a key in the GPG keyring, or the path to
+	// (or contents of) an armored private key.
+	Key           string `yaml:"key,omitempty" json:"key,omitempty"`
+	PassphraseEnv string `yaml:"passphrase_env,omitempty" json:"passphrase_env,omitempty"`
 }
 
 // BuildHooks define actions to run before and/or after something.
@@ -421,6 +1020,7 @@ type IgnoredBuild struct {
 	Goarm   string `yaml:"goarm,omitempty" json:"goarm,omitempty" jsonschema:"oneof_type=string;integer"`
 	Gomips  string `yaml:"gomips,omitempty" json:"gomips,omitempty"`
 	Goamd64 string `yaml:"goamd64,omitempty" json:"goamd64,omitempty"`
+	Goarm64 string `yaml:"goarm64,omitempty" json:"goarm64,omitempty"`
 }
 
 // StringArray is a wrapper for an array of strings.
@@ -493,6 +1093,7 @@ type Build struct {
 	Goarm           []string        `yaml:"goarm,omitempty" json:"goarm,omitempty"`
 	Gomips          []string        `yaml:"gomips,omitempty" json:"gomips,omitempty"`
 	Goamd64         []string        `yaml:"goamd64,omitempty" json:"goamd64,omitempty"`
+	Goarm64         []string        `yaml:"goarm64,omitempty" json:"goarm64,omitempty"`
 	Targets         []string        `yaml:"targets,omitempty" json:"targets,omitempty"`
 	Ignore          []IgnoredBuild  `yaml:"ignore,omitempty" json:"ignore,omitempty"`
 	Dir             string          `yaml:"dir,omitempty" json:"dir,omitempty"`
@@ -519,6 +1120,7 @@ type BuildDetailsOverride struct {
 	Goarm        string                          `yaml:"goarm,omitempty" json:"goarm,omitempty" jsonschema:"oneof_type=string;integer"`
 	Gomips       string                          `yaml:"gomips,omitempty" json:"gomips,omitempty"`
 	Goamd64      string                          `yaml:"goamd64,omitempty" json:"goamd64,omitempty"`
+	Goarm64      string                          `yaml:"goarm64,omitempty" json:"goarm64,omitempty"`
 	BuildDetails `yaml:",inline" json:",inline"` // nolint: tagliatelle
 }
 
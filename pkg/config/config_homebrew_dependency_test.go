@@ -38,6 +38,9 @@ brews:
   - bar
   - name: foobar
     type: optional
+  - name: barfoo
+    version: v1.2.3
+    comment: needs at least 1.2
 `
 		buf := strings.NewReader(conf)
 		prop, err := LoadReader(buf)
@@ -51,6 +54,10 @@ brews:
 			}, {
 				Name: "foobar",
 				Type: "optional",
+			}, {
+				Name:    "barfoo",
+				Version: "v1.2.3",
+				Comment: "needs at least 1.2",
 			},
 		}, prop.Brews[0].Dependencies)
 	})
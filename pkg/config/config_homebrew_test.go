@@ -0,0 +1,250 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/goreleaser/goreleaser/internal/yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHomebrewValidate(t *testing.T) {
+	valid := Homebrew{
+		Name:       "foo_{{ .Env.FOO | lower }}",
+		QuoteStyle: "double",
+		Repository: RepoRef{
+			Owner: "foo",
+			Name:  "bar",
+		},
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, valid.Validate())
+	})
+
+	t.Run("git", func(t *testing.T) {
+		brew := valid
+		brew.Repository = RepoRef{
+			Name: "bar",
+			Git: GitRepoRef{
+				URL: "git@github.com:foo/bar.git",
+			},
+		}
+		require.NoError(t, brew.Validate())
+	})
+
+	t.Run("binary tests", func(t *testing.T) {
+		brew := valid
+		brew.TestConfig = HomebrewTestConfig{
+			Fixtures: []HomebrewTestFixture{{Path: "input.txt"}},
+			Binaries: []HomebrewBinaryTest{{Binary: "foo"}, {Args: "--version"}},
+		}
+		require.EqualError(t, brew.Validate(), "test_config.binaries[1].binary: required")
+	})
+
+	t.Run("pull request", func(t *testing.T) {
+		brew := valid
+		brew.Repository.Branch = "main"
+		brew.Repository.PullRequest = PullRequest{Enabled: true, Base: PullRequestBase{Branch: "main"}}
+		require.EqualError(t, brew.Validate(), `repository.branch: can't be the same as repository.pull_request.base.branch, "main"`)
+
+		brew.Repository.PullRequest.Base = PullRequestBase{Owner: "someone", Name: "bar", Branch: "main"}
+		require.NoError(t, brew.Validate())
+
+		brew.Repository.PullRequest.Base = PullRequestBase{Branch: "develop"}
+		require.NoError(t, brew.Validate())
+	})
+
+	t.Run("install with guessed binaries options", func(t *testing.T) {
+		brew := valid
+		brew.Install = `bin.install "foo"`
+		brew.ExtraInstall = `man1.install "foo.1"`
+		require.NoError(t, brew.Validate())
+
+		brew.InstallExclude = []string{"*.md"}
+		brew.StrictInstall = true
+		require.EqualError(t, brew.Validate(), `install_exclude: can't be used together with install, binaries are not guessed when install is set
+strict_install: can't be used together with install, binaries are not guessed when install is set`)
+	})
+
+	t.Run("bottle without checksums", func(t *testing.T) {
+		brew := valid
+		brew.Bottle = HomebrewBottle{RootURL: "https://ghcr.io/v2/foo/tap"}
+		require.EqualError(t, brew.Validate(), "bottle.checksums: required")
+	})
+
+	t.Run("plist and service", func(t *testing.T) {
+		brew := valid
+		brew.Plist = "<xml>whatever</xml>"
+		require.NoError(t, brew.Validate())
+
+		brew.Service = HomebrewService{Raw: "run foo/bar"}
+		require.EqualError(t, brew.Validate(), "plist: can't be used together with service, plist is deprecated and should be migrated to service")
+
+		brew.Plist = ""
+		require.NoError(t, brew.Validate())
+	})
+
+	t.Run("deprecate date", func(t *testing.T) {
+		brew := valid
+		brew.Deprecate = HomebrewDeprecate{Date: "2024-01-31"}
+		require.EqualError(t, brew.Validate(), "deprecate.reason: required when deprecate.date is set")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		brew := valid
+		brew.Name = "{{ .Name }"
+		brew.QuoteStyle = "backtick"
+		brew.ClassSuffix = "-cli"
+		brew.ClassName = "foo@2"
+		brew.RequireArch = "ppc"
+		brew.CustomRequire = "custom_download_strategy"
+		brew.RequireOS = "windows"
+		brew.Compat = "2.0"
+		brew.ChecksumAlgorithm = "md5"
+		brew.SourceBuild = true
+		brew.PublishDelay = "5"
+		brew.PublishConcurrency = -1
+		brew.PublishRetries = -1
+		brew.PublishRetryDelay = "1"
+		brew.SharedStrategyFile = "strategy.rb"
+		brew.DownloadStrategy = "CurlDownloadStrategy"
+		brew.URLUsing = "homebrew_curl"
+		brew.TestConfig.Fixtures = []HomebrewTestFixture{{Content: "foo"}}
+		brew.Chmod = []HomebrewChmod{{Mode: "u+x"}}
+		brew.Sorbet = "loose"
+		brew.ShareInstall = []HomebrewShareInstall{{Dst: "foo"}}
+		brew.Dependencies = []HomebrewDependency{
+			{Name: "git"},
+			{Name: "go", Type: "runtime", OS: "windows"},
+			{Name: "zlib", UsesFromMacOS: true, OS: "linux", Version: "1.2", Since: "leopard"},
+			{Name: "curl", Since: "catalina"},
+		}
+		brew.Head.Dependencies = []HomebrewDependency{{Name: "go", Type: "runtime"}}
+		brew.Head.Install = `system "make"`
+		brew.BottleManifest = "bottles.json"
+		brew.Bottle = HomebrewBottle{Checksums: []HomebrewBottleChecksum{{Tag: "sonoma"}, {SHA256: "abc"}}}
+		brew.Resources = []HomebrewResource{{Name: "helper", URL: "https://example.com/helper.tar.gz"}, {}}
+		brew.OldNames = []string{"foo-legacy", "Foo Bar"}
+		brew.Deprecate = HomebrewDeprecate{RenamedTo: "bar", Reason: "old"}
+		brew.Disable = HomebrewDisable{Date: "2024-01-31"}
+		brew.IndentWidth = -4
+		brew.StripComponents = -1
+		brew.LineEnding = "cr"
+		brew.ExcludeNameRegex = "debug("
+		brew.CompletionsFromExecutable = HomebrewCompletions{Args: "completion", Shells: []string{"zsh", "tcsh"}}
+		brew.Service = HomebrewService{RunType: "daily", KeepAlive: true}
+		brew.InstallExclude = []string{"*.md"}
+		brew.FromArchive = map[string][]string{"foo": {"foo"}, "bar": nil, "": {"baz"}}
+		brew.NoAutobump = ":because_i_said_so"
+		brew.Repositories = []RepoRef{{Owner: "foo"}, {Name: "bar"}}
+		brew.Repository = RepoRef{
+			Name: "bar",
+		}
+		require.EqualError(t, brew.Validate(), `name: invalid template: template: tmpl:1: unexpected "}" in operand
+repository.owner: required when repository.name is set
+repositories[0].name: required
+repositories[1].owner: required
+quote_style: invalid value "backtick", valid options are [double single]
+require_arch: invalid value "ppc", valid options are [x86_64 arm64 intel arm]
+require_os: invalid value "windows", valid options are [macos linux]
+compat: invalid value "2.0", valid options are [3.0 4.0]
+checksum_algorithm: invalid value "md5", valid options are [sha256 sha512]
+source_build: install is required
+publish_delay: invalid value "5", must be a duration, e.g. 5s
+publish_concurrency: invalid value -1, must not be negative
+publish_retries: invalid value -1, must not be negative
+publish_retry_delay: invalid value "1", must be a duration, e.g. 5s
+url_using: can't be used together with download_strategy
+shared_strategy_file: can't be used together with custom_require
+test_config.command: required when test_config.fixtures is set
+test_config.fixtures[0].path: required
+sorbet: invalid value "loose", valid options are [ignore false true strict strong]
+share_install[0].src: required
+chmod[0].path: required
+chmod[0].mode: invalid value "u+x", must be an octal mode, e.g. 0755
+dependencies[1].type: invalid value "runtime", valid options are [build optional recommended test]
+dependencies[1].os: invalid value "windows", valid options are [macos linux]
+dependencies[2].os: can't be used together with uses_from_macos
+dependencies[2].version: can't be used together with uses_from_macos
+dependencies[2].since: invalid value "leopard", valid options are [sonoma ventura monterey big_sur catalina mojave high_sierra sierra el_capitan]
+dependencies[3].since: can't be used without uses_from_macos
+old_names[1]: invalid formula name "Foo Bar"
+deprecate.reason: can't be used together with deprecate.renamed_to
+disable.reason: required when disable.date is set
+disable: can't be used together with deprecate
+indent_width: invalid value -4, must not be negative
+line_ending: invalid value "cr", valid options are [lf crlf]
+strip_components: invalid value -1, must not be negative
+exclude_name_regex: invalid regular expression "debug(": error parsing regexp: missing closing ): `+"`debug(`"+`
+head.dependencies: can't be used without head.url
+head.install: can't be used without head.url
+head.dependencies[0].type: invalid value "runtime", valid options are [build optional recommended test]
+resources[0].sha256: required
+resources[1].name: required
+resources[1].url: required
+resources[1].sha256: required
+bottle: can't be used together with bottle_manifest
+bottle.checksums[0].sha256: required
+bottle.checksums[1].tag: required
+completions_from_executable.binary: required
+completions_from_executable.shells[1]: invalid value "tcsh", valid options are [bash zsh fish pwsh]
+from_archive: can't be used together with install_exclude
+from_archive: formula name can't be empty
+from_archive["bar"]: at least one binary is required
+no_autobump: invalid value ":because_i_said_so", valid symbols are [bumped_by_upstream extract_plist incompatible_version_format latest_version requires_manual_review]
+service.run_type: invalid value "daily", valid options are [immediate interval cron]
+service.run: required
+class_suffix: invalid value "-cli", must contain only letters, digits and underscores
+class_name: invalid value "foo@2", must be a valid Ruby constant, e.g. FooAT2
+class_name: can't be used together with class_suffix`)
+	})
+}
+
+func TestHomebrewServiceUnmarshal(t *testing.T) {
+	t.Run("string", func(t *testing.T) {
+		var actual Homebrew
+		require.NoError(t, yaml.UnmarshalStrict([]byte(`service: |
+  run [opt_bin/"foo"]
+  keep_alive true
+`), &actual))
+		require.Equal(t, HomebrewService{
+			Raw: "run [opt_bin/\"foo\"]\nkeep_alive true\n",
+		}, actual.Service)
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		var actual Homebrew
+		require.NoError(t, yaml.UnmarshalStrict([]byte(`service:
+  run: foo
+  keep_alive: true
+  environment_variables:
+    FOO: bar
+`), &actual))
+		require.Equal(t, HomebrewService{
+			Run:                  StringArray{"foo"},
+			KeepAlive:            true,
+			EnvironmentVariables: map[string]string{"FOO": "bar"},
+		}, actual.Service)
+	})
+}
+
+func TestHomebrewServiceMarshal(t *testing.T) {
+	for name, service := range map[string]HomebrewService{
+		"string": {Raw: "run [opt_bin/\"foo\"]\nkeep_alive true\n"},
+		"struct": {
+			Run:                  StringArray{"foo", "serve"},
+			RunType:              "immediate",
+			KeepAlive:            true,
+			WorkingDir:           "/tmp",
+			EnvironmentVariables: map[string]string{"FOO": "bar"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bts, err := yaml.Marshal(Homebrew{Service: service})
+			require.NoError(t, err)
+			var actual Homebrew
+			require.NoError(t, yaml.UnmarshalStrict(bts, &actual))
+			require.Equal(t, service, actual.Service)
+		})
+	}
+}
@@ -101,6 +101,8 @@ type Context struct {
 	SkipKo             bool
 	SkipDocker         bool
 	SkipBefore         bool
+	BrewDryRun         bool
+	BrewValidateOnly   bool
 	Clean              bool
 	PreRelease         bool
 	Deprecated         bool